@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignUpGivesNoRole(t *testing.T) {
+	s := newTestServer(t)
+
+	// Even the very first account waits for a role
+	s.signUp("first@example.com")
+	first := s.login("first@example.com")
+	if first.Role != nil {
+		t.Fatalf("first account got role %s, want none", *first.Role)
+	}
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodGet, "/foods", first.Token, nil)
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodPost, "/notes", first.Token, gin.H{})
+	s.expect(http.StatusOK, http.MethodPost, "/users/logout", first.Token, nil, nil)
+
+	admin := s.admin()
+	if admin.Role == nil || *admin.Role != models.RoleAdmin {
+		t.Fatalf("granted account got role %v, want %s", admin.Role, models.RoleAdmin)
+	}
+	if err := grantAdmin(s.repos.Users, "nobody@example.com"); err == nil {
+		t.Fatal("granted admin to an account that does not exist")
+	}
+
+	var users listPage[session]
+	s.expect(http.StatusOK, http.MethodGet, "/users", admin.Token, nil, &users)
+	if users.Total != 2 || len(users.Items) != 2 {
+		t.Fatalf("listed %d of %d users, want 2 of 2", len(users.Items), users.Total)
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/users/"+first.UserId, admin.Token, nil, nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/users/000000000000000000000000", admin.Token, nil)
+}
+
+func TestUserResponsesLeaveOutSecrets(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	manager := s.staff(admin, "manager@example.com", models.RoleManager)
+
+	// A manager reading the admin must not get the hash or a usable token
+	var user map[string]any
+	s.expect(http.StatusOK, http.MethodGet, "/users/"+admin.UserId, manager.Token, nil, &user)
+	var users listPage[map[string]any]
+	s.expect(http.StatusOK, http.MethodGet, "/users", admin.Token, nil, &users)
+	for _, shown := range append(users.Items, user) {
+		for _, secret := range []string{"Password", "token", "refresh_token"} {
+			if _, ok := shown[secret]; ok {
+				t.Fatalf("user %v was shown with its %s", shown["user_id"], secret)
+			}
+		}
+	}
+
+	// Logging in hands out the tokens, still without the hash
+	var logged map[string]any
+	s.expect(http.StatusOK, http.MethodPost, "/users/login", "", gin.H{"email": "manager@example.com", "Password": "secret-password"}, &logged)
+	if _, ok := logged["Password"]; ok {
+		t.Fatal("login answered with the password hash")
+	}
+	if logged["token"] == nil || logged["refresh_token"] == nil {
+		t.Fatalf("login answered %v, want the token pair", logged)
+	}
+}
+
+func TestSignUpValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	apiErr := s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      "not-an-email",
+		"Password":   "secret-password",
+		"phone":      "555-0001",
+	})
+	if len(apiErr.Details) != 1 || apiErr.Details[0].Field != "email" || apiErr.Details[0].Rule != "email" {
+		t.Fatalf("got details %+v, want one email rule on email", apiErr.Details)
+	}
+	apiErr = s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      "short@example.com",
+		"Password":   "short",
+		"phone":      "555-0001",
+	})
+	if len(apiErr.Details) != 1 || apiErr.Details[0].Field != "Password" || apiErr.Details[0].Rule != "min" {
+		t.Fatalf("got details %+v, want one min rule on Password", apiErr.Details)
+	}
+	s.expectError(http.StatusBadRequest, helper.CodeInvalidBody, http.MethodPost, "/users/signup", "", "not a user")
+
+	s.signUp("taken@example.com")
+	s.expectError(http.StatusConflict, helper.CodeAlreadyExists, http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      "taken@example.com",
+		"Password":   "secret-password",
+		"phone":      "555-9999",
+	})
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("admin@example.com")
+
+	// An unknown account is indistinguishable from a wrong password
+	wrong := s.expectError(http.StatusUnauthorized, helper.CodeInvalidCredentials, http.MethodPost, "/users/login", "", gin.H{"email": "admin@example.com", "Password": "wrong-password"})
+	unknown := s.expectError(http.StatusUnauthorized, helper.CodeInvalidCredentials, http.MethodPost, "/users/login", "", gin.H{"email": "nobody@example.com", "Password": "secret-password"})
+	if wrong.Message != unknown.Message {
+		t.Fatalf("login errors differ: %q and %q", wrong.Message, unknown.Message)
+	}
+}
+
+func TestAuthenticationIsRequired(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", "", nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/no-such-endpoint", admin.Token, nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", "not-a-token", nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", admin.RefreshToken, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/foods", admin.Token, nil, nil)
+}
+
+func TestRolesAreEnforced(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	waiter := s.staff(admin, "waiter@example.com", models.RoleWaiter)
+
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodGet, "/users", waiter.Token, nil)
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodPost, "/menus", waiter.Token, gin.H{"name": "Dinner", "category": "Mains"})
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodPatch, "/users/"+admin.UserId+"/role", waiter.Token, gin.H{"role": models.RoleWaiter})
+	s.expect(http.StatusBadRequest, http.MethodPatch, "/users/"+admin.UserId+"/role", admin.Token, gin.H{"role": models.RoleWaiter}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPatch, "/users/"+waiter.UserId+"/role", admin.Token, gin.H{"role": "OWNER"}, nil)
+}
+
+func TestRoleChangeAppliesToIssuedTokens(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	second := s.staff(admin, "second@example.com", models.RoleAdmin)
+	s.expect(http.StatusOK, http.MethodGet, "/users", second.Token, nil, nil)
+
+	// The demoted admin keeps the token but loses the rights straight away
+	s.expect(http.StatusOK, http.MethodPatch, "/users/"+second.UserId+"/role", admin.Token, gin.H{"role": models.RoleWaiter}, nil)
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodGet, "/users", second.Token, nil)
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodPatch, "/users/"+admin.UserId+"/role", second.Token, gin.H{"role": models.RoleWaiter})
+	s.expect(http.StatusOK, http.MethodGet, "/foods", second.Token, nil, nil)
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+
+	var refreshed session
+	s.expect(http.StatusOK, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": admin.RefreshToken}, &refreshed)
+	if refreshed.Token == "" || refreshed.RefreshToken == admin.RefreshToken {
+		t.Fatalf("refresh did not hand out a new token pair")
+	}
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/foods", refreshed.Token, nil, nil)
+
+	// Presenting the old refresh token again ends the session
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": admin.RefreshToken})
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", refreshed.Token, nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": refreshed.RefreshToken})
+
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": refreshed.Token})
+	s.expect(http.StatusBadRequest, http.MethodPost, "/users/refresh", "", gin.H{}, nil)
+}
+
+func TestAccessTokenIsNoRefreshTokenWithOneKey(t *testing.T) {
+	// The refresh key defaults to the access key
+	defer func(key string) { helper.REFRESH_SECRET_KEY = key }(helper.REFRESH_SECRET_KEY)
+	helper.REFRESH_SECRET_KEY = helper.SECRET_KEY
+
+	s := newTestServer(t)
+	admin := s.admin()
+
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": admin.Token})
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", admin.RefreshToken, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/foods", admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": admin.RefreshToken}, nil)
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+
+	s.expect(http.StatusOK, http.MethodPost, "/users/logout", admin.Token, nil, nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", admin.Token, nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodPost, "/users/refresh", "", gin.H{"refresh_token": admin.RefreshToken})
+}
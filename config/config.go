@@ -0,0 +1,303 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the service. Values come from the defaults
+// below, then the file named by CONFIG_FILE (YAML or TOML), then environment
+// variables, which win.
+type Config struct {
+	Port            string     `yaml:"port" toml:"port"`
+	ShutdownTimeout Duration   `yaml:"shutdown_timeout" toml:"shutdown_timeout"` // how long in-flight requests get to finish
+	Mongo           Mongo      `yaml:"mongo" toml:"mongo"`
+	Auth            Auth       `yaml:"auth" toml:"auth"`
+	CORS            CORS       `yaml:"cors" toml:"cors"`
+	Restaurant      Restaurant `yaml:"restaurant" toml:"restaurant"`
+	Payments        Payments   `yaml:"payments" toml:"payments"`
+	Printers        string     `yaml:"printers" toml:"printers"` // name=url,name=url
+}
+
+type Mongo struct {
+	URI                    string   `yaml:"uri" toml:"uri"`
+	Database               string   `yaml:"database" toml:"database"`
+	MinPoolSize            uint64   `yaml:"min_pool_size" toml:"min_pool_size"`
+	MaxPoolSize            uint64   `yaml:"max_pool_size" toml:"max_pool_size"`
+	ConnectTimeout         Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	ServerSelectionTimeout Duration `yaml:"server_selection_timeout" toml:"server_selection_timeout"`
+	MigrateOnStart         bool     `yaml:"migrate_on_start" toml:"migrate_on_start"` // apply pending migrations before serving
+}
+
+type Auth struct {
+	SecretKey        string   `yaml:"secret_key" toml:"secret_key"`
+	RefreshSecretKey string   `yaml:"refresh_secret_key" toml:"refresh_secret_key"` // defaults to SecretKey
+	AccessTokenTTL   Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL  Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+}
+
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods" toml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers" toml:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age"`
+}
+
+type Restaurant struct {
+	Id                   string   `yaml:"id" toml:"id"`
+	Name                 string   `yaml:"name" toml:"name"`
+	Address              []string `yaml:"address" toml:"address"`
+	Currency             string   `yaml:"currency" toml:"currency"`
+	FiscalYearStartMonth int      `yaml:"fiscal_year_start_month" toml:"fiscal_year_start_month"`
+}
+
+type Payments struct {
+	WebhookSecret string `yaml:"webhook_secret" toml:"webhook_secret"`
+}
+
+// Duration reads values such as "90s" or "24h" from files and the environment.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Defaults are the settings of a local development setup, minus the secrets.
+func Defaults() Config {
+	return Config{
+		Port:            "8000",
+		ShutdownTimeout: Duration{30 * time.Second},
+		Mongo: Mongo{
+			URI:                    "mongodb://localhost:27017",
+			Database:               "restaurant_db",
+			MaxPoolSize:            100,
+			ConnectTimeout:         Duration{10 * time.Second},
+			ServerSelectionTimeout: Duration{10 * time.Second},
+		},
+		Auth: Auth{
+			AccessTokenTTL:  Duration{24 * time.Hour},
+			RefreshTokenTTL: Duration{168 * time.Hour},
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "token"},
+			MaxAge:         Duration{12 * time.Hour},
+		},
+		Restaurant: Restaurant{
+			Id:                   "default",
+			Name:                 "Restaurant",
+			Currency:             "USD",
+			FiscalYearStartMonth: 1,
+		},
+	}
+}
+
+// Load reads the configuration and validates it. The error lists every
+// problem found, not just the first.
+func Load() (Config, error) {
+	cfg := Defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	envErr := applyEnv(&cfg)
+	// Amounts sent in lower case are upper-cased too, see Money.UnmarshalJSON
+	cfg.Restaurant.Currency = strings.ToUpper(strings.TrimSpace(cfg.Restaurant.Currency))
+	if cfg.Auth.RefreshSecretKey == "" {
+		cfg.Auth.RefreshSecretKey = cfg.Auth.SecretKey
+	}
+	return cfg, errors.Join(envErr, cfg.Validate())
+}
+
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s: use a .yaml, .yml or .toml file", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides the settings that have an environment variable set.
+func applyEnv(cfg *Config) error {
+	var errs []error
+	str := func(name string, target *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+	list := func(name string, separator string, target *[]string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = nil
+			for _, item := range strings.Split(value, separator) {
+				if item = strings.TrimSpace(item); item != "" {
+					*target = append(*target, item)
+				}
+			}
+		}
+	}
+	number := func(name string, target *uint64) {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a whole number, got %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	duration := func(name string, target *Duration) {
+		if value, ok := os.LookupEnv(name); ok {
+			if err := target.UnmarshalText([]byte(value)); err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a duration such as 30s or 24h, got %q", name, value))
+			}
+		}
+	}
+	boolean := func(name string, target *bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be true or false, got %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	str("PORT", &cfg.Port)
+	duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	str("MONGODB_URI", &cfg.Mongo.URI)
+	str("MONGODB_DATABASE", &cfg.Mongo.Database)
+	number("MONGODB_MIN_POOL_SIZE", &cfg.Mongo.MinPoolSize)
+	number("MONGODB_MAX_POOL_SIZE", &cfg.Mongo.MaxPoolSize)
+	duration("MONGODB_CONNECT_TIMEOUT", &cfg.Mongo.ConnectTimeout)
+	duration("MONGODB_SERVER_SELECTION_TIMEOUT", &cfg.Mongo.ServerSelectionTimeout)
+	boolean("MONGODB_MIGRATE_ON_START", &cfg.Mongo.MigrateOnStart)
+
+	str("SECRET_KEY", &cfg.Auth.SecretKey)
+	str("REFRESH_SECRET_KEY", &cfg.Auth.RefreshSecretKey)
+	duration("ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL)
+	duration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+
+	list("CORS_ALLOWED_ORIGINS", ",", &cfg.CORS.AllowedOrigins)
+	list("CORS_ALLOWED_METHODS", ",", &cfg.CORS.AllowedMethods)
+	list("CORS_ALLOWED_HEADERS", ",", &cfg.CORS.AllowedHeaders)
+	boolean("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials)
+	duration("CORS_MAX_AGE", &cfg.CORS.MaxAge)
+
+	str("RESTAURANT_ID", &cfg.Restaurant.Id)
+	str("RESTAURANT_NAME", &cfg.Restaurant.Name)
+	list("RESTAURANT_ADDRESS", "|", &cfg.Restaurant.Address)
+	str("CURRENCY", &cfg.Restaurant.Currency)
+	if value, ok := os.LookupEnv("FISCAL_YEAR_START_MONTH"); ok {
+		month, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("FISCAL_YEAR_START_MONTH must be a month number, got %q", value))
+		}
+		cfg.Restaurant.FiscalYearStartMonth = month
+	}
+
+	str("PAYMENT_WEBHOOK_SECRET", &cfg.Payments.WebhookSecret)
+	str("PRINTERS", &cfg.Printers)
+	return errors.Join(errs...)
+}
+
+// currencyCode is an ISO 4217 code as amounts carry it.
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Validate checks that the settings can work together.
+func (cfg Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		fail("port must be between 1 and 65535, got %q", cfg.Port)
+	}
+	if cfg.ShutdownTimeout.Duration <= 0 {
+		fail("shutdown timeout must be positive")
+	}
+
+	if uri, err := url.Parse(cfg.Mongo.URI); err != nil || (uri.Scheme != "mongodb" && uri.Scheme != "mongodb+srv") {
+		fail("mongo uri must start with mongodb:// or mongodb+srv://")
+	}
+	if cfg.Mongo.Database == "" {
+		fail("mongo database name is required")
+	}
+	if cfg.Mongo.MaxPoolSize == 0 || cfg.Mongo.MinPoolSize > cfg.Mongo.MaxPoolSize {
+		fail("mongo pool sizes must satisfy 0 <= min (%d) <= max (%d) and max > 0", cfg.Mongo.MinPoolSize, cfg.Mongo.MaxPoolSize)
+	}
+	if cfg.Mongo.ConnectTimeout.Duration <= 0 || cfg.Mongo.ServerSelectionTimeout.Duration <= 0 {
+		fail("mongo timeouts must be positive")
+	}
+
+	if len(cfg.Auth.SecretKey) < 32 {
+		fail("SECRET_KEY is required and must be at least 32 characters")
+	}
+	if cfg.Auth.RefreshSecretKey != cfg.Auth.SecretKey && len(cfg.Auth.RefreshSecretKey) < 32 {
+		fail("REFRESH_SECRET_KEY must be at least 32 characters")
+	}
+	if cfg.Auth.AccessTokenTTL.Duration <= 0 || cfg.Auth.RefreshTokenTTL.Duration <= 0 {
+		fail("token lifetimes must be positive")
+	}
+	if cfg.Auth.RefreshTokenTTL.Duration < cfg.Auth.AccessTokenTTL.Duration {
+		fail("the refresh token lifetime (%s) must not be shorter than the access token lifetime (%s)", cfg.Auth.RefreshTokenTTL, cfg.Auth.AccessTokenTTL)
+	}
+
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if origin == "*" {
+			if cfg.CORS.AllowCredentials {
+				fail("cors cannot allow credentials for every origin (*)")
+			}
+			continue
+		}
+		if parsed, err := url.Parse(origin); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			fail("cors origin %q must look like https://example.com", origin)
+		}
+	}
+
+	if !currencyCode.MatchString(cfg.Restaurant.Currency) {
+		fail("currency must be a three letter ISO 4217 code, got %q", cfg.Restaurant.Currency)
+	}
+	if cfg.Restaurant.Id == "" {
+		fail("restaurant id is required")
+	}
+	if cfg.Restaurant.FiscalYearStartMonth < 1 || cfg.Restaurant.FiscalYearStartMonth > 12 {
+		fail("fiscal year start month must be between 1 and 12, got %d", cfg.Restaurant.FiscalYearStartMonth)
+	}
+	return errors.Join(errs...)
+}
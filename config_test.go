@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go-restaurant-management/config"
+)
+
+func TestConfigCurrencyIsAnUpperCaseCode(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SECRET_KEY", testSettings().Auth.SecretKey)
+
+	// Amounts come in upper-cased, so the restaurant currency has to match them
+	t.Setenv("CURRENCY", " usd")
+	settings, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Restaurant.Currency != "USD" {
+		t.Fatalf("loaded currency %q, want USD", settings.Restaurant.Currency)
+	}
+
+	for _, currency := range []string{"US1", "U$D", "EURO"} {
+		t.Setenv("CURRENCY", currency)
+		if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "currency") {
+			t.Fatalf("loading currency %q failed with %v, want a currency error", currency, err)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (h *Handler) GetInvoiceCreditNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		creditNotes, err := h.invoiceCreditNotes(ctx, c.Param("invoice_id"))
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing credit notes", err))
+			return
+		}
+		c.JSON(http.StatusOK, creditNotes)
+	}
+}
+
+func (h *Handler) GetCreditNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		creditNote, err := h.CreditNotes.Get(ctx, c.Param("credit_note_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("credit note not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the credit note", err))
+			return
+		}
+		c.JSON(http.StatusOK, creditNote)
+	}
+}
+
+// CreateCreditNote credits an issued invoice in full or for some quantity of
+// its lines and, when a refund method is given, gives the money back through
+// the payment ledger.
+func (h *Handler) CreateCreditNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var body struct {
+			Reason_code string `json:"reason_code"`
+			Reason      string `json:"reason"`
+			Full        bool   `json:"full"`
+			Lines       []struct {
+				Order_item_id string `json:"order_item_id" validate:"required"`
+				Quantity      int64  `json:"quantity" validate:"min=1"`
+			} `json:"lines" validate:"dive"`
+			Refund_method string `json:"refund_method" validate:"omitempty,eq=CARD|eq=CASH|eq=GIFT_CARD|eq=VOUCHER|eq=OTHER"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+		if validationErr := validate.Struct(body); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+		if body.Full == (len(body.Lines) > 0) {
+			abort(c, helper.BadRequest("credit either the full invoice or a list of lines"))
+			return
+		}
+
+		invoice, err := h.Invoices.Get(ctx, c.Param("invoice_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Invoice not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return
+		}
+		if invoice.Breakdown == nil {
+			abort(c, helper.Conflict("the invoice has no issued totals to credit"))
+			return
+		}
+
+		previous, err := h.invoiceCreditNotes(ctx, invoice.Invoice_id)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing credit notes", err))
+			return
+		}
+
+		now := time.Now()
+		creditNote := models.CreditNote{
+			Invoice_id:  invoice.Invoice_id,
+			Order_id:    invoice.Order_id,
+			Reason_code: body.Reason_code,
+			Reason:      body.Reason,
+			Full:        body.Full,
+			Created_by:  c.GetString("uid"),
+		}
+		if validationErr := validate.Struct(creditNote); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		requested := map[string]int64{}
+		for _, line := range body.Lines {
+			requested[line.Order_item_id] += line.Quantity
+		}
+		lines, total, err := creditNoteLines(invoice, previous, body.Full, requested)
+		if err != nil {
+			abort(c, helper.Conflict(err.Error()))
+			return
+		}
+		creditNote.Lines = lines
+		creditNote.Total = total
+		creditNote.Created_at = now
+		creditNote.Updated_at = now
+		creditNote.ID = primitive.NewObjectID()
+		creditNote.Credit_note_id = creditNote.ID.Hex()
+
+		if err := h.issueCreditNote(ctx, invoice, previous, creditNote); err != nil {
+			abort(c, err)
+			return
+		}
+
+		invoice, err = h.refreshInvoiceBalance(ctx, invoice)
+		if err != nil {
+			abort(c, helper.Internal("the credit note was created but the invoice could not be updated", err))
+			return
+		}
+
+		// Only money that was actually received can be given back
+		refunds := []models.Payment{}
+		if body.Refund_method != "" {
+			amount := creditNote.Total
+			if invoice.Amount_paid.Amount < amount.Amount {
+				amount = invoice.Amount_paid
+			}
+
+			var refundErr error
+			if amount.Amount > 0 {
+				refunds, refundErr = h.refundPayments(ctx, c.GetString("uid"), invoice, creditNote, body.Refund_method, amount)
+			}
+			if len(refunds) > 0 {
+				if updated, err := h.refreshInvoiceBalance(ctx, invoice); err == nil {
+					invoice = updated
+				}
+			}
+			if refundErr != nil {
+				// The credit note stands, so the reply carries it next to the error
+				message := "the credit note was created but the refund failed: " + refundErr.Error()
+				failure := helper.UpstreamFailed(message, refundErr)
+				var declined *refundDeclinedError
+				if errors.As(refundErr, &declined) {
+					failure = helper.Conflict(message)
+				}
+				c.JSON(failure.Status, gin.H{"error": failure, "credit_note": creditNote, "refunds": refunds, "invoice": invoice})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"credit_note": creditNote, "refunds": refunds, "invoice": invoice})
+	}
+}
+
+// issueCreditNote credits the invoice for the credit note and stores it. The
+// credit is added on the invoice first, on condition that nothing was
+// credited since the previous credit notes were read, so of two requests
+// crediting the same lines one gets a conflict rather than crediting and
+// refunding them a second time.
+func (h *Handler) issueCreditNote(ctx context.Context, invoice models.Invoice, previous []models.CreditNote, creditNote models.CreditNote) error {
+	credited, err := creditedTotal(invoice, previous)
+	if err != nil {
+		return helper.Internal("error occurred while totalling the credit notes of the invoice", err)
+	}
+	creditedAfter, err := credited.CheckedAdd(creditNote.Total)
+	if err != nil {
+		return helper.Internal("error occurred while totalling the credit notes of the invoice", err)
+	}
+
+	return h.Transaction(ctx, func(ctx context.Context) error {
+		if _, err := h.Invoices.Credit(ctx, invoice.Invoice_id, credited, creditNote.Total); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return helper.Conflict("the invoice was credited by another request, try again")
+			}
+			return helper.Internal("error occurred while crediting the invoice", err)
+		}
+		repository.Undo(ctx, func(ctx context.Context) error {
+			_, err := h.Invoices.Credit(ctx, invoice.Invoice_id, creditedAfter, creditNote.Total.Multiply(-1))
+			return err
+		})
+
+		if err := h.CreditNotes.Create(ctx, creditNote); err != nil {
+			return helper.Internal("Credit note could not be created", err)
+		}
+		return nil
+	})
+}
+
+// creditedTotal adds up the credit notes issued against an invoice, in the
+// currency of the invoice.
+func creditedTotal(invoice models.Invoice, creditNotes []models.CreditNote) (models.Money, error) {
+	totals := make([]models.Money, len(creditNotes))
+	for i, creditNote := range creditNotes {
+		totals[i] = creditNote.Total
+	}
+	return models.SumMoney(invoice.Total.Currency, totals...)
+}
+
+// creditNoteLines works out what a new credit note credits. Each invoice
+// line stands for its share of the invoice total, so tax, service charge and
+// discounts are credited in proportion. Crediting the last units of a line,
+// or of the invoice, credits exactly what is left so no cents go astray.
+func creditNoteLines(invoice models.Invoice, previous []models.CreditNote, full bool, requested map[string]int64) ([]models.CreditNoteLine, models.Money, error) {
+	currency := invoice.Total.Currency
+	credited, err := creditedTotal(invoice, previous)
+	if err != nil {
+		return nil, credited, err
+	}
+	creditedQuantity := map[string]int64{}
+	creditedAmount := map[string]int64{}
+	for _, creditNote := range previous {
+		for _, line := range creditNote.Lines {
+			creditedQuantity[line.Order_item_id] += line.Quantity
+			creditedAmount[line.Order_item_id] += line.Amount.Amount
+		}
+	}
+
+	remaining, err := invoice.Total.CheckedSub(credited)
+	if err != nil {
+		return nil, remaining, err
+	}
+	if remaining.Amount <= 0 {
+		return nil, remaining, errors.New("the invoice has already been fully credited")
+	}
+
+	breakdown := invoice.Breakdown
+	netSubtotal, err := breakdown.Subtotal.CheckedSub(breakdown.Discount_total)
+	if err != nil {
+		return nil, remaining, err
+	}
+
+	lines := []models.CreditNoteLine{}
+	total := models.NewMoney(0, currency)
+	fullyCredited := true
+	for _, priced := range breakdown.Lines {
+		left := priced.Quantity - creditedQuantity[priced.Order_item_id]
+		quantity := requested[priced.Order_item_id]
+		if full {
+			quantity = left
+		}
+		delete(requested, priced.Order_item_id)
+
+		if quantity > left {
+			return nil, total, fmt.Errorf("only %d of %s can still be credited", left, priced.Name)
+		}
+		if quantity < left {
+			fullyCredited = false
+		}
+		if quantity == 0 {
+			continue
+		}
+
+		lineNet, err := priced.Line_total.CheckedSub(priced.Discount)
+		if err != nil {
+			return nil, total, err
+		}
+		lineShare := helper.ShareOf(invoice.Total, lineNet.Amount, netSubtotal.Amount)
+		amount := helper.ShareOf(lineShare, quantity, priced.Quantity)
+		if quantity == left {
+			amount = models.NewMoney(lineShare.Amount-creditedAmount[priced.Order_item_id], currency)
+		}
+
+		lines = append(lines, models.CreditNoteLine{
+			Order_item_id: priced.Order_item_id,
+			Name:          priced.Name,
+			Quantity:      quantity,
+			Amount:        amount,
+		})
+		if total, err = total.CheckedAdd(amount); err != nil {
+			return nil, total, err
+		}
+	}
+	for orderItemId := range requested {
+		return nil, total, fmt.Errorf("order item %s is not on the invoice", orderItemId)
+	}
+
+	// Whatever rounding left over goes on the last line of the final credit
+	if (fullyCredited || total.Amount > remaining.Amount) && len(lines) > 0 {
+		last := &lines[len(lines)-1]
+		last.Amount = models.NewMoney(last.Amount.Amount+remaining.Amount-total.Amount, currency)
+		total = remaining
+	}
+	if total.Amount <= 0 {
+		return nil, total, errors.New("nothing is left to credit on these lines")
+	}
+	return lines, total, nil
+}
+
+// refundDeclinedError is a refund the ledger or the provider would not allow.
+type refundDeclinedError struct {
+	message string
+}
+
+func (e *refundDeclinedError) Error() string {
+	return e.message
+}
+
+// refundPayments records refunds for a credit note. Card refunds go back to
+// the captured card payments of the invoice, oldest first, through the
+// payment provider; other methods are paid out at the till.
+func (h *Handler) refundPayments(ctx context.Context, uid string, invoice models.Invoice, creditNote models.CreditNote, method string, amount models.Money) ([]models.Payment, error) {
+	refunds := []models.Payment{}
+	newRefund := func(refundAmount models.Money) models.Payment {
+		now := time.Now()
+		tip := models.NewMoney(0, refundAmount.Currency)
+		refund := models.Payment{
+			Invoice_id:     invoice.Invoice_id,
+			Method:         &method,
+			Amount:         &refundAmount,
+			Tip:            &tip,
+			Received_by:    uid,
+			Paid_at:        now,
+			Type:           models.PaymentTypeRefund,
+			Status:         models.PaymentStatusCaptured,
+			Credit_note_id: creditNote.Credit_note_id,
+		}
+		refund.Created_at = now
+		refund.Updated_at = now
+		refund.ID = primitive.NewObjectID()
+		refund.Payment_id = refund.ID.Hex()
+		return refund
+	}
+
+	if method != models.PaymentMethodCard {
+		refund := newRefund(amount)
+		if err := h.Payments.Create(ctx, refund); err != nil {
+			return refunds, err
+		}
+		return append(refunds, refund), nil
+	}
+
+	payments, err := h.invoicePayments(ctx, invoice.Invoice_id)
+	if err != nil {
+		return refunds, err
+	}
+	alreadyRefunded := map[string]int64{}
+	for _, payment := range payments {
+		if payment.IsRefund() && payment.Refunded_payment_id != "" {
+			alreadyRefunded[payment.Refunded_payment_id] += payment.Amount.Amount
+		}
+	}
+
+	left := amount
+	for _, payment := range payments {
+		if left.Amount <= 0 {
+			break
+		}
+		if payment.IsRefund() || payment.Status != models.PaymentStatusCaptured || payment.Provider != helper.Payments.Name() || payment.Provider_reference == "" {
+			continue
+		}
+
+		available := payment.Amount.Amount - alreadyRefunded[payment.Payment_id]
+		if available <= 0 {
+			continue
+		}
+		refundAmount := models.NewMoney(min(available, left.Amount), left.Currency)
+
+		result, err := helper.Payments.Refund(ctx, payment.Provider_reference, refundAmount)
+		if err != nil {
+			return refunds, err
+		}
+		if result.Status != helper.ProviderApproved {
+			return refunds, &refundDeclinedError{"the provider declined the refund: " + result.Reason}
+		}
+
+		refund := newRefund(refundAmount)
+		refund.Provider = payment.Provider
+		refund.Provider_reference = payment.Provider_reference
+		refund.Refunded_payment_id = payment.Payment_id
+		if err := h.Payments.Create(ctx, refund); err != nil {
+			return refunds, err
+		}
+		refunds = append(refunds, refund)
+		left = models.NewMoney(left.Amount-refundAmount.Amount, left.Currency)
+	}
+
+	if left.Amount > 0 {
+		return refunds, &refundDeclinedError{fmt.Sprintf("%s could not be refunded to a card", left)}
+	}
+	return refunds, nil
+}
+
+// invoiceCreditNotes returns the credit notes of an invoice, oldest first.
+func (h *Handler) invoiceCreditNotes(ctx context.Context, invoiceId string) ([]models.CreditNote, error) {
+	creditNotes, err := h.CreditNotes.ListByInvoice(ctx, invoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if creditNotes == nil {
+		creditNotes = []models.CreditNote{}
+	}
+	return creditNotes, nil
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The DELETE endpoints soft delete: the document is marked with who deleted
+// it and when, and disappears from lists and lookups. An admin can restore
+// it, or purge it to remove it for good. Each resource passes the checks
+// that keep references intact.
+
+// deleteHandler soft deletes the document named by the path parameter once
+// unused reports nothing still depends on it.
+func deleteHandler(name string, param string, documents interface {
+	Delete(ctx context.Context, id string, deletedBy string) error
+}, unused func(ctx context.Context, id string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		id := c.Param(param)
+		if unused != nil {
+			if err := unused(ctx, id); err != nil {
+				abort(c, err)
+				return
+			}
+		}
+
+		if err := documents.Delete(ctx, id, c.GetString("uid")); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound(name+" not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while deleting the "+name, err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": name + " deleted successfully", param: id})
+	}
+}
+
+// restoreHandler brings a deleted document back once restorable reports
+// that what it refers to is still there.
+func restoreHandler[T any](name string, param string, documents repository.SoftDeleter[T], restorable func(ctx context.Context, document T) error) gin.HandlerFunc {
+	return restoreViewHandler(name, param, documents, restorable, func(document T) T { return document })
+}
+
+// restoreViewHandler is restoreHandler for documents that are shown through
+// a view rather than as stored.
+func restoreViewHandler[T any, V any](name string, param string, documents repository.SoftDeleter[T], restorable func(ctx context.Context, document T) error, view func(document T) V) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		id := c.Param(param)
+		deleted, err := documents.GetDeleted(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("no deleted "+name+" with that id"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the "+name, err))
+			return
+		}
+		if restorable != nil {
+			if err := restorable(ctx, deleted); err != nil {
+				abort(c, err)
+				return
+			}
+		}
+
+		restored, err := documents.Restore(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.Conflict("the "+name+" was restored or purged meanwhile"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while restoring the "+name, err))
+			return
+		}
+		c.JSON(http.StatusOK, view(restored))
+	}
+}
+
+// purgeHandler removes a deleted document for good once unreferenced
+// reports nothing live refers to it anymore. Documents that are not deleted
+// have to be deleted first.
+func purgeHandler(name string, param string, documents interface {
+	Purge(ctx context.Context, id string) error
+}, unreferenced func(ctx context.Context, id string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		id := c.Param(param)
+		if unreferenced != nil {
+			if err := unreferenced(ctx, id); err != nil {
+				abort(c, err)
+				return
+			}
+		}
+
+		if err := documents.Purge(ctx, id); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("no deleted "+name+" with that id, delete it before purging"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while purging the "+name, err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": name + " purged", param: id})
+	}
+}
+
+// stillThere answers a restore whose parent is gone with a conflict.
+func stillThere(err error, parent string) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return helper.Conflict("the " + parent + " it belongs to is deleted, restore the " + parent + " first")
+	}
+	return helper.Internal("error occurred while fetching the "+parent, err)
+}
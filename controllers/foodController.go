@@ -2,124 +2,90 @@ package controller
 
 import(
 	"context"
-	"math"
+	"errors"
 	"net/http"
-	"strconv"
 	"time"
 
-	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-var validate = validator.New()
+var foodList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "menu_id"},
+		{Param: "station"},
+		{Param: "name"},
+		{Param: "price", Field: "price.amount", Kind: helper.FilterMoney},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "updated_at": "updated_at", "name": "name", "price": "price.amount"},
+}
 
-func GetFoods() gin.HandlerFunc {
+func (h *Handler) GetFoods() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel() // Ensure context is canceled to free resources
-
-		// Retrieve query parameters with defaults
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
-		}
-
-		page, pageErr := strconv.Atoi(c.Query("page"))
-		if pageErr != nil || page < 1 {
-			page = 1
-		}
-
-		startIndex := (page - 1) * recordPerPage
-
-		// Define aggregation stages
-		matchStage := bson.D{{Key: "$match", Value: bson.D{}}}
-		groupStage := bson.D{
-			{"$group", bson.D{
-				{"_id", nil}, // Group all documents together
-				{"total_count", bson.D{{"$sum", 1}}},
-				{"data", bson.D{{"$push", "$$ROOT"}}},
-			}},
-		}
-		projectStage := bson.D{
-			{"$project", bson.D{
-				{"_id", 0},
-				{"total_count", 1},
-				{"food_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
-			}},
-		}
-
-		// Execute the aggregation
-		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage,
-		})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
 
+		params, err := helper.ParseList(c.Request.URL, foodList)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing food items"})
-			return // Exit the handler if there's an error
-		}
-
-		// Create a value into which the single document can be decoded
-		var allFoods []bson.M
-		if err = result.All(ctx, &allFoods); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while processing results"})
-			return // Exit the handler if there's an error
+			abort(c, err)
+			return
 		}
 
-		if len(allFoods) == 0 {
-			c.JSON(http.StatusOK, []bson.M{}) // Return an empty list if no items are found
+		items, total, err := h.Foods.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing food items", err))
 			return
 		}
-		c.JSON(http.StatusOK, allFoods)
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
 	}
 }
 
-func GetFood() gin.HandlerFunc {
+func (h *Handler) GetFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var food models.Food
-
-		err := foodCollection.FindOne(ctx, bson.M{"food_id": c.Query("food_id")}).Decode(&food)
+		food, err := h.Foods.Get(ctx, c.Param("food_id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the food item"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Food not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the food item", err))
 			return
 		}
 		c.JSON(http.StatusOK, food)
 	}
 }
 
-func CreateFood() gin.HandlerFunc {
+func (h *Handler) CreateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled to free resources
 
-		var menu models.Menu
 		var food models.Food
 
 		// Bind JSON to food struct
-		if err := c.BindJSON(&food); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&food); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Validate the food struct
 		if validationErr := validate.Struct(food); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			abort(c, helper.Invalid(validationErr))
 			return
 		}
 
 		// Check if the menu exists
-		if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "menu not found"})
+		if _, err := h.Menus.Get(ctx, *food.Menu_id); err != nil {
+			abort(c, helper.NotFound("menu not found"))
 			return
 		}
 
@@ -130,99 +96,125 @@ func CreateFood() gin.HandlerFunc {
 		food.Created_at = now
 		food.Updated_at = now
 
-		// Format price to two decimal places
-		num := toFixed(*food.Price, 2)
-		food.Price = &num
+		// Prices are exact amounts, but they still have to be positive
+		if food.Price.Amount <= 0 {
+			abort(c, helper.BadRequest("price must be greater than zero"))
+			return
+		}
+		if food.Price.Currency != models.DefaultCurrency {
+			abort(c, helper.BadRequest("prices must be in the restaurant currency "+models.DefaultCurrency))
+			return
+		}
 
 		// Insert the food item into the database
-		result, insertErr := foodCollection.InsertOne(ctx, food)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Food item was not created"})
+		if insertErr := h.Foods.Create(ctx, food); insertErr != nil {
+			abort(c, helper.Internal("Food item was not created", insertErr))
 			return
 		}
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": food.ID})
 	}
 }
 
-func UpdateFood() gin.HandlerFunc {
+func (h *Handler) UpdateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var menu models.Menu
 		var food models.Food
 
-		// Get the food_id from query parameters and validate
-		foodId := c.Query("food_id")
-		if foodId == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "food_id is required"})
-			return
-		}
+		foodId := c.Param("food_id")
 
 		// Bind the JSON request body to the food struct
-		if err := c.BindJSON(&food); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		if err := c.ShouldBindJSON(&food); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Dynamically build the update object
-		var updateObj primitive.D
+		updateObj := bson.M{}
 
 		if food.Name != nil {
-			updateObj = append(updateObj, bson.E{"name", *food.Name})
+			updateObj["name"] = *food.Name
 		}
 		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{"price", *food.Price})
+			if food.Price.Amount <= 0 {
+				abort(c, helper.BadRequest("price must be greater than zero"))
+				return
+			}
+			if food.Price.Currency != models.DefaultCurrency {
+				abort(c, helper.BadRequest("prices must be in the restaurant currency "+models.DefaultCurrency))
+				return
+			}
+			updateObj["price"] = *food.Price
 		}
 		if food.Food_image != nil {
-			updateObj = append(updateObj, bson.E{"food_image", *food.Food_image})
+			updateObj["food_image"] = *food.Food_image
+		}
+		if food.Station != nil {
+			updateObj["station"] = *food.Station
 		}
 
 		// Check if the menu exists if menu_id is provided
 		if food.Menu_id != nil {
-			if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			if _, err := h.Menus.Get(ctx, *food.Menu_id); err != nil {
+				abort(c, helper.NotFound("Menu not found"))
 				return
 			}
-			updateObj = append(updateObj, bson.E{"menu_id", *food.Menu_id})
+			updateObj["menu_id"] = *food.Menu_id
 		}
 
 		// Ensure at least one field is being updated
 		if len(updateObj) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			abort(c, helper.BadRequest("No fields to update"))
 			return
 		}
 
 		// Always update the updated_at field
-		updateObj = append(updateObj, bson.E{"updated_at", time.Now()})
+		updateObj["updated_at"] = time.Now()
 
-		// Define the filter and update options
-		filter := bson.M{"food_id": foodId}
-		upsert := true
-		opt := options.Update().SetUpsert(upsert)
-
-		// Perform the update operation
-		result, err := foodCollection.UpdateOne(ctx, filter, bson.D{{"$set", updateObj}}, opt)
+		updated, err := h.Foods.Update(ctx, foodId, updateObj)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Food item update failed", "details": err.Error()})
-			return
-		}
-
-		if result.MatchedCount == 0 && result.UpsertedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Food not found"))
+				return
+			}
+			abort(c, helper.Internal("Food item update failed", err))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Food updated successfully", "result": result})
+		c.JSON(http.StatusOK, gin.H{"message": "Food updated successfully", "food": updated})
 	}
 }
 
+// DeleteFood needs no reference check: ordered items keep the price they
+// were ordered at, and past orders still find the deleted food by its id.
+func (h *Handler) DeleteFood() gin.HandlerFunc {
+	return deleteHandler("food", "food_id", h.Foods, nil)
+}
 
-func round(num float64) int {
-	return int(num + math.Copysign(0.5, num))
+func (h *Handler) RestoreFood() gin.HandlerFunc {
+	return restoreHandler("food", "food_id", h.Foods, func(ctx context.Context, food models.Food) error {
+		if food.Menu_id == nil {
+			return nil
+		}
+		if _, err := h.Menus.Get(ctx, *food.Menu_id); err != nil {
+			return stillThere(err, "menu")
+		}
+		return nil
+	})
 }
 
-func toFixed(num float64, precision int) float64 {
-	output := math.Pow(10, float64(precision))
-	return float64(round(num*output)) / output
-}
\ No newline at end of file
+// PurgeFood keeps a food that order items still refer to, since orders are
+// shown and invoiced with its name and the category of its menu.
+func (h *Handler) PurgeFood() gin.HandlerFunc {
+	return purgeHandler("food", "food_id", h.Foods, func(ctx context.Context, foodId string) error {
+		count, err := h.OrderItems.CountByFood(ctx, foodId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the order items of the food", err)
+		}
+		if count > 0 {
+			return helper.Conflict("order items still refer to the food")
+		}
+		return nil
+	})
+}
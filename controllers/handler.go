@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+// newValidator reports fields by their JSON names, which is what clients send.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Handler serves the API over the repositories it was built with.
+type Handler struct {
+	repository.Repositories
+}
+
+func New(repos repository.Repositories) *Handler {
+	return &Handler{Repositories: repos}
+}
+
+// abort records the error for middleware.Errors to render and skips the
+// remaining handlers.
+func abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
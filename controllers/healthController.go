@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness answers as long as the process can serve requests at all.
+func (h *Handler) Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readiness reports whether the service can take traffic, which is when the
+// database answers. The configuration was validated at startup. The probe
+// needs no login, so why the database failed goes to the log only.
+func (h *Handler) Readiness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		checks := gin.H{"mongo": "ok"}
+		ready := true
+		if err := h.Ping(ctx); err != nil {
+			log.Printf("readiness: mongo did not answer: %v", err)
+			checks["mongo"] = "unreachable"
+			ready = false
+		}
+
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+	}
+}
@@ -2,124 +2,274 @@ package controller
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
-	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type InvoiceViewFormat struct {
 	Invoice_id       string
-	Payment_method   string
+	Invoice_number   string
 	Order_id         string
 	Payment_status   *string
 	Payment_due      interface{}
+	Amount_credited  models.Money
+	Amount_paid      models.Money
+	Amount_refunded  models.Money
+	Tip_total        models.Money
+	Amount_due       models.Money
 	Table_number     interface{}
+	Issued_at        time.Time
 	Payment_due_date time.Time
 	Order_details    interface{}
+	Breakdown        *models.InvoiceBreakdown
+	Split            *models.InvoiceSplit
+	Payments         []models.Payment
+	Credit_notes     []models.CreditNote
 }
 
-var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
+var invoiceList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "order_id"},
+		{Param: "invoice_number"},
+		{Param: "payment_status"},
+		{Param: "total", Field: "total.amount", Kind: helper.FilterMoney},
+		{Param: "amount_due", Field: "amount_due.amount", Kind: helper.FilterMoney},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts:  map[string]string{"created_at": "created_at", "invoice_number": "invoice_number", "total": "total.amount", "amount_due": "amount_due.amount"},
+	Keyset: true,
+}
 
-func GetInvoices() gin.HandlerFunc {
+func (h *Handler) GetInvoices() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel() // Ensure context is canceled to free resources
-
-		// Get pagination parameters from query
-		pageStr := c.Query("page")
-		limitStr := c.Query("limit")
-
-		// Set default values for page and limit
-		page := 1
-		limit := 10
+		defer cancel() // Ensure context is canceled
 
-		// Parse page and limit, with error handling
-		if pageParam, err := strconv.Atoi(pageStr); err == nil && pageParam > 0 {
-			page = pageParam
+		params, err := helper.ParseList(c.Request.URL, invoiceList)
+		if err != nil {
+			abort(c, err)
+			return
 		}
-		if limitParam, err := strconv.Atoi(limitStr); err == nil && limitParam > 0 {
-			limit = limitParam
+
+		items, total, err := h.Invoices.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing invoices", err))
+			return
 		}
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
+	}
+}
 
-		// Calculate skip
-		skip := (page - 1) * limit
+func (h *Handler) GetInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
 
-		// Query invoices with pagination
-		cursor, err := invoiceCollection.Find(ctx, bson.M{}, options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)))
+		invoice, err := h.findInvoice(ctx, c.Param("invoice_id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred while listing invoice items"})
-			return
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Invoice not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return // Early return on error
 		}
-		defer cursor.Close(ctx) // Ensure cursor is closed
 
-		var invoices []bson.M
-		if err = cursor.All(ctx, &invoices); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred while reading invoice items"})
-			log.Fatal(err)
+		invoiceView, err := h.BuildInvoiceView(ctx, invoice)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while preparing the invoice", err))
 			return
 		}
 
-		// Return the paginated invoices
-		c.JSON(http.StatusOK, invoices)
+		c.JSON(http.StatusOK, invoiceView)
 	}
 }
 
-func GetInvoice() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel() // Ensure context is canceled
+// findInvoice loads an invoice by its id or, since printed receipts carry
+// it, by its invoice number.
+func (h *Handler) findInvoice(ctx context.Context, invoiceId string) (models.Invoice, error) {
+	return h.Invoices.FindByReference(ctx, invoiceId)
+}
 
-		invoiceId := c.Query("invoice_id")
-		var invoice models.Invoice
+// BuildInvoiceView assembles the order details and payments of an invoice.
+// Issued invoices show the lines and totals stored when they were created;
+// older invoices without a stored breakdown are priced with the current rules.
+func (h *Handler) BuildInvoiceView(ctx context.Context, invoice models.Invoice) (InvoiceViewFormat, error) {
+	var invoiceView InvoiceViewFormat
+	allOrderItems, err := h.ItemsByOrder(ctx, invoice.Order_id)
+	if err != nil {
+		return invoiceView, err
+	}
+
+	payments, err := h.invoicePayments(ctx, invoice.Invoice_id)
+	if err != nil {
+		return invoiceView, err
+	}
+	creditNotes, err := h.invoiceCreditNotes(ctx, invoice.Invoice_id)
+	if err != nil {
+		return invoiceView, err
+	}
 
-		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+	invoiceView.Order_id = invoice.Order_id
+	invoiceView.Issued_at = invoice.Created_at
+	invoiceView.Payment_due_date = invoice.Payment_due_date
+	invoiceView.Invoice_id = invoice.Invoice_id
+	invoiceView.Invoice_number = invoice.Invoice_number
+	invoiceView.Payment_status = invoice.Payment_status
+	invoiceView.Split = invoice.Split
+	invoiceView.Payments = payments
+	invoiceView.Credit_notes = creditNotes
+
+	// Ensure allOrderItems has elements before accessing
+	var orderItems []OrderItemView
+	if len(allOrderItems) > 0 {
+		orderItems = itemsOnInvoice(allOrderItems[0].Order_items, invoice.Order_item_ids)
+		invoiceView.Table_number = allOrderItems[0].Table_number
+		invoiceView.Order_details = orderItems
+	} else {
+		invoiceView.Payment_due = nil
+		invoiceView.Table_number = nil
+		invoiceView.Order_details = nil
+	}
+
+	if invoice.Breakdown == nil && len(allOrderItems) > 0 {
+		rules, err := h.loadPricingRules(ctx)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
-			return // Early return on error
+			return invoiceView, err
+		}
+		breakdown, err := helper.PriceLines(pricingLinesFor(orderItems), rules, invoice.Discounts)
+		if err != nil {
+			return invoiceView, err
 		}
+		invoice.Breakdown = &breakdown
+		invoice.Total = breakdown.Total
+		if invoice.Amount_due, err = breakdown.Total.CheckedSub(invoice.Amount_paid); err != nil {
+			return invoiceView, err
+		}
+	}
+	if invoice.Breakdown != nil {
+		invoiceView.Breakdown = invoice.Breakdown
+		invoiceView.Payment_due = invoice.Total
+		invoiceView.Amount_credited = invoice.Amount_credited
+		invoiceView.Amount_paid = invoice.Amount_paid
+		invoiceView.Amount_refunded = invoice.Amount_refunded
+		invoiceView.Tip_total = invoice.Tip_total
+		invoiceView.Amount_due = invoice.Amount_due
+		invoiceView.Order_details = issuedLines(*invoice.Breakdown, orderItems)
+	}
+	if invoice.Table_number != nil {
+		invoiceView.Table_number = invoice.Table_number
+	}
+	return invoiceView, nil
+}
 
-		var invoiceView InvoiceViewFormat
-		allOrderItems, err := ItemsByOrder(invoice.Order_id)
+// priceOrder works out the breakdown of an order, or of the given items of
+// it, with the current pricing rules and returns the table it was served at.
+func (h *Handler) priceOrder(ctx context.Context, orderId string, orderItemIds []string, discounts []models.Discount) (models.InvoiceBreakdown, *int, error) {
+	allOrderItems, err := h.ItemsByOrder(ctx, orderId)
+	if err != nil {
+		return models.InvoiceBreakdown{}, nil, err
+	}
+	rules, err := h.loadPricingRules(ctx)
+	if err != nil {
+		return models.InvoiceBreakdown{}, nil, err
+	}
 
-		invoiceView.Order_id = invoice.Order_id
-		invoiceView.Payment_due_date = invoice.Payment_due_date
+	var orderItems []OrderItemView
+	var tableNumber *int
+	if len(allOrderItems) > 0 {
+		orderItems = itemsOnInvoice(allOrderItems[0].Order_items, orderItemIds)
+		tableNumber = allOrderItems[0].Table_number
+	}
+	breakdown, err := helper.PriceLines(pricingLinesFor(orderItems), rules, discounts)
+	return breakdown, tableNumber, err
+}
+
+// itemsOnInvoice keeps the order items billed on an invoice. Invoices that
+// are not split by item cover every item of the order.
+func itemsOnInvoice(orderItems []OrderItemView, orderItemIds []string) []OrderItemView {
+	if len(orderItemIds) == 0 {
+		return orderItems
+	}
+	onInvoice := map[string]bool{}
+	for _, id := range orderItemIds {
+		onInvoice[id] = true
+	}
 
-		// Handle Payment_method safely
-		if invoice.Payment_method != nil {
-			invoiceView.Payment_method = *invoice.Payment_method
-		} else {
-			invoiceView.Payment_method = "null"
+	var kept []OrderItemView
+	for _, item := range orderItems {
+		if onInvoice[item.Order_item_id] {
+			kept = append(kept, item)
 		}
+	}
+	return kept
+}
 
-		invoiceView.Invoice_id = invoice.Invoice_id
-		invoiceView.Payment_status = *&invoice.Payment_status
+// issuedLines renders the lines of the breakdown an invoice was issued with,
+// so the invoice keeps showing what it billed when the food is renamed or
+// recategorised later on. The image, portion and notes of an item are taken
+// from the order while the item is still there.
+func issuedLines(breakdown models.InvoiceBreakdown, orderItems []OrderItemView) []OrderItemView {
+	current := map[string]OrderItemView{}
+	for _, item := range orderItems {
+		current[item.Order_item_id] = item
+	}
 
-		// Ensure allOrderItems has elements before accessing
-		if len(allOrderItems) > 0 {
-			invoiceView.Payment_due = allOrderItems[0]["payment_due"]
-			invoiceView.Table_number = allOrderItems[0]["table_number"]
-			invoiceView.Order_details = allOrderItems[0]["order_items"]
-		} else {
-			invoiceView.Payment_due = nil
-			invoiceView.Table_number = nil
-			invoiceView.Order_details = nil
+	lines := make([]OrderItemView, 0, len(breakdown.Lines))
+	for _, priced := range breakdown.Lines {
+		name, category := priced.Name, priced.Category
+		quantity := int(priced.Quantity)
+		price, amount := priced.Unit_price, priced.Line_total
+
+		line := current[priced.Order_item_id]
+		line.Order_item_id = priced.Order_item_id
+		line.Food_name = &name
+		line.Category = &category
+		line.Quantity = &quantity
+		line.Price = &price
+		line.Amount = &amount
+		if line.Notes == nil {
+			line.Notes = []models.Note{}
 		}
+		lines = append(lines, line)
+	}
+	return lines
+}
 
-		c.JSON(http.StatusOK, invoiceView)
+// pricingLinesFor turns the items of an order into pricing engine lines.
+func pricingLinesFor(orderItems []OrderItemView) []helper.PricingLine {
+	lines := make([]helper.PricingLine, 0, len(orderItems))
+	for _, item := range orderItems {
+		line := helper.PricingLine{Order_item_id: item.Order_item_id, Quantity: 1}
+		if item.Quantity != nil {
+			line.Quantity = int64(*item.Quantity)
+		}
+		if item.Food_name != nil {
+			line.Name = *item.Food_name
+		}
+		if item.Category != nil {
+			line.Category = *item.Category
+		}
+		if item.Price != nil {
+			line.Unit_price = *item.Price
+		}
+		lines = append(lines, line)
 	}
+	return lines
 }
 
-func CreateInvoice() gin.HandlerFunc {
+func (h *Handler) CreateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
@@ -127,52 +277,347 @@ func CreateInvoice() gin.HandlerFunc {
 		var invoice models.Invoice
 
 		// Bind JSON input to the invoice struct
-		if err := c.BindJSON(&invoice); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&invoice); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Check if the associated order exists
-		var order models.Order
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		if _, err := h.Orders.Get(ctx, invoice.Order_id); err != nil {
+			abort(c, helper.NotFound("Order not found"))
 			return
 		}
 
-		// Set default payment status if not provided
-		if invoice.Payment_status == nil {
-			status := "PENDING"
-			invoice.Payment_status = &status
+		issued := newInvoice(invoice.Order_id)
+		issued.Discounts = invoice.Discounts
+
+		// Validate the invoice struct
+		if validationErr := validate.Struct(issued); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+		if err := validateDiscounts(issued.Discounts); err != nil {
+			abort(c, helper.Invalid(err))
+			return
 		}
 
-		// Set timestamps
-		now := time.Now()
-		invoice.Payment_due_date = now.AddDate(0, 0, 1) // Due date is 1 day from now
-		invoice.Created_at = now
-		invoice.Updated_at = now
-		invoice.ID = primitive.NewObjectID()
-		invoice.Invoice_id = invoice.ID.Hex()
+		err := h.Transaction(ctx, func(ctx context.Context) error {
+			if err := h.claimOrderForInvoicing(ctx, issued.Order_id); err != nil {
+				return err
+			}
+
+			// Fix the totals at the moment the invoice is issued
+			breakdown, tableNumber, err := h.priceOrder(ctx, issued.Order_id, nil, issued.Discounts)
+			if err != nil {
+				return helper.Internal("error occurred while pricing the order", err)
+			}
+			if len(breakdown.Lines) == 0 {
+				return helper.Conflict("the order has no items to invoice")
+			}
+			issued.Table_number = tableNumber
+			setInvoiceTotal(&issued, breakdown, breakdown.Total)
+
+			// Number and store the invoice
+			if err := h.issueInvoices(ctx, []models.Invoice{issued}); err != nil {
+				return helper.Internal("Invoice could not be created", err)
+			}
+			return nil
+		})
+		if err != nil {
+			abort(c, err)
+			return
+		}
 
-		// Validate the invoice struct
-		if validationErr := validate.Struct(invoice); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		// Return the id of the new invoice
+		c.JSON(http.StatusOK, gin.H{"InsertedID": issued.ID})
+	}
+}
+
+// SplitOrder issues several invoices for one order, either one per group of
+// order items or one equal share per guest.
+func (h *Handler) SplitOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var body struct {
+			Mode      string            `json:"mode" validate:"required,eq=ITEMS|eq=EVEN"`
+			Groups    [][]string        `json:"groups"`
+			Guests    int               `json:"guests"`
+			Discounts []models.Discount `json:"discounts"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+		if validationErr := validate.Struct(body); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+		if err := validateDiscounts(body.Discounts); err != nil {
+			abort(c, helper.Invalid(err))
 			return
 		}
 
-		// Insert the invoice into the collection
-		result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invoice could not be created"})
+		orderId := c.Param("order_id")
+		if _, err := h.Orders.Get(ctx, orderId); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Order not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while checking the order", err))
 			return
 		}
 
-		// Return the result of the insertion
-		c.JSON(http.StatusOK, result)
+		var invoices []models.Invoice
+		err := h.Transaction(ctx, func(ctx context.Context) error {
+			if err := h.claimOrderForInvoicing(ctx, orderId); err != nil {
+				return err
+			}
+
+			var splitErr error
+			if body.Mode == models.SplitEvenly {
+				invoices, splitErr = h.splitEvenly(ctx, orderId, body.Guests, body.Discounts)
+			} else {
+				invoices, splitErr = h.splitByItems(ctx, orderId, body.Groups, body.Discounts)
+			}
+			if splitErr != nil {
+				var badSplit *invalidSplitError
+				if errors.As(splitErr, &badSplit) {
+					return helper.Invalid(splitErr)
+				}
+				return helper.Internal("error occurred while pricing the order", splitErr)
+			}
+
+			if err := h.issueInvoices(ctx, invoices); err != nil {
+				return helper.Internal("Invoices could not be created", err)
+			}
+			return nil
+		})
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, invoices)
+	}
+}
+
+// invalidSplitError is a split request that does not add up.
+type invalidSplitError struct {
+	message string
+}
+
+func (e *invalidSplitError) Error() string {
+	return e.message
+}
+
+// splitEvenly prices the whole order once and shares the total between the
+// guests. The cents that do not divide evenly go to the first guests.
+func (h *Handler) splitEvenly(ctx context.Context, orderId string, guests int, discounts []models.Discount) ([]models.Invoice, error) {
+	if guests < 2 || guests > 100 {
+		return nil, &invalidSplitError{"guests must be between 2 and 100"}
+	}
+
+	breakdown, tableNumber, err := h.priceOrder(ctx, orderId, nil, discounts)
+	if err != nil {
+		return nil, err
+	}
+	if len(breakdown.Lines) == 0 {
+		return nil, &invalidSplitError{"the order has no items to split"}
+	}
+
+	share := breakdown.Total.Amount / int64(guests)
+	remainder := breakdown.Total.Amount % int64(guests)
+
+	invoices := make([]models.Invoice, 0, guests)
+	for guest := 1; guest <= guests; guest++ {
+		amount := share
+		if int64(guest) <= remainder {
+			amount++
+		}
+
+		invoice := newInvoice(orderId)
+		invoice.Discounts = discounts
+		invoice.Table_number = tableNumber
+		invoice.Split = &models.InvoiceSplit{Guest: guest, Guests: guests}
+		setInvoiceTotal(&invoice, breakdown, models.NewMoney(amount, breakdown.Total.Currency))
+		invoices = append(invoices, invoice)
+	}
+	return invoices, nil
+}
+
+// splitByItems prices every group of order items on its own invoice. Each
+// item of the order must be in exactly one group.
+func (h *Handler) splitByItems(ctx context.Context, orderId string, groups [][]string, discounts []models.Discount) ([]models.Invoice, error) {
+	if len(groups) < 2 {
+		return nil, &invalidSplitError{"a split needs at least two groups of items"}
+	}
+	for _, discount := range discounts {
+		if discount.Type == models.DiscountAmount {
+			return nil, &invalidSplitError{"amount discounts cannot be shared between split invoices"}
+		}
+	}
+
+	allOrderItems, err := h.ItemsByOrder(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	remaining := map[string]bool{}
+	if len(allOrderItems) > 0 {
+		for _, item := range allOrderItems[0].Order_items {
+			remaining[item.Order_item_id] = true
+		}
+	}
+
+	for _, group := range groups {
+		if len(group) == 0 {
+			return nil, &invalidSplitError{"split groups cannot be empty"}
+		}
+		for _, id := range group {
+			if !remaining[id] {
+				return nil, &invalidSplitError{fmt.Sprintf("order item %s is not on the order or is in more than one group", id)}
+			}
+			delete(remaining, id)
+		}
+	}
+	if len(remaining) > 0 {
+		return nil, &invalidSplitError{"every order item must be in one of the groups"}
+	}
+
+	invoices := make([]models.Invoice, 0, len(groups))
+	for _, group := range groups {
+		breakdown, tableNumber, err := h.priceOrder(ctx, orderId, group, discounts)
+		if err != nil {
+			return nil, err
+		}
+
+		invoice := newInvoice(orderId)
+		invoice.Order_item_ids = group
+		invoice.Discounts = discounts
+		invoice.Table_number = tableNumber
+		setInvoiceTotal(&invoice, breakdown, breakdown.Total)
+		invoices = append(invoices, invoice)
+	}
+	return invoices, nil
+}
+
+// newInvoice returns an unpaid invoice for the order, due in a day.
+func newInvoice(orderId string) models.Invoice {
+	now := time.Now()
+	status := models.InvoiceStatusUnpaid
+
+	var invoice models.Invoice
+	invoice.Order_id = orderId
+	invoice.Payment_status = &status
+	invoice.Payment_due_date = now.AddDate(0, 0, 1) // Due date is 1 day from now
+	invoice.Created_at = now
+	invoice.Updated_at = now
+	invoice.ID = primitive.NewObjectID()
+	invoice.Invoice_id = invoice.ID.Hex()
+	return invoice
+}
+
+// issueInvoices numbers the invoices and stores them in one transaction, so
+// a number is only used up by an invoice that was stored and the sequence
+// has no gaps. Without a transaction, numbers of invoices that failed to be
+// stored are handed back, which fails only when later numbers were taken
+// meanwhile.
+func (h *Handler) issueInvoices(ctx context.Context, invoices []models.Invoice) error {
+	return h.Transaction(ctx, func(ctx context.Context) error {
+		if err := h.numberInvoices(ctx, invoices); err != nil {
+			return fmt.Errorf("numbering the invoices: %w", err)
+		}
+		return h.Invoices.Create(ctx, invoices...)
+	})
+}
+
+// numberInvoices gives the invoices consecutive numbers from the sequence of
+// the restaurant and the current fiscal year.
+func (h *Handler) numberInvoices(ctx context.Context, invoices []models.Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+	fiscalYear := models.FiscalYear(invoices[0].Created_at)
+	sequence := models.InvoiceNumberSequence(models.RestaurantId, fiscalYear)
+	count := int64(len(invoices))
+	first, err := h.Counters.Next(ctx, sequence, count)
+	if err != nil {
+		return err
+	}
+	repository.Undo(ctx, func(ctx context.Context) error {
+		released, err := h.Counters.Release(ctx, sequence, first, count)
+		if err == nil && !released {
+			err = fmt.Errorf("later numbers were taken meanwhile")
+		}
+		if err != nil {
+			return fmt.Errorf("invoice numbers %d to %d of %s are lost: %w", first, first+count-1, sequence, err)
+		}
+		return nil
+	})
+	for i := range invoices {
+		invoice := &invoices[i]
+		invoice.Restaurant_id = models.RestaurantId
+		invoice.Invoice_number = models.FormatInvoiceNumber(fiscalYear, first+int64(i))
+	}
+	return nil
+}
+
+// setInvoiceTotal stores the issued breakdown and the amount the invoice asks for.
+func setInvoiceTotal(invoice *models.Invoice, breakdown models.InvoiceBreakdown, total models.Money) {
+	zero := models.NewMoney(0, total.Currency)
+	invoice.Breakdown = &breakdown
+	invoice.Total = total
+	invoice.Amount_credited = zero
+	invoice.Amount_paid = zero
+	invoice.Amount_refunded = zero
+	invoice.Tip_total = zero
+	invoice.Amount_due = total
+	status := models.InvoicePaymentStatus(total, zero, zero)
+	invoice.Payment_status = &status
+}
+
+// claimOrderForInvoicing makes sure an order is billed only once. It claims
+// the order before it is priced, so of two requests invoicing it at the same
+// time the second gets a conflict, and hands the claim back when the
+// invoices are not stored. Orders invoiced before claims existed are told
+// apart by their invoices.
+func (h *Handler) claimOrderForInvoicing(ctx context.Context, orderId string) error {
+	if _, err := h.Orders.ClaimForInvoicing(ctx, orderId); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return h.unclaimable(ctx, orderId)
+		}
+		return helper.Internal("error occurred while claiming the order for invoicing", err)
+	}
+	repository.Undo(ctx, func(ctx context.Context) error {
+		return h.Orders.ReleaseInvoicing(ctx, orderId)
+	})
+
+	count, err := h.Invoices.CountByOrder(ctx, orderId)
+	if err != nil {
+		return helper.Internal("error occurred while checking the order invoices", err)
+	}
+	if count > 0 {
+		return helper.Conflict("the order has already been invoiced")
 	}
+	return nil
 }
 
-func UpdateInvoice() gin.HandlerFunc {
+// unclaimable tells why an order could not be claimed for invoicing.
+func (h *Handler) unclaimable(ctx context.Context, orderId string) error {
+	order, err := h.Orders.Get(ctx, orderId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return helper.NotFound("Order not found")
+		}
+		return helper.Internal("error occurred while checking the order", err)
+	}
+	if !order.Invoiced {
+		return helper.Conflict(fmt.Sprintf("a %s order cannot be invoiced", strings.ToLower(order.Status)))
+	}
+	return helper.Conflict("the order has already been invoiced")
+}
+
+func (h *Handler) UpdateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
@@ -181,46 +626,112 @@ func UpdateInvoice() gin.HandlerFunc {
 		invoiceId := c.Param("invoice_id")
 
 		// Bind JSON input to the invoice struct
-		if err := c.BindJSON(&invoice); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&invoice); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
-		filter := bson.M{"invoice_id": invoiceId}
-		updateObj := primitive.D{}
+		updateObj := bson.M{}
 
-		// Build update object based on provided fields
-		if invoice.Payment_method != nil {
-			updateObj = append(updateObj, bson.E{"payment_method", invoice.Payment_method})
+		// The status follows the payment ledger
+		if invoice.Payment_status != nil {
+			abort(c, helper.BadRequest("payment_status is derived from the recorded payments"))
+			return
 		}
 
-		if invoice.Payment_status != nil {
-			updateObj = append(updateObj, bson.E{"payment_status", invoice.Payment_status})
+		// Totals are fixed once issued, corrections go through a new invoice
+		if invoice.Discounts != nil || invoice.Breakdown != nil || !invoice.Total.IsZero() {
+			abort(c, helper.Conflict("invoice totals cannot be changed once issued"))
+			return
+		}
+
+		// Build update object based on provided fields
+		if !invoice.Payment_due_date.IsZero() {
+			updateObj["payment_due_date"] = invoice.Payment_due_date
 		}
 
 		// Set updated_at timestamp
 		invoice.Updated_at = time.Now()
-		updateObj = append(updateObj, bson.E{"updated_at", invoice.Updated_at})
-
-		// Prepare options for the update operation
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
+		updateObj["updated_at"] = invoice.Updated_at
 
 		// Perform the update operation
-		result, err := invoiceCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{{"$set", updateObj}},
-			&opt,
-		)
+		updated, err := h.Invoices.Update(ctx, invoiceId, updateObj)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invoice update failed"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Invoice not found"))
+				return
+			}
+			abort(c, helper.Internal("Invoice update failed", err))
 			return
 		}
 
-		// Return the result of the update operation
-		c.JSON(http.StatusOK, result)
+		// Return the updated invoice
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteInvoice refuses numbered invoices, which belong to the fiscal
+// sequence, and invoices with payments on their ledger. Those are settled
+// with a credit note instead. Only invoices issued before numbering existed
+// can be deleted.
+func (h *Handler) DeleteInvoice() gin.HandlerFunc {
+	return deleteHandler("invoice", "invoice_id", h.Invoices, func(ctx context.Context, invoiceId string) error {
+		invoice, err := h.Invoices.Get(ctx, invoiceId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return helper.NotFound("invoice not found")
+			}
+			return helper.Internal("error occurred while fetching the invoice", err)
+		}
+		if invoice.Invoice_number != "" {
+			return helper.Conflict("invoice " + invoice.Invoice_number + " is numbered and has to be kept, issue a credit note instead")
+		}
+		payments, err := h.Payments.ListByInvoice(ctx, invoiceId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the payments of the invoice", err)
+		}
+		if len(payments) > 0 {
+			return helper.Conflict("the invoice has payments, issue a credit note instead")
+		}
+		return nil
+	})
+}
+
+// RestoreInvoice needs the order back, and no new invoice issued for it
+// since this one was deleted. Invoices of the same split stay restorable.
+func (h *Handler) RestoreInvoice() gin.HandlerFunc {
+	return restoreHandler("invoice", "invoice_id", h.Invoices, func(ctx context.Context, invoice models.Invoice) error {
+		if _, err := h.Orders.Get(ctx, invoice.Order_id); err != nil {
+			return stillThere(err, "order")
+		}
+		reissued, err := h.Invoices.CountByOrderSince(ctx, invoice.Order_id, *invoice.Deleted_at)
+		if err != nil {
+			return helper.Internal("error occurred while checking the invoices of the order", err)
+		}
+		if reissued > 0 {
+			return helper.Conflict("the order was invoiced again after this invoice was deleted")
+		}
+		return nil
+	})
+}
+
+// PurgeInvoice never removes a numbered invoice, even one deleted before
+// numbered invoices were kept, so the fiscal sequence has no holes.
+func (h *Handler) PurgeInvoice() gin.HandlerFunc {
+	purgeInvoice := purgeHandler("invoice", "invoice_id", h.Invoices, nil)
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoice, err := h.Invoices.GetDeleted(ctx, c.Param("invoice_id"))
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return
+		}
+		if err == nil && invoice.Invoice_number != "" {
+			abort(c, helper.Conflict("invoice "+invoice.Invoice_number+" is numbered and has to be kept"))
+			return
+		}
+		purgeInvoice(c)
 	}
 }
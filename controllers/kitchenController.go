@@ -0,0 +1,408 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (h *Handler) GetKitchenTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		// Screens only care about tickets still being worked on unless asked otherwise
+		statuses := []string{models.KitchenStatusPending, models.KitchenStatusInProgress}
+		if status := c.Query("status"); status != "" {
+			statuses = []string{status}
+		}
+
+		// Oldest tickets first, the way the kitchen works through them
+		allTickets, err := h.KitchenTickets.List(ctx, statuses, c.Query("station"))
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing kitchen tickets", err))
+			return
+		}
+		if allTickets == nil {
+			allTickets = []models.KitchenTicket{}
+		}
+		if err := h.attachKitchenNotes(ctx, allTickets); err != nil {
+			abort(c, helper.Internal("error occurred while retrieving kitchen ticket notes", err))
+			return
+		}
+		c.JSON(http.StatusOK, allTickets)
+	}
+}
+
+func (h *Handler) GetKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		ticket, err := h.KitchenTickets.Get(ctx, c.Param("ticket_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("kitchen ticket not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the kitchen ticket", err))
+			return
+		}
+
+		tickets := []models.KitchenTicket{ticket}
+		if err := h.attachKitchenNotes(ctx, tickets); err != nil {
+			abort(c, helper.Internal("error occurred while retrieving kitchen ticket notes", err))
+			return
+		}
+		c.JSON(http.StatusOK, tickets[0])
+	}
+}
+
+// StreamKitchenTickets pushes ticket events to a kitchen screen as Server-Sent Events.
+func (h *Handler) StreamKitchenTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		events := helper.Kitchen.Subscribe(c.Query("station"))
+		defer helper.Kitchen.Unsubscribe(events)
+
+		// Keep idle connections open through proxies
+		keepAlive := time.NewTicker(15 * time.Second)
+		defer keepAlive.Stop()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				c.SSEvent(event.Type, event.Ticket)
+				return true
+			case <-keepAlive.C:
+				c.SSEvent("ping", time.Now().Unix())
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// BumpKitchenTicket moves every item on a ticket to the requested status.
+func (h *Handler) BumpKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.bumpKitchenItems(c, "")
+	}
+}
+
+// BumpKitchenTicketItem moves a single item on a ticket to the requested status.
+func (h *Handler) BumpKitchenTicketItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.bumpKitchenItems(c, c.Param("order_item_id"))
+	}
+}
+
+func (h *Handler) bumpKitchenItems(c *gin.Context, orderItemId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel() // Ensure context is canceled
+
+	var body struct {
+		Status *string `json:"status" validate:"required,eq=PENDING|eq=IN_PROGRESS|eq=READY"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		abort(c, helper.InvalidBody(err))
+		return
+	}
+	if validationErr := validate.Struct(body); validationErr != nil {
+		abort(c, helper.Invalid(validationErr))
+		return
+	}
+
+	ticket, bumped, err := h.bumpTicket(ctx, c.Param("ticket_id"), orderItemId, *body.Status)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	now := ticket.Updated_at
+
+	// Feed the preparation status back into the order items
+	if err := h.OrderItems.SetStatus(ctx, bumped, *body.Status, now); err != nil {
+		abort(c, helper.Internal("order item status update failed", err))
+		return
+	}
+
+	tickets := []models.KitchenTicket{ticket}
+	if err := h.attachKitchenNotes(ctx, tickets); err != nil {
+		log.Printf("could not load notes for kitchen ticket %s: %v", ticket.Ticket_id, err)
+	}
+	ticket = tickets[0]
+
+	helper.Kitchen.Publish(helper.KitchenEvent{Type: "updated", Ticket: ticket})
+
+	if ticket.Status == models.KitchenStatusReady {
+		h.markOrderReadyIfComplete(ctx, ticket.Order_id, c.GetString("uid"))
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// bumpTicket moves the items of a ticket, or the one order item when
+// orderItemId is set, to the status and returns the ticket with the ids of
+// the items it moved. Cancelled items stay cancelled.
+func (h *Handler) bumpTicket(ctx context.Context, ticketId string, orderItemId string, status string) (models.KitchenTicket, []string, error) {
+	var bumped []string
+	ticket, err := h.changeTicket(ctx, ticketId, func(ticket *models.KitchenTicket) error {
+		bumped = nil
+		for i := range ticket.Items {
+			item := &ticket.Items[i]
+			if orderItemId != "" && item.Order_item_id != orderItemId {
+				continue
+			}
+			if item.Status == models.KitchenStatusCancelled {
+				if orderItemId != "" {
+					return helper.Conflict("the order item was cancelled")
+				}
+				continue
+			}
+			item.Status = status
+			bumped = append(bumped, item.Order_item_id)
+		}
+		if len(bumped) == 0 {
+			if orderItemId == "" {
+				return helper.Conflict("every item on the kitchen ticket was cancelled")
+			}
+			return helper.NotFound("order item is not on this kitchen ticket")
+		}
+		return nil
+	})
+	return ticket, bumped, err
+}
+
+// changeTicket lets change edit the items of a ticket and stores them with
+// the status they add up to. Cooks bump the same ticket at the same time, so
+// a ticket that changed after it was read is read again and changed on top
+// of that change instead of overwriting it.
+func (h *Handler) changeTicket(ctx context.Context, ticketId string, change func(ticket *models.KitchenTicket) error) (models.KitchenTicket, error) {
+	for attempt := 1; ; attempt++ {
+		ticket, err := h.KitchenTickets.Get(ctx, ticketId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ticket, helper.NotFound("kitchen ticket not found")
+			}
+			return ticket, helper.Internal("error occurred while fetching the kitchen ticket", err)
+		}
+		if err := change(&ticket); err != nil {
+			return ticket, err
+		}
+
+		// updated_at is what tells the versions apart, it has to move on even
+		// when the last change was in the same millisecond
+		readAt := ticket.Updated_at
+		now := time.Now().Truncate(time.Millisecond)
+		if !now.After(readAt) {
+			now = readAt.Add(time.Millisecond)
+		}
+		update := bson.M{
+			"items":      ticket.Items,
+			"status":     kitchenTicketStatus(ticket.Items),
+			"updated_at": now,
+		}
+		updated, err := h.KitchenTickets.Update(ctx, ticket.Ticket_id, readAt, update)
+		switch {
+		case err == nil:
+			return updated, nil
+		case !errors.Is(err, repository.ErrNotFound):
+			return ticket, helper.Internal("kitchen ticket update failed", err)
+		case attempt == 5:
+			return ticket, helper.Conflict("the kitchen ticket keeps changing, try again")
+		}
+	}
+}
+
+// reviseKitchenItem applies a change of an order item to the tickets it is
+// on, then shows and prints those tickets again.
+func (h *Handler) reviseKitchenItem(ctx context.Context, orderItemId string, revise func(item *models.KitchenTicketItem)) error {
+	tickets, err := h.KitchenTickets.ListByOrderItem(ctx, orderItemId)
+	if err != nil {
+		return err
+	}
+	changed := make([]models.KitchenTicket, 0, len(tickets))
+	for _, ticket := range tickets {
+		updated, err := h.changeTicket(ctx, ticket.Ticket_id, func(ticket *models.KitchenTicket) error {
+			for i := range ticket.Items {
+				if ticket.Items[i].Order_item_id == orderItemId {
+					revise(&ticket.Items[i])
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		changed = append(changed, updated)
+	}
+	h.announceChangedKitchenTickets(ctx, changed)
+	return nil
+}
+
+// cancelKitchenTickets takes every item of an order off the kitchen screens.
+func (h *Handler) cancelKitchenTickets(ctx context.Context, orderId string) error {
+	tickets, err := h.KitchenTickets.ListByOrder(ctx, orderId)
+	if err != nil {
+		return err
+	}
+	changed := make([]models.KitchenTicket, 0, len(tickets))
+	for _, ticket := range tickets {
+		if ticket.Status == models.KitchenStatusCancelled {
+			continue
+		}
+		updated, err := h.changeTicket(ctx, ticket.Ticket_id, func(ticket *models.KitchenTicket) error {
+			for i := range ticket.Items {
+				ticket.Items[i].Status = models.KitchenStatusCancelled
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		changed = append(changed, updated)
+	}
+	h.announceChangedKitchenTickets(ctx, changed)
+	return nil
+}
+
+// newKitchenTickets splits freshly ordered items into one ticket per
+// station. foods holds the food of every item; nothing is stored yet.
+func newKitchenTickets(order models.Order, orderItems []models.OrderItem, foods map[string]models.Food, tableNumber *int) []models.KitchenTicket {
+	// Keep stations in the order they first appear on the order
+	var stations []string
+	itemsByStation := map[string][]models.KitchenTicketItem{}
+	for _, orderItem := range orderItems {
+		food := foods[*orderItem.Food_id]
+		station := models.DefaultKitchenStation
+		if food.Station != nil && *food.Station != "" {
+			station = *food.Station
+		}
+
+		foodName := ""
+		if food.Name != nil {
+			foodName = *food.Name
+		}
+
+		if _, seen := itemsByStation[station]; !seen {
+			stations = append(stations, station)
+		}
+		itemsByStation[station] = append(itemsByStation[station], models.KitchenTicketItem{
+			Order_item_id: orderItem.Order_item_id,
+			Food_id:       *orderItem.Food_id,
+			Food_name:     foodName,
+			Portion_size:  orderItem.Portion_size,
+			Quantity:      orderItem.Quantity,
+			Status:        models.KitchenStatusPending,
+		})
+	}
+
+	now := time.Now()
+	tickets := make([]models.KitchenTicket, 0, len(stations))
+	for _, station := range stations {
+		var ticket models.KitchenTicket
+		ticket.ID = primitive.NewObjectID()
+		ticket.Ticket_id = ticket.ID.Hex()
+		ticket.Created_at = now
+		ticket.Updated_at = now
+		ticket.Order_id = order.Order_id
+		ticket.Table_id = order.Table_id
+		ticket.Table_number = tableNumber
+		ticket.Station = station
+		ticket.Status = models.KitchenStatusPending
+		ticket.Items = itemsByStation[station]
+
+		tickets = append(tickets, ticket)
+	}
+	return tickets
+}
+
+// announceKitchenTickets shows stored tickets on the kitchen screens and
+// prints them at their stations.
+func announceKitchenTickets(tickets []models.KitchenTicket) {
+	for _, ticket := range tickets {
+		helper.Kitchen.Publish(helper.KitchenEvent{Type: "created", Ticket: ticket})
+	}
+	printNewKitchenTickets(tickets)
+}
+
+// announceChangedKitchenTickets shows changed tickets on the kitchen screens
+// and prints them again at their stations.
+func (h *Handler) announceChangedKitchenTickets(ctx context.Context, tickets []models.KitchenTicket) {
+	if err := h.attachKitchenNotes(ctx, tickets); err != nil {
+		log.Printf("could not load notes for the changed kitchen tickets: %v", err)
+	}
+	for _, ticket := range tickets {
+		helper.Kitchen.Publish(helper.KitchenEvent{Type: "updated", Ticket: ticket})
+	}
+	printChangedKitchenTickets(tickets)
+}
+
+// kitchenTicketStatus derives a ticket's status from its items. Cancelled
+// items do not count, unless every item is cancelled.
+func kitchenTicketStatus(items []models.KitchenTicketItem) string {
+	live := 0
+	ready := 0
+	started := false
+	for _, item := range items {
+		switch item.Status {
+		case models.KitchenStatusCancelled:
+			continue
+		case models.KitchenStatusReady:
+			ready++
+			started = true
+		case models.KitchenStatusInProgress:
+			started = true
+		}
+		live++
+	}
+
+	switch {
+	case live == 0:
+		return models.KitchenStatusCancelled
+	case ready == live:
+		return models.KitchenStatusReady
+	case started:
+		return models.KitchenStatusInProgress
+	default:
+		return models.KitchenStatusPending
+	}
+}
+
+// markOrderReadyIfComplete moves a fired order to READY once every one of its
+// kitchen tickets is ready.
+func (h *Handler) markOrderReadyIfComplete(ctx context.Context, orderId string, changedBy string) {
+	pending, err := h.KitchenTickets.CountNotReady(ctx, orderId)
+	if err != nil || pending > 0 {
+		return
+	}
+
+	order, err := h.Orders.Get(ctx, orderId)
+	if err != nil {
+		return
+	}
+	if order.Status != models.OrderStatusFired {
+		return
+	}
+
+	if _, err := h.ChangeOrderStatus(ctx, orderId, models.OrderStatusReady, changedBy, "all kitchen tickets are ready"); err != nil {
+		log.Printf("could not mark order %s as ready: %v", orderId, err)
+	}
+}
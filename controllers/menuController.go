@@ -2,71 +2,79 @@ package controller
 
 import(
 	"context"
-	"log"
+	"errors"
 	"net/http"
 	"time"
 
-	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
+var menuList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "category"},
+		{Param: "name"},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "updated_at": "updated_at", "name": "name", "category": "category"},
+}
 
-func GetMenus() gin.HandlerFunc {
+func (h *Handler) GetMenus() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel() // Ensure context is canceled to free resources
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
 
-		result, err := menuCollection.Find(context.TODO(), bson.M{})
+		params, err := helper.ParseList(c.Request.URL, menuList)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing the menu items"})
+			abort(c, err)
 			return
 		}
 
-		var allMenus []bson.M
-		if err = result.All(ctx, &allMenus); err != nil {
-			log.Fatal(err)
+		items, total, err := h.Menus.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing menus", err))
 			return
 		}
-		c.JSON(http.StatusOK, allMenus)
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
 	}
 }
 
-func GetMenu() gin.HandlerFunc {
+func (h *Handler) GetMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var menu models.Menu
-
-		err := foodCollection.FindOne(ctx, bson.M{"menu_id": c.Query("menu_id")}).Decode(&menu)
+		menu, err := h.Menus.Get(ctx, c.Param("menu_id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching the menu"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Menu not found"))
+				return
+			}
+			abort(c, helper.Internal("error occured while fetching the menu", err))
 			return
 		}
 		c.JSON(http.StatusOK, menu)
 	}
 }
 
-func CreateMenu() gin.HandlerFunc {
+func (h *Handler) CreateMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
 		var menu models.Menu
-		if err := c.BindJSON(&menu); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&menu); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		validationErr := validate.Struct(menu)
 		if validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			abort(c, helper.Invalid(validationErr))
 			return
 		}
 
@@ -76,16 +84,15 @@ func CreateMenu() gin.HandlerFunc {
 		menu.Created_at = now
 		menu.Updated_at = now
 
-		newMenu, insertErr := menuCollection.InsertOne(ctx, menu)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating a menu"})
+		if insertErr := h.Menus.Create(ctx, menu); insertErr != nil {
+			abort(c, helper.Internal("error occured while creating a menu", insertErr))
 			return
 		}
-		c.JSON(http.StatusOK, newMenu)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": menu.ID})
 	}
 }
 
-func UpdateMenu() gin.HandlerFunc {
+func (h *Handler) UpdateMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled to free resources
@@ -93,51 +100,47 @@ func UpdateMenu() gin.HandlerFunc {
 		var menu models.Menu
 
 		// Bind JSON to menu struct
-		if err := c.BindJSON(&menu); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&menu); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		menuId := c.Param("menu_id")
-		filter := bson.M{"menu_id": menuId} // Fixed typo from "manuu_id" to "menu_id"
-
-		var updateObj primitive.D
 
 		// Validate date range
 		if menu.Start_Date != nil && menu.End_Date != nil {
 			if !inTimeSpan(*menu.Start_Date, *menu.End_Date, time.Now()) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Kindly retype the time"})
+				abort(c, helper.BadRequest("Kindly retype the time"))
 				return
 			}
 
-			updateObj = append(updateObj, bson.E{"start_date", menu.Start_Date}, bson.E{"end_date", menu.End_Date})
+			updateObj := bson.M{"start_date": menu.Start_Date, "end_date": menu.End_Date}
 
 			// Add optional fields to updateObj
 			if menu.Name != "" {
-				updateObj = append(updateObj, bson.E{"name", menu.Name})
+				updateObj["name"] = menu.Name
 			}
 			if menu.Category != "" {
-				updateObj = append(updateObj, bson.E{"category", menu.Category}) // Fixed field name
+				updateObj["category"] = menu.Category
 			}
 
 			// Set updated_at timestamp directly
 			menu.Updated_at = time.Now()
-			updateObj = append(updateObj, bson.E{"updated_at", menu.Updated_at})
-
-			// Define upsert option
-			upsert := true
-			opt := options.Update().SetUpsert(upsert)
+			updateObj["updated_at"] = menu.Updated_at
 
-			// Perform the update operation
-			result, err := menuCollection.UpdateOne(ctx, filter, bson.D{{"$set", updateObj}}, opt)
+			updated, err := h.Menus.Update(ctx, menuId, updateObj)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Menu update failed"})
+				if errors.Is(err, repository.ErrNotFound) {
+					abort(c, helper.NotFound("Menu not found"))
+					return
+				}
+				abort(c, helper.Internal("Menu update failed", err))
 				return
 			}
 
-			c.JSON(http.StatusOK, result)
+			c.JSON(http.StatusOK, updated)
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Start and End dates must be provided"})
+			abort(c, helper.BadRequest("Start and End dates must be provided"))
 		}
 	}
 }
@@ -146,3 +149,35 @@ func inTimeSpan(start, end, check time.Time) bool {
 	return check.After(start) && check.Before(end)
 }
 
+// DeleteMenu refuses menus that still have foods on them.
+func (h *Handler) DeleteMenu() gin.HandlerFunc {
+	return deleteHandler("menu", "menu_id", h.Menus, func(ctx context.Context, menuId string) error {
+		count, err := h.Foods.CountByMenu(ctx, menuId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the foods of the menu", err)
+		}
+		if count > 0 {
+			return helper.Conflict("the menu still has foods, delete or move them first")
+		}
+		return nil
+	})
+}
+
+func (h *Handler) RestoreMenu() gin.HandlerFunc {
+	return restoreHandler[models.Menu]("menu", "menu_id", h.Menus, nil)
+}
+
+// PurgeMenu keeps a menu that foods still refer to, deleted ones included,
+// since its category decides the tax on their order items.
+func (h *Handler) PurgeMenu() gin.HandlerFunc {
+	return purgeHandler("menu", "menu_id", h.Menus, func(ctx context.Context, menuId string) error {
+		count, err := h.Foods.CountAllByMenu(ctx, menuId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the foods of the menu", err)
+		}
+		if count > 0 {
+			return helper.Conflict("foods still refer to the menu, purge them first")
+		}
+		return nil
+	})
+}
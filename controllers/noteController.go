@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var noteList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "owner_type"},
+		{Param: "owner_id"},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "updated_at": "updated_at"},
+}
+
+func (h *Handler) GetNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		params, err := helper.ParseList(c.Request.URL, noteList)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+
+		items, total, err := h.Notes.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing notes", err))
+			return
+		}
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
+	}
+}
+
+func (h *Handler) GetNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		note, err := h.Notes.Get(ctx, c.Param("note_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("note not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the note", err))
+			return
+		}
+		c.JSON(http.StatusOK, note)
+	}
+}
+
+func (h *Handler) CreateNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var note models.Note
+		if err := c.ShouldBindJSON(&note); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		if validationErr := validate.Struct(note); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		// The note must be attached to something that exists
+		exists, err := h.noteOwnerExists(ctx, note.Owner_type, note.Owner_id)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while checking the note owner", err))
+			return
+		}
+		if !exists {
+			abort(c, helper.NotFound("the document this note is attached to was not found"))
+			return
+		}
+
+		now := time.Now()
+		note.Created_at = now
+		note.Updated_at = now
+		note.ID = primitive.NewObjectID()
+		note.Note_id = note.ID.Hex()
+		note.Created_by = c.GetString("uid")
+
+		if insertErr := h.Notes.Create(ctx, note); insertErr != nil {
+			abort(c, helper.Internal("Note could not be created", insertErr))
+			return
+		}
+
+		h.publishNoteToKitchen(ctx, note)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": note.ID})
+	}
+}
+
+func (h *Handler) UpdateNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var changes models.Note
+		if err := c.ShouldBindJSON(&changes); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		note, ok := h.findEditableNote(ctx, c)
+		if !ok {
+			return
+		}
+
+		// Notes stay attached to their owner, only the wording can change
+		updateObj := bson.M{}
+		if changes.Text != "" {
+			note.Text = changes.Text
+			updateObj["text"] = changes.Text
+		}
+		if changes.Title != "" {
+			note.Title = changes.Title
+			updateObj["title"] = changes.Title
+		}
+		if len(updateObj) == 0 {
+			abort(c, helper.BadRequest("No fields to update"))
+			return
+		}
+
+		if validationErr := validate.Struct(note); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		note.Updated_at = time.Now()
+		updateObj["updated_at"] = note.Updated_at
+
+		if _, err := h.Notes.Update(ctx, note.Note_id, updateObj); err != nil {
+			abort(c, helper.Internal("Note update failed", err))
+			return
+		}
+
+		h.publishNoteToKitchen(ctx, note)
+		c.JSON(http.StatusOK, note)
+	}
+}
+
+func (h *Handler) DeleteNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		note, ok := h.findEditableNote(ctx, c)
+		if !ok {
+			return
+		}
+
+		deleted, err := h.Notes.Delete(ctx, note.Note_id)
+		if err != nil {
+			abort(c, helper.Internal("Note could not be deleted", err))
+			return
+		}
+
+		h.publishNoteToKitchen(ctx, note)
+		c.JSON(http.StatusOK, gin.H{"DeletedCount": deleted})
+	}
+}
+
+// findEditableNote loads the note from the route and checks that the caller
+// wrote it or manages the floor. It replies to the client when it returns false.
+func (h *Handler) findEditableNote(ctx context.Context, c *gin.Context) (models.Note, bool) {
+	note, err := h.Notes.Get(ctx, c.Param("note_id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			abort(c, helper.NotFound("note not found"))
+			return note, false
+		}
+		abort(c, helper.Internal("error occurred while fetching the note", err))
+		return note, false
+	}
+
+	role := c.GetString("role")
+	if note.Created_by != c.GetString("uid") && role != models.RoleAdmin && role != models.RoleManager {
+		abort(c, helper.Forbidden("only the author or a manager can change this note"))
+		return note, false
+	}
+	return note, true
+}
+
+// noteOwnerExists reports whether the document a note is attached to exists.
+func (h *Handler) noteOwnerExists(ctx context.Context, ownerType string, ownerId string) (bool, error) {
+	var err error
+	switch ownerType {
+	case models.NoteOwnerOrder:
+		_, err = h.Orders.Get(ctx, ownerId)
+	case models.NoteOwnerOrderItem:
+		_, err = h.OrderItems.Get(ctx, ownerId)
+	case models.NoteOwnerTable:
+		_, err = h.Tables.Get(ctx, ownerId)
+	default:
+		_, err = h.Invoices.Get(ctx, ownerId)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// publishNoteToKitchen re-sends the kitchen tickets a note belongs to, so
+// screens pick up added, edited and removed notes.
+func (h *Handler) publishNoteToKitchen(ctx context.Context, note models.Note) {
+	var tickets []models.KitchenTicket
+	var err error
+	switch note.Owner_type {
+	case models.NoteOwnerOrder:
+		tickets, err = h.KitchenTickets.ListByOrder(ctx, note.Owner_id)
+	case models.NoteOwnerOrderItem:
+		tickets, err = h.KitchenTickets.ListByOrderItem(ctx, note.Owner_id)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("could not load kitchen tickets for note %s: %v", note.Note_id, err)
+		return
+	}
+	if err := h.attachKitchenNotes(ctx, tickets); err != nil {
+		log.Printf("could not load notes for kitchen tickets: %v", err)
+		return
+	}
+
+	for _, ticket := range tickets {
+		helper.Kitchen.Publish(helper.KitchenEvent{Type: "updated", Ticket: ticket})
+	}
+}
+
+// attachKitchenNotes fills in the order and item notes of the given tickets.
+func (h *Handler) attachKitchenNotes(ctx context.Context, tickets []models.KitchenTicket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	var orderIds, orderItemIds []string
+	for _, ticket := range tickets {
+		orderIds = append(orderIds, ticket.Order_id)
+		for _, item := range ticket.Items {
+			orderItemIds = append(orderItemIds, item.Order_item_id)
+		}
+	}
+
+	orderNotes, err := h.notesByOwner(ctx, models.NoteOwnerOrder, orderIds)
+	if err != nil {
+		return err
+	}
+	itemNotes, err := h.notesByOwner(ctx, models.NoteOwnerOrderItem, orderItemIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		tickets[i].Notes = orderNotes[tickets[i].Order_id]
+		for j := range tickets[i].Items {
+			tickets[i].Items[j].Notes = itemNotes[tickets[i].Items[j].Order_item_id]
+		}
+	}
+	return nil
+}
@@ -4,63 +4,71 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
-	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
+var (
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrIllegalOrderTransition = errors.New("illegal order status transition")
+	ErrOrderStatusConflict    = errors.New("order status was changed by another request")
+)
+
+var orderList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "status"},
+		{Param: "table_id"},
+		{Param: "order_date", Kind: helper.FilterTime},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts:  map[string]string{"created_at": "created_at", "updated_at": "updated_at", "order_date": "order_date", "status": "status"},
+	Keyset: true,
+}
 
-func GetOrders() gin.HandlerFunc {
+func (h *Handler) GetOrders() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
-		// Retrieve all orders from the collection
-		result, err := orderCollection.Find(ctx, bson.M{})
+		params, err := helper.ParseList(c.Request.URL, orderList)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred while listing order items"})
+			abort(c, err)
 			return
 		}
 
-		// Collect all orders into a slice
-		var allOrders []bson.M
-		if err := result.All(ctx, &allOrders); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred while processing order items"})
+		items, total, err := h.Orders.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing orders", err))
 			return
 		}
-
-		// Return the list of orders
-		c.JSON(http.StatusOK, allOrders)
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
 	}
 }
 
-func GetOrder() gin.HandlerFunc {
+func (h *Handler) GetOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
-		orderId := c.Query("order_id")
-		var order models.Order
-
 		// Attempt to find the order by order_id
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+		order, err := h.Orders.Get(ctx, c.Param("order_id"))
 		if err != nil {
 			// Check if the error is due to no documents found
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Order not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred while fetching the order"})
+			abort(c, helper.Internal("Error occurred while fetching the order", err))
 			return
 		}
 
@@ -69,7 +77,7 @@ func GetOrder() gin.HandlerFunc {
 	}
 }
 
-func CreateOrder() gin.HandlerFunc {
+func (h *Handler) CreateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
@@ -77,26 +85,31 @@ func CreateOrder() gin.HandlerFunc {
 		var order models.Order
 
 		// Bind JSON input to the order struct
-		if err := c.BindJSON(&order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&order); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Validate the order struct
 		if validationErr := validate.Struct(order); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			abort(c, helper.Invalid(validationErr))
 			return
 		}
 
 		// Check if the table exists if Table_id is provided
 		if order.Table_id != nil {
-			var table models.Table
-			if err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			if _, err := h.Tables.Get(ctx, *order.Table_id); err != nil {
+				abort(c, helper.NotFound("Table not found"))
 				return
 			}
 		}
 
+		// Every order starts open, whatever the payload says
+		order.Status = models.OrderStatusOpen
+		order.Status_history = []models.OrderStatusChange{
+			newOrderStatusChange("", models.OrderStatusOpen, c.GetString("uid"), ""),
+		}
+
 		// Set created and updated timestamps
 		order.Created_at = time.Now()
 		order.Updated_at = time.Now()
@@ -105,87 +118,212 @@ func CreateOrder() gin.HandlerFunc {
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
 
-		// Insert the order into the collection
-		result, insertErr := orderCollection.InsertOne(ctx, order)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create Order item."})
+		// Store the order
+		if insertErr := h.Orders.Create(ctx, order); insertErr != nil {
+			abort(c, helper.Internal("Unable to create Order item.", insertErr))
 			return
 		}
 
-		// Return the result of the insertion
-		c.JSON(http.StatusOK, result)
+		// Return the id of the new order
+		c.JSON(http.StatusOK, gin.H{"InsertedID": order.ID})
 	}
 }
 
-func UpdateOrder() gin.HandlerFunc {
+func (h *Handler) UpdateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
 		var order models.Order
-		var updateObj primitive.D
+		updateObj := bson.M{}
 
 		// Extract order ID from the request parameters
 		orderId := c.Param("order_id")
 		if orderId == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "order_id is required"})
+			abort(c, helper.BadRequest("order_id is required"))
 			return
 		}
 
 		// Bind JSON body to the order model
-		if err := c.BindJSON(&order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&order); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Check and validate table_id
 		if order.Table_id != nil {
-			var table models.Table
-			err := menuCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			if _, err := h.Tables.Get(ctx, *order.Table_id); err != nil {
+				abort(c, helper.NotFound("Table not found"))
 				return
 			}
-			updateObj = append(updateObj, bson.E{Key: "table_id", Value: order.Table_id})
+			updateObj["table_id"] = order.Table_id
 		}
 
 		// Update the timestamp
 		order.Updated_at = time.Now()
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: order.Updated_at})
-
-		// Prepare the filter and update options
-		filter := bson.M{"order_id": orderId}
-		opts := options.Update().SetUpsert(true)
+		updateObj["updated_at"] = order.Updated_at
 
 		// Perform the update operation
-		result, err := orderCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: updateObj}}, opts)
+		updated, err := h.Orders.Update(ctx, orderId, updateObj)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order update failed"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Order not found"))
+				return
+			}
+			abort(c, helper.Internal("Order update failed", err))
 			return
 		}
 
-		// Return the update result
-		c.JSON(http.StatusOK, gin.H{"result": result})
+		// Return the updated order
+		c.JSON(http.StatusOK, updated)
 	}
 }
 
-func OrderItemOrderCreator(order models.Order) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel() // Ensure context is canceled
-
-	// Set created and updated timestamps
+// newOpenOrder builds an open order for the table, which may be nil for
+// orders that are not served at a table. Nothing is stored yet.
+func newOpenOrder(tableId *string, openedBy string) models.Order {
 	now := time.Now()
+	order := models.Order{
+		Order_Date: now,
+		Table_id:   tableId,
+		Status:     models.OrderStatusOpen,
+		Status_history: []models.OrderStatusChange{
+			newOrderStatusChange("", models.OrderStatusOpen, openedBy, ""),
+		},
+	}
 	order.Created_at = now
 	order.Updated_at = now
-
-	// Generate a new ObjectID and set the order ID
 	order.ID = primitive.NewObjectID()
 	order.Order_id = order.ID.Hex()
+	return order
+}
+
+func (h *Handler) TransitionOrder(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		// The reason is optional, so an empty body is fine
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				abort(c, helper.InvalidBody(err))
+				return
+			}
+		}
+
+		order, err := h.ChangeOrderStatus(ctx, c.Param("order_id"), status, c.GetString("uid"), body.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrOrderNotFound):
+				abort(c, helper.NotFound("Order not found"))
+			case errors.Is(err, ErrIllegalOrderTransition), errors.Is(err, ErrOrderStatusConflict):
+				abort(c, helper.Conflict(err.Error()))
+			default:
+				abort(c, helper.Internal("Order status update failed", err))
+			}
+			return
+		}
+
+		// Nothing of a voided or cancelled order is cooked any more
+		if status == models.OrderStatusVoided || status == models.OrderStatusCancelled {
+			if err := h.cancelKitchenTickets(ctx, order.Order_id); err != nil {
+				log.Printf("could not cancel the kitchen tickets of order %s: %v", order.Order_id, err)
+			}
+		}
 
-	// Insert the order into the collection
-	_, err := orderCollection.InsertOne(ctx, order)
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// ChangeOrderStatus moves an order to a new status if the lifecycle allows it,
+// recording who made the change. The update only applies while the order is
+// still in the status it was read in, so concurrent transitions cannot both win.
+func (h *Handler) ChangeOrderStatus(ctx context.Context, orderId string, to string, changedBy string, reason string) (models.Order, error) {
+	order, err := h.Orders.Get(ctx, orderId)
 	if err != nil {
-		return "", fmt.Errorf("failed to insert order: %w", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			return order, ErrOrderNotFound
+		}
+		return order, err
+	}
+
+	// Orders created before statuses existed are treated as open
+	from := order.Status
+	if from == "" {
+		from = models.OrderStatusOpen
+	}
+
+	if !models.CanTransitionOrder(from, to) {
+		return order, fmt.Errorf("%w: cannot move order from %s to %s", ErrIllegalOrderTransition, from, to)
+	}
+
+	change := newOrderStatusChange(from, to, changedBy, reason)
+	updated, err := h.Orders.Transition(ctx, orderId, order.Status, change)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return order, ErrOrderStatusConflict
+		}
+		return order, err
+	}
+	return updated, nil
+}
+
+func newOrderStatusChange(from string, to string, changedBy string, reason string) models.OrderStatusChange {
+	return models.OrderStatusChange{
+		From:       from,
+		To:         to,
+		Changed_by: changedBy,
+		Changed_at: time.Now(),
+		Reason:     reason,
+	}
+}
+
+// DeleteOrder refuses orders that were invoiced or still have items, which
+// are deleted one by one first.
+func (h *Handler) DeleteOrder() gin.HandlerFunc {
+	return deleteHandler("order", "order_id", h.Orders, func(ctx context.Context, orderId string) error {
+		if err := h.checkNotInvoiced(ctx, orderId); err != nil {
+			return err
+		}
+		orderItems, err := h.OrderItems.ListByOrder(ctx, orderId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the items of the order", err)
+		}
+		if len(orderItems) > 0 {
+			return helper.Conflict("the order still has items, delete them first")
+		}
+		return nil
+	})
+}
+
+func (h *Handler) RestoreOrder() gin.HandlerFunc {
+	return restoreHandler("order", "order_id", h.Orders, func(ctx context.Context, order models.Order) error {
+		if order.Table_id == nil {
+			return nil
+		}
+		if _, err := h.Tables.Get(ctx, *order.Table_id); err != nil {
+			return stillThere(err, "table")
+		}
+		return nil
+	})
+}
+
+func (h *Handler) PurgeOrder() gin.HandlerFunc {
+	return purgeHandler("order", "order_id", h.Orders, nil)
+}
+
+// checkNotInvoiced refuses changes that would pull the rug from under an
+// issued invoice.
+func (h *Handler) checkNotInvoiced(ctx context.Context, orderId string) error {
+	invoiced, err := h.Invoices.CountByOrder(ctx, orderId)
+	if err != nil {
+		return helper.Internal("error occurred while checking the invoices of the order", err)
+	}
+	if invoiced > 0 {
+		return helper.Conflict("the order has been invoiced, issue a credit note to correct it")
 	}
-	return order.Order_id, nil
+	return nil
 }
@@ -2,238 +2,537 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
-	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// OrderItemPack is an order placed in one go: the table, if any, and its items.
 type OrderItemPack struct {
-	Table_id    *string
-	Order_items []models.OrderItem
+	Table_id    *string            `json:"table_id"`
+	Order_items []models.OrderItem `json:"order_items" validate:"required,min=1,dive"`
 }
 
-var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderItem")
+// OrderItemView is one line of an order as assembled by ItemsByOrder.
+type OrderItemView struct {
+	Order_item_id string        `json:"order_item_id"`
+	Food_name     *string       `json:"food_name"`
+	Food_image    *string       `json:"food_image"`
+	Category      *string       `json:"category"`
+	Portion_size  *string       `json:"portion_size"`
+	Quantity      *int          `json:"quantity"`
+	Price         *models.Money `json:"price"`  // unit price captured when ordered
+	Amount        *models.Money `json:"amount"` // price times quantity
+	Table_number  *int          `json:"table_number"`
+	Table_id      *string       `json:"table_id"`
+	Order_id      *string       `json:"order_id"`
+	Notes         []models.Note `json:"notes"`
+}
+
+// OrderView groups the items of an order with the amount due for them.
+type OrderView struct {
+	Order_id     *string         `json:"order_id"`
+	Table_id     *string         `json:"table_id"`
+	Table_number *int            `json:"table_number"`
+	Payment_due  models.Money    `json:"payment_due"`
+	Total_count  int             `json:"total_count"`
+	Order_items  []OrderItemView `json:"order_items"`
+	Order_notes  []models.Note   `json:"order_notes"`
+	Table_notes  []models.Note   `json:"table_notes"`
+}
+
+var orderItemList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "order_id"},
+		{Param: "food_id"},
+		{Param: "status"},
+		{Param: "portion_size"},
+		{Param: "quantity", Kind: helper.FilterNumber},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts:  map[string]string{"created_at": "created_at", "updated_at": "updated_at", "quantity": "quantity"},
+	Keyset: true,
+}
 
-func GetOrderItems() gin.HandlerFunc {
+func (h *Handler) GetOrderItems() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
-		// Find all order items
-		result, err := orderItemCollection.Find(ctx, bson.M{})
+		params, err := helper.ParseList(c.Request.URL, orderItemList)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ordered items"})
+			abort(c, err)
 			return
 		}
 
-		// Retrieve all order items into a slice
-		var allOrderItems []bson.M
-		if err := result.All(ctx, &allOrderItems); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while retrieving order items"})
+		items, total, err := h.OrderItems.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing ordered items", err))
 			return
 		}
-
-		// Return the list of order items
-		c.JSON(http.StatusOK, allOrderItems)
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
 	}
 }
 
-func GetOrderItemsByOrder() gin.HandlerFunc {
+func (h *Handler) GetOrderItemsByOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
 		orderId := c.Param("order_id")
 
-		allOrderItems, err := ItemsByOrder(orderId)
+		allOrderItems, err := h.ItemsByOrder(ctx, orderId)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing order items by order ID"})
+			abort(c, helper.Internal("error occurred while listing order items by order ID", err))
 			return
 		}
 		c.JSON(http.StatusOK, allOrderItems)
 	}
 }
 
-func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel() // Ensure context is canceled
-
-	// Define aggregation pipeline stages
-	matchStage := bson.D{{"$match", bson.D{{"order_id", id}}}}
-	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
-	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
-	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
-	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	projectStage := bson.D{
-		{"$project", bson.D{
-			{"id", 0},
-			{"amount", "$food.price"},
-			{"total_count", 1},
-			{"food_name", "$food.name"},
-			{"food_image", "$food.food_image"},
-			{"table_number", "$table.table_number"},
-			{"table_id", "$table.table_id"},
-			{"order_id", "$order.order_id"},
-			{"price", "$food.price"},
-			{"quantity", 1},
-		}}}
-
-	groupStage := bson.D{{"$group", bson.D{
-		{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}}},
-		{"payment_due", bson.D{{"$sum", "$amount"}}},
-		{"total_count", bson.D{{"$sum", 1}}},
-		{"order_items", bson.D{{"$push", "$$ROOT"}}},
-	}}}
-
-	projectStage2 := bson.D{
-		{"$project", bson.D{
-			{"id", 0},
-			{"payment_due", 1},
-			{"total_count", 1},
-			{"table_number", "$_id.table_number"},
-			{"order_items", 1},
-		}}}
-
-	// Execute aggregation pipeline
-	result, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage,
-		lookupStage,
-		unwindStage,
-		lookupOrderStage,
-		unwindOrderStage,
-		lookupTableStage,
-		unwindTableStage,
-		projectStage,
-		groupStage,
-		projectStage2,
-	})
+// ItemsByOrder assembles the items of an order with their food, menu, table
+// and notes. It returns one OrderView, or none when the order has no items.
+func (h *Handler) ItemsByOrder(ctx context.Context, id string) ([]OrderView, error) {
+	orderItems, err := h.OrderItems.ListByOrder(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order items: %w", err)
+	}
+	if len(orderItems) == 0 {
+		return []OrderView{}, nil
+	}
+
+	view := OrderView{
+		Order_items: []OrderItemView{},
+		Order_notes: []models.Note{},
+		Table_notes: []models.Note{},
+	}
 
+	// The order and the table it is served at
+	order, err := h.Orders.Get(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("failed to fetch the order: %w", err)
+	}
+	if err == nil {
+		view.Order_id = &order.Order_id
+		if order.Table_id != nil {
+			table, err := h.Tables.Get(ctx, *order.Table_id)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return nil, fmt.Errorf("failed to fetch the table: %w", err)
+			}
+			if err == nil {
+				view.Table_id = &table.Table_id
+				view.Table_number = table.Table_number
+			}
+		}
+	}
+
+	// Foods and the menus they are on
+	foodIds := []string{}
+	orderItemIds := []string{}
+	for _, orderItem := range orderItems {
+		if orderItem.Food_id != nil {
+			foodIds = append(foodIds, *orderItem.Food_id)
+		}
+		orderItemIds = append(orderItemIds, orderItem.Order_item_id)
+	}
+	foodList, err := h.Foods.GetMany(ctx, foodIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch foods: %w", err)
+	}
+	foods := map[string]models.Food{}
+	menuIds := []string{}
+	for _, food := range foodList {
+		foods[food.Food_id] = food
+		if food.Menu_id != nil {
+			menuIds = append(menuIds, *food.Menu_id)
+		}
+	}
+	menuList, err := h.Menus.GetMany(ctx, menuIds)
 	if err != nil {
-		return nil, fmt.Errorf("aggregation failed: %w", err)
+		return nil, fmt.Errorf("failed to fetch menus: %w", err)
+	}
+	menus := map[string]models.Menu{}
+	for _, menu := range menuList {
+		menus[menu.Menu_id] = menu
 	}
 
-	// Retrieve all order items into the slice
-	if err = result.All(ctx, &OrderItems); err != nil {
-		return nil, fmt.Errorf("failed to decode results: %w", err)
+	itemNotes, err := h.notesByOwner(ctx, models.NoteOwnerOrderItem, orderItemIds)
+	if err != nil {
+		return nil, err
 	}
-	return OrderItems, nil
+
+	// Line totals use the unit price captured at order time and are summed as
+	// integer minor units so the total stays exact
+	for _, orderItem := range orderItems {
+		item := OrderItemView{
+			Order_item_id: orderItem.Order_item_id,
+			Portion_size:  orderItem.Portion_size,
+			Quantity:      orderItem.Quantity,
+			Price:         orderItem.Unit_price,
+			Table_id:      view.Table_id,
+			Table_number:  view.Table_number,
+			Order_id:      view.Order_id,
+			Notes:         itemNotes[orderItem.Order_item_id],
+		}
+		if item.Notes == nil {
+			item.Notes = []models.Note{}
+		}
+		if orderItem.Food_id != nil {
+			if food, ok := foods[*orderItem.Food_id]; ok {
+				item.Food_name = food.Name
+				item.Food_image = food.Food_image
+				if food.Menu_id != nil {
+					if menu, ok := menus[*food.Menu_id]; ok {
+						item.Category = &menu.Category
+					}
+				}
+			}
+		}
+		if orderItem.Unit_price != nil {
+			quantity := 1
+			if orderItem.Quantity != nil {
+				quantity = *orderItem.Quantity
+			}
+			amount := orderItem.Unit_price.Multiply(int64(quantity))
+			item.Amount = &amount
+			if view.Payment_due, err = view.Payment_due.CheckedAdd(amount); err != nil {
+				return nil, fmt.Errorf("totalling the order: %w", err)
+			}
+		}
+		view.Order_items = append(view.Order_items, item)
+	}
+	view.Total_count = len(view.Order_items)
+	if view.Payment_due.Currency == "" {
+		view.Payment_due.Currency = models.DefaultCurrency
+	}
+
+	// Notes on the order itself and on the table it is served at
+	orderNotes, err := h.Notes.ListByOwner(ctx, models.NoteOwnerOrder, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order notes: %w", err)
+	}
+	if orderNotes != nil {
+		view.Order_notes = orderNotes
+	}
+	if view.Table_id != nil {
+		tableNotes, err := h.Notes.ListByOwner(ctx, models.NoteOwnerTable, *view.Table_id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch table notes: %w", err)
+		}
+		if tableNotes != nil {
+			view.Table_notes = tableNotes
+		}
+	}
+	return []OrderView{view}, nil
+}
+
+// notesByOwner returns the notes of the owners of one type, keyed by owner id.
+func (h *Handler) notesByOwner(ctx context.Context, ownerType string, ownerIds []string) (map[string][]models.Note, error) {
+	notes, err := h.Notes.ListByOwners(ctx, ownerType, ownerIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s notes: %w", ownerType, err)
+	}
+	byOwner := map[string][]models.Note{}
+	for _, note := range notes {
+		byOwner[note.Owner_id] = append(byOwner[note.Owner_id], note)
+	}
+	return byOwner, nil
 }
 
-func GetOrderItem() gin.HandlerFunc {
+func (h *Handler) GetOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		orderItemId := c.Query("order_item_id")
-		var orderItem models.OrderItem
-
-		err := orderItemCollection.FindOne(ctx, bson.M{"orderItem_id": orderItemId}).Decode(&orderItem)
+		orderItem, err := h.OrderItems.Get(ctx, c.Param("orderItem_id"))
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "error occured while listing ordered item"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Order item not found"))
+				return
+			}
+			abort(c, helper.Internal("error occured while listing ordered item", err))
 			return
 		}
 		c.JSON(http.StatusOK, orderItem)
 	}
 }
 
-func UpdateOrderItem() gin.HandlerFunc {
+func (h *Handler) UpdateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
 		var orderItem models.OrderItem
-		orderItemId := c.Param("order_item_id")
+		orderItemId := c.Param("orderItem_id")
 
-		filter := bson.M{"order_item_id": orderItemId}
-		updateObj := primitive.D{}
+		if err := c.ShouldBindJSON(&orderItem); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
 
-		// Populate update object based on non-nil fields
+		// The unit price is captured when the item is ordered and never edited directly
 		if orderItem.Unit_price != nil {
-			updateObj = append(updateObj, bson.E{"unit_price", *orderItem.Unit_price})
+			abort(c, helper.BadRequest("unit_price is taken from the food price and cannot be changed"))
+			return
+		}
+
+		// Like deleting it, changing an item of an invoiced order would leave the invoice behind
+		current, err := h.OrderItems.Get(ctx, orderItemId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Order item not found"))
+				return
+			}
+			abort(c, helper.Internal("error occured while fetching the order item", err))
+			return
+		}
+		if err := h.checkNotInvoiced(ctx, current.Order_id); err != nil {
+			abort(c, err)
+			return
+		}
+
+		updateObj := bson.M{}
+
+		// Populate update object based on non-nil fields
+		if orderItem.Portion_size != nil {
+			if err := validate.Var(*orderItem.Portion_size, "eq=S|eq=M|eq=L"); err != nil {
+				abort(c, helper.BadRequest("portion_size must be S, M or L"))
+				return
+			}
+			updateObj["portion_size"] = *orderItem.Portion_size
 		}
 		if orderItem.Quantity != nil {
-			updateObj = append(updateObj, bson.E{"quantity", *orderItem.Quantity})
+			if err := validate.Var(*orderItem.Quantity, "min=1,max=1000"); err != nil {
+				abort(c, helper.BadRequest("quantity must be between 1 and 1000"))
+				return
+			}
+			updateObj["quantity"] = *orderItem.Quantity
 		}
+		var foodName *string
 		if orderItem.Food_id != nil {
-			updateObj = append(updateObj, bson.E{"food_id", *orderItem.Food_id})
+			// A different food is charged at its current price
+			food, err := h.Foods.Get(ctx, *orderItem.Food_id)
+			if err != nil {
+				abort(c, helper.NotFound("Food not found"))
+				return
+			}
+			updateObj["food_id"] = *orderItem.Food_id
+			updateObj["unit_price"] = food.Price
+			foodName = food.Name
 		}
 
+		// The edited item has to be made again
+		updateObj["status"] = models.KitchenStatusPending
+
 		// Update the timestamp
 		orderItem.Updated_at = time.Now()
-		updateObj = append(updateObj, bson.E{"updated_at", orderItem.Updated_at})
-
-		// Set upsert option
-		opt := options.Update().SetUpsert(true)
+		updateObj["updated_at"] = orderItem.Updated_at
 
 		// Execute update
-		result, err := orderItemCollection.UpdateOne(ctx, filter, bson.D{{"$set", updateObj}}, opt)
+		updated, err := h.OrderItems.Update(ctx, orderItemId, updateObj)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order item update failed"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Order item not found"))
+				return
+			}
+			abort(c, helper.Internal("Order item update failed", err))
 			return
 		}
-		c.JSON(http.StatusOK, result)
+
+		err = h.reviseKitchenItem(ctx, orderItemId, func(item *models.KitchenTicketItem) {
+			if updated.Food_id != nil {
+				item.Food_id = *updated.Food_id
+			}
+			if foodName != nil {
+				item.Food_name = *foodName
+			}
+			item.Portion_size = updated.Portion_size
+			item.Quantity = updated.Quantity
+			item.Status = models.KitchenStatusPending
+		})
+		if err != nil {
+			log.Printf("could not update the kitchen tickets of order item %s: %v", orderItemId, err)
+		}
+		c.JSON(http.StatusOK, updated)
 	}
 }
 
-func CreateOrderItem() gin.HandlerFunc {
+// CreateOrderItem places a new order with its items and sends them to the
+// kitchen. The table and every food are checked before anything is written,
+// then the fired order, its items and its kitchen tickets are stored in one
+// transaction, or undone without one, so a failure leaves no order behind
+// and a success is never reported as one.
+func (h *Handler) CreateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
 		var orderItemPack OrderItemPack
-		if err := c.BindJSON(&orderItemPack); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&orderItemPack); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
-		// Initialize order and set date
-		var order models.Order
-		order.Order_Date = time.Now()
+		order := newOpenOrder(orderItemPack.Table_id, c.GetString("uid"))
+		for i := range orderItemPack.Order_items {
+			orderItemPack.Order_items[i].Order_id = order.Order_id
+		}
+		if validationErr := validate.Struct(orderItemPack); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
 
-		orderItemsToBeInserted := []interface{}{}
-		order.Table_id = orderItemPack.Table_id
-		orderId, _ := OrderItemOrderCreator(order)
+		var tableNumber *int
+		if order.Table_id != nil {
+			table, err := h.Tables.Get(ctx, *order.Table_id)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					abort(c, helper.NotFound("Table not found"))
+					return
+				}
+				abort(c, helper.Internal("error occurred while fetching the table", err))
+				return
+			}
+			tableNumber = table.Table_number
+		}
 
-		// Process each order item
+		// Capture the current food prices so later price changes don't rewrite the order
+		foods := map[string]models.Food{}
+		orderItems := make([]models.OrderItem, 0, len(orderItemPack.Order_items))
 		for _, orderItem := range orderItemPack.Order_items {
-			orderItem.Order_id = orderId
-
-			// Validate order item
-			if validationErr := validate.Struct(orderItem); validationErr != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-				return
+			food, seen := foods[*orderItem.Food_id]
+			if !seen {
+				var err error
+				food, err = h.Foods.Get(ctx, *orderItem.Food_id)
+				if err != nil {
+					if errors.Is(err, repository.ErrNotFound) {
+						abort(c, helper.NotFound("Food "+*orderItem.Food_id+" not found"))
+						return
+					}
+					abort(c, helper.Internal("error occurred while fetching the food", err))
+					return
+				}
+				foods[food.Food_id] = food
 			}
 
-			// Prepare order item
 			orderItem.ID = primitive.NewObjectID()
-			orderItem.Created_at = time.Now()
-			orderItem.Updated_at = time.Now()
+			orderItem.Created_at = order.Created_at
+			orderItem.Updated_at = order.Created_at
 			orderItem.Order_item_id = orderItem.ID.Hex()
+			orderItem.Status = models.KitchenStatusPending
+			orderItem.Unit_price = food.Price
+			if orderItem.Quantity == nil {
+				quantity := 1
+				orderItem.Quantity = &quantity
+			}
+			orderItems = append(orderItems, orderItem)
+		}
 
-			// Set unit price with fixed decimal
-			num := toFixed(*orderItem.Unit_price, 2)
-			orderItem.Unit_price = &num
+		// The items go to the kitchen right away, so the order is stored fired
+		order.Status = models.OrderStatusFired
+		order.Status_history = append(order.Status_history,
+			newOrderStatusChange(models.OrderStatusOpen, models.OrderStatusFired, c.GetString("uid"), "sent to kitchen"))
+		tickets := newKitchenTickets(order, orderItems, foods, tableNumber)
 
-			orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+		err := h.Transaction(ctx, func(ctx context.Context) error {
+			if err := h.Orders.Create(ctx, order); err != nil {
+				return fmt.Errorf("inserting the order: %w", err)
+			}
+			repository.Undo(ctx, func(ctx context.Context) error {
+				return h.Orders.Discard(ctx, order.Order_id)
+			})
+			// A failed insert of several items may still have stored some of them
+			repository.Undo(ctx, func(ctx context.Context) error {
+				return h.OrderItems.DiscardByOrder(ctx, order.Order_id)
+			})
+			if err := h.OrderItems.Create(ctx, orderItems...); err != nil {
+				return fmt.Errorf("inserting the order items: %w", err)
+			}
+			repository.Undo(ctx, func(ctx context.Context) error {
+				return h.KitchenTickets.DiscardByOrder(ctx, order.Order_id)
+			})
+			if err := h.KitchenTickets.Create(ctx, tickets...); err != nil {
+				return fmt.Errorf("inserting the kitchen tickets: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			abort(c, helper.Internal("Unable to create the order", err))
+			return
 		}
 
-		// Insert order items
-		insertedOrderItems, err := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
+		announceKitchenTickets(tickets)
+
+		insertedIds := []interface{}{}
+		for _, orderItem := range orderItems {
+			insertedIds = append(insertedIds, orderItem.ID)
+		}
+		c.JSON(http.StatusOK, gin.H{"order": order, "order_items": orderItems, "InsertedIDs": insertedIds})
+	}
+}
+
+// DeleteOrderItem refuses items of an order that was invoiced, and takes
+// deleted items off the kitchen tickets.
+func (h *Handler) DeleteOrderItem() gin.HandlerFunc {
+	deleteOrderItem := deleteHandler("order item", "orderItem_id", h.OrderItems, func(ctx context.Context, orderItemId string) error {
+		orderItem, err := h.OrderItems.Get(ctx, orderItemId)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert order items"})
-			return
+			if errors.Is(err, repository.ErrNotFound) {
+				return helper.NotFound("order item not found")
+			}
+			return helper.Internal("error occurred while fetching the order item", err)
+		}
+		return h.checkNotInvoiced(ctx, orderItem.Order_id)
+	})
+	return func(c *gin.Context) {
+		deleteOrderItem(c)
+		if !c.IsAborted() {
+			h.setKitchenItemStatus(c.Param("orderItem_id"), models.KitchenStatusCancelled)
 		}
-		c.JSON(http.StatusOK, insertedOrderItems)
 	}
 }
+
+// RestoreOrderItem puts restored items back on the kitchen tickets, to be
+// made again.
+func (h *Handler) RestoreOrderItem() gin.HandlerFunc {
+	restoreOrderItem := restoreHandler("order item", "orderItem_id", h.OrderItems, func(ctx context.Context, orderItem models.OrderItem) error {
+		if _, err := h.Orders.Get(ctx, orderItem.Order_id); err != nil {
+			return stillThere(err, "order")
+		}
+		return h.checkNotInvoiced(ctx, orderItem.Order_id)
+	})
+	return func(c *gin.Context) {
+		restoreOrderItem(c)
+		if !c.IsAborted() {
+			h.setKitchenItemStatus(c.Param("orderItem_id"), models.KitchenStatusPending)
+		}
+	}
+}
+
+// setKitchenItemStatus moves an order item on the kitchen tickets once the
+// item itself was changed.
+func (h *Handler) setKitchenItemStatus(orderItemId string, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := h.reviseKitchenItem(ctx, orderItemId, func(item *models.KitchenTicketItem) {
+		item.Status = status
+	})
+	if err != nil {
+		log.Printf("could not update the kitchen tickets of order item %s: %v", orderItemId, err)
+	}
+}
+
+func (h *Handler) PurgeOrderItem() gin.HandlerFunc {
+	return purgeHandler("order item", "orderItem_id", h.OrderItems, nil)
+}
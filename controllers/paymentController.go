@@ -0,0 +1,369 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (h *Handler) GetInvoicePayments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		payments, err := h.invoicePayments(ctx, c.Param("invoice_id"))
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing payments", err))
+			return
+		}
+		c.JSON(http.StatusOK, payments)
+	}
+}
+
+// CreatePayment records a payment against an invoice and updates the amount
+// paid, the tips and the derived payment status of the invoice.
+func (h *Handler) CreatePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var payment models.Payment
+		if err := c.ShouldBindJSON(&payment); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+		if validationErr := validate.Struct(payment); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		invoice, err := h.Invoices.Get(ctx, c.Param("invoice_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Invoice not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return
+		}
+		// Invoices issued by older versions get their totals fixed on the first payment
+		if invoice.Breakdown == nil {
+			breakdown, tableNumber, err := h.priceOrder(ctx, invoice.Order_id, invoice.Order_item_ids, invoice.Discounts)
+			if err != nil {
+				abort(c, helper.Internal("error occurred while pricing the order", err))
+				return
+			}
+			invoice.Table_number = tableNumber
+			setInvoiceTotal(&invoice, breakdown, breakdown.Total)
+			update := bson.M{
+				"breakdown":    invoice.Breakdown,
+				"table_number": invoice.Table_number,
+				"total":        invoice.Total,
+			}
+			if _, err := h.Invoices.Update(ctx, invoice.Invoice_id, update); err != nil {
+				abort(c, helper.Internal("Invoice update failed", err))
+				return
+			}
+		}
+
+		currency := invoice.Total.Currency
+		if payment.Amount.Amount <= 0 {
+			abort(c, helper.BadRequest("amount must be greater than zero"))
+			return
+		}
+		if payment.Tip == nil {
+			tip := models.NewMoney(0, currency)
+			payment.Tip = &tip
+		}
+		if payment.Tip.IsNegative() {
+			abort(c, helper.BadRequest("tip cannot be negative"))
+			return
+		}
+		if payment.Amount.Currency != currency || payment.Tip.Currency != currency {
+			abort(c, helper.BadRequest("payments must be in the invoice currency "+currency))
+			return
+		}
+		if *payment.Method == models.PaymentMethodCard && payment.Card_token == "" {
+			abort(c, helper.BadRequest("card payments need a card_token"))
+			return
+		}
+
+		now := time.Now()
+		payment.Created_at = now
+		payment.Updated_at = now
+		payment.Paid_at = now
+		payment.ID = primitive.NewObjectID()
+		payment.Payment_id = payment.ID.Hex()
+		payment.Invoice_id = invoice.Invoice_id
+		payment.Received_by = c.GetString("uid")
+		payment.Type = models.PaymentTypePayment
+		payment.Credit_note_id = ""
+		payment.Refunded_payment_id = ""
+
+		// Cash and vouchers are in hand, cards count once the provider captured
+		// them. A card payment is in the ledger before the card is charged, so
+		// no charge is ever left without its payment.
+		payment.Status = models.PaymentStatusCaptured
+		if *payment.Method == models.PaymentMethodCard {
+			payment.Status = models.PaymentStatusPending
+			payment.Provider = helper.Payments.Name()
+		}
+
+		// Failed attempts stay in the ledger too
+		if err := h.Payments.Create(ctx, payment); err != nil {
+			abort(c, helper.Internal("Payment could not be recorded", err))
+			return
+		}
+
+		if *payment.Method == models.PaymentMethodCard {
+			if err := h.chargeRecordedCard(ctx, &payment); err != nil {
+				abort(c, err)
+				return
+			}
+		}
+
+		invoice, err = h.refreshInvoiceBalance(ctx, invoice)
+		if err != nil {
+			abort(c, helper.Internal("the payment was recorded but the invoice could not be updated", err))
+			return
+		}
+
+		status := http.StatusOK
+		switch payment.Status {
+		case models.PaymentStatusPending:
+			status = http.StatusAccepted
+		case models.PaymentStatusFailed:
+			status = http.StatusPaymentRequired
+		}
+		c.JSON(status, gin.H{"payment": payment, "invoice": invoice})
+	}
+}
+
+// PaymentWebhook receives the callbacks of the payment provider for captures
+// that were still pending. Callbacks are idempotent: a payment that already
+// settled is left alone.
+func (h *Handler) PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		payload, err := c.GetRawData()
+		if err != nil {
+			abort(c, helper.Invalid(err))
+			return
+		}
+		event, err := helper.Payments.ParseWebhook(payload, c.GetHeader("X-Payment-Signature"))
+		if err != nil {
+			if errors.Is(err, helper.ErrInvalidWebhookSignature) {
+				abort(c, helper.Unauthorized(err.Error()))
+				return
+			}
+			abort(c, helper.Invalid(err))
+			return
+		}
+
+		set := bson.M{"updated_at": time.Now()}
+		switch event.Type {
+		case helper.WebhookPaymentCaptured:
+			set["status"] = models.PaymentStatusCaptured
+		case helper.WebhookPaymentFailed:
+			set["status"] = models.PaymentStatusFailed
+			set["failure_reason"] = event.Reason
+		default:
+			c.JSON(http.StatusOK, gin.H{"result": "ignored"})
+			return
+		}
+
+		payment, err := h.Payments.SettlePending(ctx, helper.Payments.Name(), event.Reference, set)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusOK, gin.H{"result": "ignored"})
+				return
+			}
+			abort(c, helper.Internal("error occurred while updating the payment", err))
+			return
+		}
+
+		invoice, err := h.Invoices.Get(ctx, payment.Invoice_id)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return
+		}
+		if _, err := h.refreshInvoiceBalance(ctx, invoice); err != nil {
+			abort(c, helper.Internal("Invoice update failed", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"result": "processed"})
+	}
+}
+
+// chargeRecordedCard charges the card of a payment already in the ledger as
+// pending and records the outcome on it. When the provider cannot be
+// reached before it authorized anything the payment fails; when it cannot
+// be reached later on, the payment stays pending for the webhook to settle.
+func (h *Handler) chargeRecordedCard(ctx context.Context, payment *models.Payment) error {
+	chargeErr := chargeCard(ctx, payment)
+	payment.Card_token = ""
+	if chargeErr != nil && payment.Provider_reference == "" {
+		payment.Status = models.PaymentStatusFailed
+		payment.Failure_reason = "the payment provider could not be reached"
+	}
+
+	payment.Updated_at = time.Now()
+	update := bson.M{
+		"status":             payment.Status,
+		"provider_reference": payment.Provider_reference,
+		"failure_reason":     payment.Failure_reason,
+		"updated_at":         payment.Updated_at,
+	}
+	if _, err := h.Payments.Update(ctx, payment.Payment_id, update); err != nil {
+		log.Printf("payment %s is %s with %s reference %s, but the ledger still has it pending: %v",
+			payment.Payment_id, payment.Status, payment.Provider, payment.Provider_reference, err)
+		return helper.Internal("the outcome of the card payment could not be recorded", err)
+	}
+	if chargeErr != nil {
+		return helper.UpstreamFailed("the payment provider could not be reached", chargeErr)
+	}
+	return nil
+}
+
+// chargeCard authorizes and captures the amount and tip of a card payment
+// and records the outcome on it. A capture that fails releases the
+// authorization again.
+func chargeCard(ctx context.Context, payment *models.Payment) error {
+	total, err := payment.Amount.CheckedAdd(*payment.Tip)
+	if err != nil {
+		return err
+	}
+	payment.Provider = helper.Payments.Name()
+
+	authorization, err := helper.Payments.Authorize(ctx, total, payment.Card_token)
+	if err != nil {
+		return err
+	}
+	payment.Provider_reference = authorization.Reference
+	if authorization.Status != helper.ProviderApproved {
+		payment.Status = models.PaymentStatusFailed
+		payment.Failure_reason = authorization.Reason
+		return nil
+	}
+
+	capture, err := helper.Payments.Capture(ctx, authorization.Reference, total)
+	if err != nil {
+		return err
+	}
+	switch capture.Status {
+	case helper.ProviderApproved:
+		payment.Status = models.PaymentStatusCaptured
+	case helper.ProviderPending:
+		payment.Status = models.PaymentStatusPending
+	default:
+		payment.Status = models.PaymentStatusFailed
+		payment.Failure_reason = capture.Reason
+		if _, err := helper.Payments.Void(ctx, authorization.Reference); err != nil {
+			log.Printf("could not void authorization %s: %v", authorization.Reference, err)
+		}
+	}
+	return nil
+}
+
+// invoicePayments returns the ledger of an invoice, oldest first.
+func (h *Handler) invoicePayments(ctx context.Context, invoiceId string) ([]models.Payment, error) {
+	payments, err := h.Payments.ListByInvoice(ctx, invoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+	return payments, nil
+}
+
+// refreshInvoiceBalance totals the ledger and credit notes of an invoice and
+// stores the amounts credited, paid and refunded, the tips, the amount still
+// due and the derived status. The issued total itself never changes.
+func (h *Handler) refreshInvoiceBalance(ctx context.Context, invoice models.Invoice) (models.Invoice, error) {
+	payments, err := h.invoicePayments(ctx, invoice.Invoice_id)
+	if err != nil {
+		return invoice, err
+	}
+	creditNotes, err := h.invoiceCreditNotes(ctx, invoice.Invoice_id)
+	if err != nil {
+		return invoice, err
+	}
+
+	// A document in another currency fails the refresh instead of the process
+	credited, err := creditedTotal(invoice, creditNotes)
+	if err != nil {
+		return invoice, err
+	}
+	var received, refunded, tips []models.Money
+	for _, payment := range payments {
+		if !payment.CountsTowardsInvoice() {
+			continue
+		}
+		if payment.IsRefund() {
+			refunded = append(refunded, *payment.Amount)
+			continue
+		}
+		received = append(received, *payment.Amount)
+		if payment.Tip != nil {
+			tips = append(tips, *payment.Tip)
+		}
+	}
+	currency := invoice.Total.Currency
+	paid, err := models.SumMoney(currency, received...)
+	if err != nil {
+		return invoice, err
+	}
+	totalRefunded, err := models.SumMoney(currency, refunded...)
+	if err != nil {
+		return invoice, err
+	}
+	totalTips, err := models.SumMoney(currency, tips...)
+	if err != nil {
+		return invoice, err
+	}
+	if paid, err = paid.CheckedSub(totalRefunded); err != nil {
+		return invoice, err
+	}
+	open, err := invoice.Total.CheckedSub(credited)
+	if err != nil {
+		return invoice, err
+	}
+
+	status := models.InvoicePaymentStatus(invoice.Total, credited, paid)
+	invoice.Amount_credited = credited
+	invoice.Amount_paid = paid
+	invoice.Amount_refunded = totalRefunded
+	invoice.Tip_total = totalTips
+	if invoice.Amount_due, err = open.CheckedSub(paid); err != nil {
+		return invoice, err
+	}
+	invoice.Payment_status = &status
+	invoice.Updated_at = time.Now()
+
+	// amount_credited is kept by issueCreditNote, which guards it against
+	// concurrent credit notes; writing it back here could undo a credit that
+	// is being issued.
+	update := bson.M{
+		"amount_paid":     invoice.Amount_paid,
+		"amount_refunded": invoice.Amount_refunded,
+		"tip_total":       invoice.Tip_total,
+		"amount_due":      invoice.Amount_due,
+		"payment_status":  status,
+		"updated_at":      invoice.Updated_at,
+	}
+	_, err = h.Invoices.Update(ctx, invoice.Invoice_id, update)
+	return invoice, err
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (h *Handler) GetPricingRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		rules, err := h.loadPricingRules(ctx)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while fetching the pricing rules", err))
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+func (h *Handler) UpdatePricingRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var rules models.PricingRules
+		if err := c.ShouldBindJSON(&rules); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		if validationErr := validate.Struct(rules); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		existing, err := h.loadPricingRules(ctx)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while fetching the pricing rules", err))
+			return
+		}
+
+		// The rules are a single document that is replaced as a whole
+		now := time.Now()
+		rules.ID = existing.ID
+		if rules.ID.IsZero() {
+			rules.ID = primitive.NewObjectID()
+		}
+		rules.Pricing_id = models.DefaultPricingId
+		rules.Created_at = existing.Created_at
+		if rules.Created_at.IsZero() {
+			rules.Created_at = now
+		}
+		rules.Updated_at = now
+
+		if err := h.Pricing.Save(ctx, rules); err != nil {
+			abort(c, helper.Internal("Pricing rules update failed", err))
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// loadPricingRules returns the stored pricing rules, or rules without any tax
+// or service charge when none were configured yet.
+func (h *Handler) loadPricingRules(ctx context.Context) (models.PricingRules, error) {
+	rules, err := h.Pricing.Get(ctx)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return rules, err
+	}
+	rules.Pricing_id = models.DefaultPricingId
+	if rules.Category_taxes == nil {
+		rules.Category_taxes = []models.CategoryTax{}
+	}
+	return rules, nil
+}
+
+// validateDiscounts checks the parts of a discount the struct tags cannot express.
+func validateDiscounts(discounts []models.Discount) error {
+	for _, discount := range discounts {
+		if err := validate.Struct(discount); err != nil {
+			return err
+		}
+		switch discount.Type {
+		case models.DiscountPercent:
+			if discount.Percent_bps <= 0 {
+				return fmt.Errorf("discount %q needs a percent_bps greater than zero", discount.Name)
+			}
+		case models.DiscountAmount:
+			if discount.Amount == nil || discount.Amount.Amount <= 0 {
+				return fmt.Errorf("discount %q needs an amount greater than zero", discount.Name)
+			}
+			if discount.Amount.Currency != models.DefaultCurrency {
+				return fmt.Errorf("discount %q must be in the restaurant currency %s", discount.Name, models.DefaultCurrency)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/printer"
+	"go-restaurant-management/receipt"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultReceiptPrinter is the printer invoices go to when none is named.
+const DefaultReceiptPrinter = "receipt"
+
+type printRequest struct {
+	Printer string `json:"printer"`
+	Width   int    `json:"width" validate:"omitempty,min=24,max=64"` // characters per line, 48 for 80mm paper
+}
+
+func (h *Handler) GetPrinters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"printers": printer.Printers.Names()})
+	}
+}
+
+func (h *Handler) GetPrintJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := printer.Jobs.Job(c.Param("job_id"))
+		if !ok {
+			abort(c, helper.NotFound("print job not found"))
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// PrintInvoice queues the invoice on a receipt printer.
+func (h *Handler) PrintInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		body, ok := bindPrintRequest(c, DefaultReceiptPrinter)
+		if !ok {
+			return
+		}
+
+		invoice, err := h.findInvoice(ctx, c.Param("invoice_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Invoice not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return
+		}
+		invoiceView, err := h.BuildInvoiceView(ctx, invoice)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while preparing the invoice", err))
+			return
+		}
+
+		submitPrintJob(c, body.Printer, receipt.RenderESCPOS(invoiceDocument(invoiceView), body.Width))
+	}
+}
+
+// PrintKitchenTicket queues a kitchen ticket, on the printer of its station
+// unless another one is named.
+func (h *Handler) PrintKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		ticket, err := h.KitchenTickets.Get(ctx, c.Param("ticket_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("kitchen ticket not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the kitchen ticket", err))
+			return
+		}
+
+		body, ok := bindPrintRequest(c, ticket.Station)
+		if !ok {
+			return
+		}
+
+		tickets := []models.KitchenTicket{ticket}
+		if err := h.attachKitchenNotes(ctx, tickets); err != nil {
+			abort(c, helper.Internal("error occurred while retrieving kitchen ticket notes", err))
+			return
+		}
+
+		submitPrintJob(c, body.Printer, receipt.RenderESCPOS(kitchenDocument(tickets[0]), body.Width))
+	}
+}
+
+// bindPrintRequest reads the optional print options. It replies to the
+// client when it returns false.
+func bindPrintRequest(c *gin.Context, defaultPrinter string) (printRequest, bool) {
+	var body printRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return body, false
+		}
+	}
+	if validationErr := validate.Struct(body); validationErr != nil {
+		abort(c, helper.Invalid(validationErr))
+		return body, false
+	}
+	if body.Printer == "" {
+		body.Printer = defaultPrinter
+	}
+	if body.Width == 0 {
+		body.Width = receipt.Width80mm
+	}
+	return body, true
+}
+
+func submitPrintJob(c *gin.Context, printerName string, data []byte) {
+	job, err := printer.Jobs.Submit(printerName, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, printer.ErrUnknownPrinter):
+			abort(c, helper.NotFound(err.Error()))
+		case errors.Is(err, printer.ErrQueueFull):
+			abort(c, helper.Unavailable(err.Error()))
+		default:
+			abort(c, helper.Internal("the print job could not be queued", err))
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// printNewKitchenTickets sends new tickets to the printer of their station,
+// for stations that have one.
+func printNewKitchenTickets(tickets []models.KitchenTicket) {
+	for _, ticket := range tickets {
+		printKitchenDocument(ticket, kitchenDocument(ticket))
+	}
+}
+
+// printChangedKitchenTickets prints tickets again after their items were
+// edited or cancelled, marked as a change so the station does not cook twice.
+func printChangedKitchenTickets(tickets []models.KitchenTicket) {
+	for _, ticket := range tickets {
+		doc := kitchenDocument(ticket)
+		doc.Title = ticket.Station + " - CHANGED"
+		if ticket.Status == models.KitchenStatusCancelled {
+			doc.Title = ticket.Station + " - CANCELLED"
+		}
+		printKitchenDocument(ticket, doc)
+	}
+}
+
+func printKitchenDocument(ticket models.KitchenTicket, doc receipt.Document) {
+	if _, ok := printer.Printers.Get(ticket.Station); !ok {
+		return
+	}
+	if _, err := printer.Jobs.Submit(ticket.Station, receipt.RenderESCPOS(doc, receipt.Width80mm)); err != nil {
+		log.Printf("could not print kitchen ticket %s: %v", ticket.Ticket_id, err)
+	}
+}
+
+// kitchenDocument lays a kitchen ticket out for the station printer.
+func kitchenDocument(ticket models.KitchenTicket) receipt.Document {
+	doc := receipt.Document{
+		Title:   ticket.Station,
+		Kitchen: true,
+	}
+	if ticket.Table_number != nil {
+		doc.Fields = append(doc.Fields, receipt.Field{Label: "Table", Value: strconv.Itoa(*ticket.Table_number)})
+	}
+	doc.Fields = append(doc.Fields,
+		receipt.Field{Label: "Order", Value: ticket.Order_id},
+		receipt.Field{Label: "Time", Value: ticket.Created_at.Format("15:04")},
+	)
+	for _, note := range ticket.Notes {
+		doc.Fields = append(doc.Fields, receipt.Field{Label: "Note", Value: note.Text})
+	}
+
+	for _, item := range ticket.Items {
+		quantity := 1
+		if item.Quantity != nil {
+			quantity = *item.Quantity
+		}
+		name := item.Food_name
+		if item.Portion_size != nil && *item.Portion_size != "" {
+			name = fmt.Sprintf("%s (%s)", name, *item.Portion_size)
+		}
+		if item.Status == models.KitchenStatusCancelled {
+			name = "CANCELLED " + name
+		}
+
+		line := receipt.Line{Name: name, Quantity: strconv.Itoa(quantity)}
+		for _, note := range item.Notes {
+			line.Notes = append(line.Notes, note.Text)
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+	return doc
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/receipt"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInvoicePDF renders the invoice as GetInvoice assembles it into a PDF.
+func (h *Handler) GetInvoicePDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		invoice, err := h.findInvoice(ctx, c.Param("invoice_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("Invoice not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the invoice", err))
+			return
+		}
+
+		invoiceView, err := h.BuildInvoiceView(ctx, invoice)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while preparing the invoice", err))
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%s.pdf"`, invoiceReference(invoiceView)))
+		c.Data(http.StatusOK, "application/pdf", receipt.RenderPDF(invoiceDocument(invoiceView)))
+	}
+}
+
+// invoiceDocument lays an invoice out for printing.
+func invoiceDocument(invoiceView InvoiceViewFormat) receipt.Document {
+	doc := receipt.Document{
+		Title:  "INVOICE",
+		Header: append([]string{models.RestaurantName}, models.RestaurantAddress...),
+		Footer: []string{"Thank you for dining with us!"},
+	}
+
+	doc.Fields = append(doc.Fields,
+		receipt.Field{Label: "Invoice", Value: invoiceReference(invoiceView)},
+		receipt.Field{Label: "Date", Value: invoiceView.Issued_at.Format("2006-01-02 15:04")},
+	)
+	if tableNumber, ok := invoiceView.Table_number.(*int); ok && tableNumber != nil {
+		doc.Fields = append(doc.Fields, receipt.Field{Label: "Table", Value: strconv.Itoa(*tableNumber)})
+	}
+	doc.Fields = append(doc.Fields, receipt.Field{Label: "Order", Value: invoiceView.Order_id})
+	if invoiceView.Payment_status != nil {
+		doc.Fields = append(doc.Fields, receipt.Field{Label: "Status", Value: *invoiceView.Payment_status})
+	}
+
+	// Notes are shown under the item they were written for
+	notes := map[string][]string{}
+	orderItems, _ := invoiceView.Order_details.([]OrderItemView)
+	for _, item := range orderItems {
+		for _, note := range item.Notes {
+			notes[item.Order_item_id] = append(notes[item.Order_item_id], note.Text)
+		}
+	}
+
+	breakdown := invoiceView.Breakdown
+	if breakdown == nil {
+		return doc
+	}
+	for _, line := range breakdown.Lines {
+		doc.Lines = append(doc.Lines, receipt.Line{
+			Name:     line.Name,
+			Quantity: strconv.FormatInt(line.Quantity, 10),
+			Price:    line.Unit_price.String(),
+			Amount:   line.Line_total.String(),
+			Notes:    notes[line.Order_item_id],
+		})
+	}
+
+	doc.Totals = append(doc.Totals, receipt.Field{Label: "Subtotal", Value: formatMoney(breakdown.Subtotal)})
+	for _, discount := range breakdown.Discounts {
+		doc.Totals = append(doc.Totals, receipt.Field{Label: "Discount: " + discount.Name, Value: formatMoney(discount.Amount.Multiply(-1))})
+	}
+	if !breakdown.Service_charge.IsZero() {
+		doc.Totals = append(doc.Totals, receipt.Field{Label: "Service charge", Value: formatMoney(breakdown.Service_charge)})
+	}
+	for _, tax := range breakdown.Taxes {
+		label := fmt.Sprintf("%s %s", tax.Name, formatRate(tax.Rate_bps))
+		if breakdown.Tax_inclusive {
+			label = "incl. " + label
+		}
+		doc.Totals = append(doc.Totals, receipt.Field{Label: label, Value: formatMoney(tax.Tax)})
+	}
+	doc.Totals = append(doc.Totals, receipt.Field{Label: "Total", Value: formatMoney(breakdown.Total)})
+	if invoiceView.Split != nil {
+		label := fmt.Sprintf("Your share (%d of %d)", invoiceView.Split.Guest, invoiceView.Split.Guests)
+		if total, ok := invoiceView.Payment_due.(models.Money); ok {
+			doc.Totals = append(doc.Totals, receipt.Field{Label: label, Value: formatMoney(total)})
+		}
+	}
+	if !invoiceView.Amount_credited.IsZero() {
+		doc.Totals = append(doc.Totals, receipt.Field{Label: "Credited", Value: formatMoney(invoiceView.Amount_credited.Multiply(-1))})
+	}
+	if !invoiceView.Amount_paid.IsZero() {
+		doc.Totals = append(doc.Totals, receipt.Field{Label: "Paid", Value: formatMoney(invoiceView.Amount_paid.Multiply(-1))})
+	}
+	doc.Totals = append(doc.Totals, receipt.Field{Label: "Amount due", Value: formatMoney(invoiceView.Amount_due)})
+
+	for _, payment := range invoiceView.Payments {
+		if !payment.CountsTowardsInvoice() {
+			continue
+		}
+		label := fmt.Sprintf("%s %s", *payment.Method, payment.Paid_at.Format("2006-01-02 15:04"))
+		amount := *payment.Amount
+		if payment.IsRefund() {
+			label = "Refund " + label
+			amount = amount.Multiply(-1)
+		}
+		doc.Payments = append(doc.Payments, receipt.Field{Label: label, Value: formatMoney(amount)})
+		if payment.Tip != nil && !payment.Tip.IsZero() {
+			doc.Payments = append(doc.Payments, receipt.Field{Label: "  Tip", Value: formatMoney(*payment.Tip)})
+		}
+	}
+	return doc
+}
+
+// invoiceReference is the number printed on an invoice, or its id for
+// invoices issued before numbering.
+func invoiceReference(invoiceView InvoiceViewFormat) string {
+	if invoiceView.Invoice_number != "" {
+		return invoiceView.Invoice_number
+	}
+	return invoiceView.Invoice_id
+}
+
+func formatMoney(amount models.Money) string {
+	return amount.String() + " " + amount.Currency
+}
+
+// formatRate prints basis points as a percentage, 750 as 7.50%.
+func formatRate(rateBps int64) string {
+	return fmt.Sprintf("%d.%02d%%", rateBps/100, rateBps%100)
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SalesReport sums what was invoiced, credited, paid and refunded in a period.
+type SalesReport struct {
+	From             string                  `json:"from"` // first day of the period, YYYY-MM-DD
+	To               string                  `json:"to"`   // last day of the period, included
+	Invoice_count    int64                   `json:"invoice_count"`
+	Gross_sales      models.Money            `json:"gross_sales"`
+	Credit_count     int64                   `json:"credit_count"`
+	Credits          models.Money            `json:"credits"`
+	Credits_by_code  map[string]models.Money `json:"credits_by_code"`
+	Net_sales        models.Money            `json:"net_sales"`
+	Payments         models.Money            `json:"payments"`
+	Payments_by_type map[string]models.Money `json:"payments_by_method"`
+	Refunds          models.Money            `json:"refunds"`
+	Refunds_by_type  map[string]models.Money `json:"refunds_by_method"`
+	Tips             models.Money            `json:"tips"`
+}
+
+// reportTotal is one group of report totals.
+type reportTotal struct {
+	Count  int64
+	Amount models.Money
+	Tips   models.Money
+}
+
+// add counts a document and adds its amounts to the total.
+func (t *reportTotal) add(amount models.Money, tip models.Money) error {
+	var amountErr, tipErr error
+	t.Count++
+	t.Amount, amountErr = t.Amount.CheckedAdd(amount)
+	t.Tips, tipErr = t.Tips.CheckedAdd(tip)
+	return errors.Join(amountErr, tipErr)
+}
+
+// GetSalesReport reports on the period given by the `from` and `to` dates
+// (YYYY-MM-DD, `to` included), today by default. Days run from midnight to
+// midnight in the restaurant's time zone.
+func (h *Handler) GetSalesReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		year, month, day := time.Now().Date()
+		today := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+		from, to := today, today
+		var err error
+		if value := c.Query("from"); value != "" {
+			if from, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+				abort(c, helper.BadRequest("from must be a date like 2024-05-31"))
+				return
+			}
+		}
+		if value := c.Query("to"); value != "" {
+			if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+				abort(c, helper.BadRequest("to must be a date like 2024-05-31"))
+				return
+			}
+		}
+		if to.Before(from) {
+			abort(c, helper.BadRequest("from must not be after to"))
+			return
+		}
+		end := to.AddDate(0, 0, 1)
+		invoiceList, err := h.Invoices.ListIssuedBetween(ctx, from, end)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while totalling invoices", err))
+			return
+		}
+		creditNoteList, err := h.CreditNotes.ListIssuedBetween(ctx, from, end)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while totalling credit notes", err))
+			return
+		}
+		paymentList, err := h.Payments.ListPaidBetween(ctx, from, end)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while totalling payments", err))
+			return
+		}
+
+		report, err := salesReport(invoiceList, creditNoteList, paymentList)
+		if err != nil {
+			if errors.Is(err, models.ErrCurrencyMismatch) {
+				abort(c, helper.Conflict("the period holds amounts in more than one currency, which cannot be added up: "+err.Error()))
+				return
+			}
+			abort(c, helper.Internal("error occurred while totalling the period", err))
+			return
+		}
+		report.From = from.Format("2006-01-02")
+		report.To = to.Format("2006-01-02")
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// salesReport adds the documents of a period up in the restaurant currency.
+// It returns ErrCurrencyMismatch for an amount in any other currency.
+func salesReport(invoiceList []models.Invoice, creditNoteList []models.CreditNote, paymentList []models.Payment) (SalesReport, error) {
+	zero := models.NewMoney(0, models.DefaultCurrency)
+	invoices := newReportTotal()
+	credits, creditsByCode := newReportTotal(), map[string]*reportTotal{}
+	payments, paymentsByMethod := newReportTotal(), map[string]*reportTotal{}
+	refunds, refundsByMethod := newReportTotal(), map[string]*reportTotal{}
+
+	for _, invoice := range invoiceList {
+		if err := invoices.add(invoice.Total, zero); err != nil {
+			return SalesReport{}, err
+		}
+	}
+	for _, creditNote := range creditNoteList {
+		if err := addReportTotal(credits, creditsByCode, creditNote.Reason_code, creditNote.Total, zero); err != nil {
+			return SalesReport{}, err
+		}
+	}
+
+	// Payments recorded before statuses and types existed count as settled payments
+	for _, payment := range paymentList {
+		if !payment.CountsTowardsInvoice() {
+			continue
+		}
+		method := ""
+		if payment.Method != nil {
+			method = *payment.Method
+		}
+		amount, tip := zero, zero
+		if payment.Amount != nil {
+			amount = *payment.Amount
+		}
+		if payment.Tip != nil {
+			tip = *payment.Tip
+		}
+		total, byMethod := payments, paymentsByMethod
+		if payment.IsRefund() {
+			total, byMethod = refunds, refundsByMethod
+		}
+		if err := addReportTotal(total, byMethod, method, amount, tip); err != nil {
+			return SalesReport{}, err
+		}
+	}
+
+	netSales, err := invoices.Amount.CheckedSub(credits.Amount)
+	return SalesReport{
+		Invoice_count:    invoices.Count,
+		Gross_sales:      invoices.Amount,
+		Credit_count:     credits.Count,
+		Credits:          credits.Amount,
+		Credits_by_code:  reportAmounts(creditsByCode),
+		Net_sales:        netSales,
+		Payments:         payments.Amount,
+		Payments_by_type: reportAmounts(paymentsByMethod),
+		Refunds:          refunds.Amount,
+		Refunds_by_type:  reportAmounts(refundsByMethod),
+		Tips:             payments.Tips,
+	}, err
+}
+
+// newReportTotal starts a total at zero in the restaurant currency, so that
+// amounts in any other currency fail to be added to it.
+func newReportTotal() *reportTotal {
+	zero := models.NewMoney(0, models.DefaultCurrency)
+	return &reportTotal{Amount: zero, Tips: zero}
+}
+
+// addReportTotal counts a document and adds its amounts to the total and to
+// the group of the key.
+func addReportTotal(total *reportTotal, groups map[string]*reportTotal, key string, amount models.Money, tip models.Money) error {
+	group, ok := groups[key]
+	if !ok {
+		group = newReportTotal()
+		groups[key] = group
+	}
+	return errors.Join(total.add(amount, tip), group.add(amount, tip))
+}
+
+// reportAmounts lists the amount of each group.
+func reportAmounts(groups map[string]*reportTotal) map[string]models.Money {
+	amounts := make(map[string]models.Money, len(groups))
+	for key, group := range groups {
+		amounts[key] = group.Amount
+	}
+	return amounts
+}
@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var reservationList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "table_id"},
+		{Param: "status"},
+		{Param: "phone"},
+		{Param: "start", Field: "start_time", Kind: helper.FilterTime},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts: map[string]string{"start_time": "start_time", "created_at": "created_at", "party_size": "party_size"},
+}
+
+func (h *Handler) GetReservations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		params, err := helper.ParseList(c.Request.URL, reservationList)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+
+		// date=YYYY-MM-DD is short for start_from and start_to on that day
+		if date := c.Query("date"); date != "" {
+			day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil {
+				abort(c, helper.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			params.Filter["start_time"] = bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}
+		}
+
+		items, total, err := h.Reservations.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing reservations", err))
+			return
+		}
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
+	}
+}
+
+func (h *Handler) GetReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		reservation, err := h.Reservations.Get(ctx, c.Param("reservation_id"))
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("reservation not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the reservation", err))
+			return
+		}
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+func (h *Handler) CreateReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		var reservation models.Reservation
+		if err := c.ShouldBindJSON(&reservation); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		if validationErr := validate.Struct(reservation); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		if reservation.Start_time.Before(time.Now()) {
+			abort(c, helper.BadRequest("start_time must be in the future"))
+			return
+		}
+
+		if reservation.Duration_minutes == nil {
+			duration := models.DefaultReservationMinutes
+			reservation.Duration_minutes = &duration
+		}
+		reservation.End_time = reservation.Start_time.Add(time.Duration(*reservation.Duration_minutes) * time.Minute)
+		reservation.Status = models.ReservationStatusBooked
+
+		now := time.Now()
+		reservation.Created_at = now
+		reservation.Updated_at = now
+		reservation.ID = primitive.NewObjectID()
+		reservation.Reservation_id = reservation.ID.Hex()
+
+		err := h.bookTable(ctx, reservation, "", func() error {
+			if insertErr := h.Reservations.Create(ctx, reservation); insertErr != nil {
+				return helper.Internal("Reservation could not be created", insertErr)
+			}
+			return nil
+		})
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"InsertedID": reservation.ID})
+	}
+}
+
+func (h *Handler) UpdateReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		reservationId := c.Param("reservation_id")
+
+		var changes models.Reservation
+		if err := c.ShouldBindJSON(&changes); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		reservation, err := h.Reservations.Get(ctx, reservationId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("reservation not found"))
+				return
+			}
+			abort(c, helper.Internal("error occurred while fetching the reservation", err))
+			return
+		}
+
+		// Seated, no-show and cancelled reservations are final
+		if reservation.Status != models.ReservationStatusBooked {
+			abort(c, helper.Conflict("only booked reservations can be changed"))
+			return
+		}
+
+		// Apply the provided fields on top of the stored reservation
+		if changes.Table_id != nil {
+			reservation.Table_id = changes.Table_id
+		}
+		if changes.Guest_name != nil {
+			reservation.Guest_name = changes.Guest_name
+		}
+		if changes.Phone != nil {
+			reservation.Phone = changes.Phone
+		}
+		if changes.Party_size != nil {
+			reservation.Party_size = changes.Party_size
+		}
+		if changes.Start_time != nil {
+			reservation.Start_time = changes.Start_time
+		}
+		if changes.Duration_minutes != nil {
+			reservation.Duration_minutes = changes.Duration_minutes
+		}
+		if changes.Status != "" {
+			reservation.Status = changes.Status
+		}
+		if reservation.Duration_minutes == nil {
+			duration := models.DefaultReservationMinutes
+			reservation.Duration_minutes = &duration
+		}
+		reservation.End_time = reservation.Start_time.Add(time.Duration(*reservation.Duration_minutes) * time.Minute)
+
+		if validationErr := validate.Struct(reservation); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		reservation.Updated_at = time.Now()
+		updateObj := bson.M{
+			"table_id":         reservation.Table_id,
+			"guest_name":       reservation.Guest_name,
+			"phone":            reservation.Phone,
+			"party_size":       reservation.Party_size,
+			"start_time":       reservation.Start_time,
+			"duration_minutes": reservation.Duration_minutes,
+			"end_time":         reservation.End_time,
+			"status":           reservation.Status,
+			"updated_at":       reservation.Updated_at,
+		}
+
+		// Only apply the update if nobody else changed the status meanwhile
+		var updated models.Reservation
+		update := func() error {
+			var updateErr error
+			updated, updateErr = h.Reservations.UpdateBooked(ctx, reservationId, updateObj)
+			if updateErr != nil {
+				if errors.Is(updateErr, repository.ErrNotFound) {
+					return helper.Conflict("reservation was changed by another request")
+				}
+				return helper.Internal("Reservation update failed", updateErr)
+			}
+			return nil
+		}
+
+		// Capacity and overlaps only matter while the reservation still holds the table
+		if reservation.Status == models.ReservationStatusBooked || reservation.Status == models.ReservationStatusSeated {
+			err = h.bookTable(ctx, reservation, reservationId, update)
+		} else {
+			err = update()
+		}
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// GetAvailableTables lists the tables that can seat a party at the given time,
+// smallest suitable table first.
+func (h *Handler) GetAvailableTables() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
+
+		start, err := time.Parse(time.RFC3339, c.Query("start_time"))
+		if err != nil {
+			abort(c, helper.BadRequest("start_time must be an RFC3339 timestamp"))
+			return
+		}
+
+		partySize, err := strconv.Atoi(c.Query("party_size"))
+		if err != nil || partySize < 1 {
+			abort(c, helper.BadRequest("party_size must be a positive number"))
+			return
+		}
+
+		duration := models.DefaultReservationMinutes
+		if durationParam := c.Query("duration_minutes"); durationParam != "" {
+			duration, err = strconv.Atoi(durationParam)
+			if err != nil || duration < 15 || duration > 720 {
+				abort(c, helper.BadRequest("duration_minutes must be between 15 and 720"))
+				return
+			}
+		}
+		end := start.Add(time.Duration(duration) * time.Minute)
+
+		// Tables already held by an overlapping reservation
+		busyTableIds, err := h.Reservations.BusyTableIds(ctx, start, end)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while checking reservations", err))
+			return
+		}
+
+		freeTables, err := h.Tables.ListSeating(ctx, partySize, busyTableIds)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing available tables", err))
+			return
+		}
+		if freeTables == nil {
+			freeTables = []models.Table{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"start_time": start,
+			"end_time":   end,
+			"party_size": partySize,
+			"tables":     freeTables,
+		})
+	}
+}
+
+// tableHold is how long a booking keeps other bookings of the table waiting
+// at most, should the request never release it.
+const tableHold = 30 * time.Second
+
+// bookTable checks that the reservation fits its table and writes it with
+// book, holding the table meanwhile. Two requests booking the same table
+// would otherwise both find it free and both write their reservation.
+func (h *Handler) bookTable(ctx context.Context, reservation models.Reservation, excludeReservationId string, book func() error) error {
+	tableId := *reservation.Table_id
+	until := time.Now().Add(tableHold).Truncate(time.Millisecond)
+	for attempt := 1; ; attempt++ {
+		err := h.Tables.Hold(ctx, tableId, until)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return helper.Internal("error occurred while holding the table", err)
+		}
+		if _, getErr := h.Tables.Get(ctx, tableId); errors.Is(getErr, repository.ErrNotFound) {
+			return helper.NotFound("Table not found")
+		}
+		if attempt == 20 {
+			return helper.Conflict("the table is being booked by another request, try again")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer func() {
+		if err := h.Tables.Release(context.WithoutCancel(ctx), tableId, until); err != nil {
+			log.Printf("could not release table %s, it is held until %s: %v", tableId, until.Format(time.RFC3339), err)
+		}
+	}()
+
+	if err := h.checkReservationFits(ctx, reservation, excludeReservationId); err != nil {
+		return err
+	}
+	return book()
+}
+
+// checkReservationFits makes sure the reserved table exists, seats the party
+// and is not held by another reservation in the same time slot. It returns
+// the HTTP status and message to reply with when the reservation does not fit.
+func (h *Handler) checkReservationFits(ctx context.Context, reservation models.Reservation, excludeReservationId string) error {
+	table, err := h.Tables.Get(ctx, *reservation.Table_id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return helper.NotFound("Table not found")
+		}
+		return helper.Internal("error occurred while fetching the table", err)
+	}
+
+	if table.Number_of_guests == nil || *table.Number_of_guests < *reservation.Party_size {
+		return helper.BadRequest("the table cannot seat a party of this size")
+	}
+
+	overlapping, err := h.Reservations.CountOverlapping(ctx, *reservation.Table_id, *reservation.Start_time, reservation.End_time, excludeReservationId)
+	if err != nil {
+		return helper.Internal("error occurred while checking reservations", err)
+	}
+	if overlapping > 0 {
+		return helper.Conflict("the table is already reserved for this time")
+	}
+	return nil
+}
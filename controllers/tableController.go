@@ -5,79 +5,77 @@ import (
 	"errors"
 	"net/http"
 	"time"
-	"fmt"
 
-	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
+var tableList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "table_number", Kind: helper.FilterNumber},
+		{Param: "number_of_guests", Kind: helper.FilterNumber},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "table_number": "table_number", "number_of_guests": "number_of_guests"},
+}
 
-func GetTables() gin.HandlerFunc {
+func (h *Handler) GetTables() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
 
-		result, err := tableCollection.Find(ctx, bson.M{})
+		params, err := helper.ParseList(c.Request.URL, tableList)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing table items"})
+			abort(c, err)
 			return
 		}
 
-		var allTables []bson.M
-		if err := result.All(ctx, &allTables); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while retrieving table items"})
+		items, total, err := h.Tables.List(ctx, params.ListQuery)
+		if err != nil {
+			abort(c, helper.Internal("error occurred while listing tables", err))
 			return
 		}
-		c.JSON(http.StatusOK, allTables)
+		c.JSON(http.StatusOK, helper.NewListResponse(items, total, params))
 	}
 }
 
-func GetTable() gin.HandlerFunc {
+func (h *Handler) GetTable() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		tableId := c.Query("table_id")
-		var table models.Table
-
-		fmt.Printf("Searching for table with ID: %s", tableId)
-		err := tableCollection.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table)
+		table, err := h.Tables.Get(ctx, c.Param("table_id"))
 		if err != nil {
-			fmt.Printf("Error occurred: %v", err) // Log the error
-			// Check if the error is due to no documents found
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "table not found"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("table not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the tables"})
+			abort(c, helper.Internal("error occurred while fetching the tables", err))
 			return
 		}
-		fmt.Printf("Fetched table: %+v", table) // Log the fetched table
 		c.JSON(http.StatusOK, table)
 	}
 }
 
-func CreateTable() gin.HandlerFunc {
+func (h *Handler) CreateTable() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
 
 		var table models.Table
-		if err := c.BindJSON(&table); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&table); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Validate table structure
 		if validationErr := validate.Struct(table); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			abort(c, helper.Invalid(validationErr))
 			return
 		}
 
@@ -89,17 +87,15 @@ func CreateTable() gin.HandlerFunc {
 		table.ID = primitive.NewObjectID()
 		table.Table_id = table.ID.Hex()
 
-		// Insert the table into the database
-		result, insertErr := tableCollection.InsertOne(ctx, table)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create Table item"})
+		if insertErr := h.Tables.Create(ctx, table); insertErr != nil {
+			abort(c, helper.Internal("Unable to create Table item", insertErr))
 			return
 		}
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": table.ID})
 	}
 }
 
-func UpdateTable() gin.HandlerFunc {
+func (h *Handler) UpdateTable() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // Ensure context is canceled
@@ -107,35 +103,82 @@ func UpdateTable() gin.HandlerFunc {
 		var table models.Table
 		tableId := c.Param("table_id")
 
-		if err := c.BindJSON(&table); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&table); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		// Prepare update object
-		updateObj := primitive.D{}
+		updateObj := bson.M{}
 		if table.Number_of_guests != nil {
-			updateObj = append(updateObj, bson.E{"number_of_guests", table.Number_of_guests})
+			updateObj["number_of_guests"] = table.Number_of_guests
 		}
 		if table.Table_number != nil {
-			updateObj = append(updateObj, bson.E{"table_number", table.Table_number})
+			updateObj["table_number"] = table.Table_number
 		}
 
 		// Update the timestamp
 		table.Updated_at = time.Now()
-		updateObj = append(updateObj, bson.E{"updated_at", table.Updated_at})
+		updateObj["updated_at"] = table.Updated_at
 
-		// Set upsert option
-		opt := options.Update().SetUpsert(true)
-
-		// Execute the update
-		filter := bson.M{"table_id": tableId}
-
-		result, err := tableCollection.UpdateOne(ctx, filter, bson.D{{"$set", updateObj}}, opt)
+		updated, err := h.Tables.Update(ctx, tableId, updateObj)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Table item update failed"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("table not found"))
+				return
+			}
+			abort(c, helper.Internal("Table item update failed", err))
 			return
 		}
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, updated)
 	}
 }
+
+// DeleteTable refuses tables with an order still running or a booking to come.
+func (h *Handler) DeleteTable() gin.HandlerFunc {
+	return deleteHandler("table", "table_id", h.Tables, func(ctx context.Context, tableId string) error {
+		open, err := h.Orders.CountOpenByTable(ctx, tableId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the orders of the table", err)
+		}
+		if open > 0 {
+			return helper.Conflict("the table has orders that are still open")
+		}
+
+		booked, err := h.Reservations.CountBooked(ctx, tableId, time.Now())
+		if err != nil {
+			return helper.Internal("error occurred while checking the reservations of the table", err)
+		}
+		if booked > 0 {
+			return helper.Conflict("the table has upcoming reservations")
+		}
+		return nil
+	})
+}
+
+func (h *Handler) RestoreTable() gin.HandlerFunc {
+	return restoreHandler[models.Table]("table", "table_id", h.Tables, nil)
+}
+
+// PurgeTable keeps a table that orders or upcoming reservations still
+// refer to, since they are shown with its number.
+func (h *Handler) PurgeTable() gin.HandlerFunc {
+	return purgeHandler("table", "table_id", h.Tables, func(ctx context.Context, tableId string) error {
+		orders, err := h.Orders.CountByTable(ctx, tableId)
+		if err != nil {
+			return helper.Internal("error occurred while checking the orders of the table", err)
+		}
+		if orders > 0 {
+			return helper.Conflict("orders still refer to the table")
+		}
+
+		booked, err := h.Reservations.CountBooked(ctx, tableId, time.Now())
+		if err != nil {
+			return helper.Internal("error occurred while checking the reservations of the table", err)
+		}
+		if booked > 0 {
+			return helper.Conflict("the table has upcoming reservations")
+		}
+		return nil
+	})
+}
@@ -2,180 +2,160 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	helper "go-restaurant-management/helpers"
 	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go-restaurant-management/database"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
-
-func GetUsers() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
-
-		// Retrieve query parameters with defaults
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
-		}
-
-		page, err1 := strconv.Atoi(c.Query("page"))
-		if err1 != nil || page < 1 {
-			page = 1
-		}
+// UserView is a user as the API shows it, without the password hash and the
+// stored tokens.
+type UserView struct {
+	User_id    string     `json:"user_id"`
+	First_name *string    `json:"first_name"`
+	Last_name  *string    `json:"last_name"`
+	Email      *string    `json:"email"`
+	Avatar     *string    `json:"avatar"`
+	Phone      *string    `json:"phone"`
+	Role       *string    `json:"role"`
+	Created_at time.Time  `json:"created_at"`
+	Updated_at time.Time  `json:"updated_at"`
+	Deleted_at *time.Time `json:"deleted_at,omitempty"`
+	Deleted_by *string    `json:"deleted_by,omitempty"`
+}
 
-		startIndex := (page - 1) * recordPerPage
+func newUserView(user models.User) UserView {
+	return UserView{
+		User_id:    user.User_id,
+		First_name: user.First_name,
+		Last_name:  user.Last_name,
+		Email:      user.Email,
+		Avatar:     user.Avatar,
+		Phone:      user.Phone,
+		Role:       user.Role,
+		Created_at: user.Created_at,
+		Updated_at: user.Updated_at,
+		Deleted_at: user.Deleted_at,
+		Deleted_by: user.Deleted_by,
+	}
+}
 
-		// Define aggregation stages
-		matchStage := bson.D{{"$match", bson.D{}}} // Fetch all documents
-		skipStage := bson.D{{"$skip", int64(startIndex)}} // Skip to start index
-		limitStage := bson.D{{"$limit", int64(recordPerPage)}} // Limit to records per page
-		countStage := bson.D{{"$count", "total_count"}} // Count total documents
+var userList = helper.ListSpec{
+	Filters: []helper.ListFilter{
+		{Param: "role"},
+		{Param: "email"},
+		{Param: "created", Field: "created_at", Kind: helper.FilterTime},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "email": "email", "first_name": "first_name", "last_name": "last_name"},
+}
 
-		// Execute the aggregation to get user data
-		userDataResult, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, skipStage, limitStage,
-		})
+func (h *Handler) GetUsers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel() // Ensure context is canceled
 
+		params, err := helper.ParseList(c.Request.URL, userList)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing user items"})
-			return
-		}
-
-		// Retrieve results
-		var allUsers []bson.M
-		if err = userDataResult.All(ctx, &allUsers); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse user data"})
+			abort(c, err)
 			return
 		}
 
-		// Execute the aggregation to count total users
-		countResult, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, countStage,
-		})
-
+		items, total, err := h.Users.List(ctx, params.ListQuery)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while counting user items"})
-			return
-		}
-
-		// Retrieve count result
-		var countData []bson.M
-		if err = countResult.All(ctx, &countData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse user count"})
+			abort(c, helper.Internal("error occurred while listing users", err))
 			return
 		}
-
-		totalCount := 0
-		if len(countData) > 0 {
-			totalCount = int(countData[0]["total_count"].(int32)) // Assuming total_count is of type int32
+		views := make([]UserView, len(items))
+		for i, item := range items {
+			views[i] = newUserView(item)
 		}
-
-		// Respond with all users and total count
-		c.JSON(http.StatusOK, gin.H{
-			"page":          page,
-			"recordPerPage": recordPerPage,
-			"total_count":   totalCount,
-			"users":         allUsers,
-		})
+		c.JSON(http.StatusOK, helper.NewListResponse(views, total, params))
 	}
 }
 
-
-func GetUser() gin.HandlerFunc {
+func (h *Handler) GetUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		userId := c.Query("user_id") // Fetch user ID from query parameter
-		if userId == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id query parameter is required"})
-			return
-		}
-
-		var user models.User
-
-		// Convert user_id to ObjectId if stored as ObjectId in MongoDB
-		objectId, err := primitive.ObjectIDFromHex(userId)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID format"})
-			return
-		}
-
-		// Query the database
-		err = userCollection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&user)
+		user, err := h.Users.Get(ctx, c.Param("user_id"))
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, gin.H{"message": "user not found"})
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("user not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the user"})
+			abort(c, helper.Internal("error occurred while fetching the user", err))
 			return
 		}
 
 		// Respond with the user data
-		c.JSON(http.StatusOK, user)
+		c.JSON(http.StatusOK, newUserView(user))
 	}
 }
 
-func SignUp() gin.HandlerFunc {
+func (h *Handler) SignUp() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel() // This ensures the context is canceled when the function exits
 		var user models.User
 
 		//convert the JSON data coming from postman to something that golang understands
-		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&user); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		//validate the data based on user struct
 		validationErr := validate.Struct(user)
 		if validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			abort(c, helper.Invalid(validationErr))
 			return
 		}
 
 		//you'll check if the email has already been used by another user
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+		count, err := h.Users.CountByEmail(ctx, *user.Email)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for the email"})
+			abort(c, helper.Internal("error occured while checking for the email", err))
 			//log.Panic(err)
 			return
 		}
 
+		if count > 0 {
+			abort(c, helper.NewError(helper.CodeAlreadyExists, "email or phone number already exists"))
+			return
+		}
+
 		//hash password
 		password := HashPassword(*user.Password)
 		user.Password = &password
 
 		// Check if the phone no. has already been used by another user
-		count, err = userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+		count, err = h.Users.CountByPhone(ctx, *user.Phone)
 
 		if err != nil {
 			//log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking for the phone number"})
+			abort(c, helper.Internal("error occured while checking for the phone number", err))
 			return
 		}
 
 		if count > 0 {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "email or phone number already exits"})
+			abort(c, helper.NewError(helper.CodeAlreadyExists, "email or phone number already exists"))
 			return
 		}
 
+		//roles are never taken from the signup payload, a new account has none and cannot do
+		//anything until an admin gives it one. The first admin is granted with the grant-admin command.
+		user.Role = nil
+
 		//create some extra details for the user object - created_at, updated_at, ID
 		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -183,24 +163,27 @@ func SignUp() gin.HandlerFunc {
 		user.User_id = user.ID.Hex()
 
 		//generate token and refresh token (generate all tokens function from helper)
-		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id)
+		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, "")
 		user.Token = &token
 		user.Refresh_Token = &refreshToken
 
 		//if all ok, then you insert this new user into the user collection
-		resultInsertionNumber, insertErr := userCollection.InsertOne(ctx, user)
-		if insertErr != nil {
-			msg := fmt.Sprintf("User item was not created")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		//the unique indexes catch a signup racing this one past the checks above
+		if insertErr := h.Users.Create(ctx, user); insertErr != nil {
+			if errors.Is(insertErr, repository.ErrDuplicate) {
+				abort(c, helper.NewError(helper.CodeAlreadyExists, "email or phone number already exists"))
+				return
+			}
+			abort(c, helper.Internal("User item was not created", insertErr))
 			return
 		}
 
 		//return status OK and send the result back
-		c.JSON(http.StatusOK, resultInsertionNumber)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": user.ID})
 	}
 }
 
-func Login() gin.HandlerFunc {
+func (h *Handler) Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -209,41 +192,177 @@ func Login() gin.HandlerFunc {
 		//whether it exits normally or due to an error.
 		defer cancel()
 		var user models.User
-		var foundUser models.User
 
 		//convert the login data from postman which is in JSON to golang readable format
-		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if err := c.ShouldBindJSON(&user); err != nil {
+			abort(c, helper.InvalidBody(err))
 			return
 		}
 
 		//find a user with that email and see if that user even exists
-		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
+		if user.Email == nil || user.Password == nil {
+			abort(c, helper.BadRequest("email and password are required"))
+			return
+		}
+		foundUser, err := h.Users.FindByEmail(ctx, *user.Email)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found, login seems to be incorrect"})
+			// An unknown email gets the same answer as a wrong password
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NewError(helper.CodeInvalidCredentials, "login or password is incorrect"))
+				return
+			}
+			abort(c, helper.Internal("error occured while looking up the user", err))
 			return
 		}
 
 		// Verify the password
 		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
 		if !passwordIsValid {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			abort(c, helper.NewError(helper.CodeInvalidCredentials, msg))
 			return
 		}
 
+		role := ""
+		if foundUser.Role != nil {
+			role = *foundUser.Role
+		}
+
 		//if all goes well, generate tokens
-		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id)
+		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, role)
 
 		//update tokens - token and refresh token
-		helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
+		if err := h.Users.SetTokens(ctx, foundUser.User_id, token, refreshToken); err != nil {
+			abort(c, helper.Internal("error occured while saving the tokens", err))
+			return
+		}
+		//return statusOK, the tokens are only ever handed out here and on refresh
+		c.JSON(http.StatusOK, struct {
+			UserView
+			Token         string `json:"token"`
+			Refresh_token string `json:"refresh_token"`
+		}{newUserView(foundUser), token, refreshToken})
+	}
+}
+
+func (h *Handler) RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		//return statusOK
-		c.JSON(http.StatusOK, foundUser)
+		var body struct {
+			Refresh_token *string `json:"refresh_token" validate:"required"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		claims, msg := helper.ValidateRefreshToken(*body.Refresh_token)
+		if msg != "" {
+			abort(c, helper.Unauthorized(msg))
+			return
+		}
+		if claims.Uid == "" {
+			abort(c, helper.Unauthorized("the refresh token is invalid"))
+			return
+		}
+
+		foundUser, err := h.Users.Get(ctx, claims.Uid)
+		if err != nil {
+			abort(c, helper.Unauthorized("the refresh token is invalid"))
+			return
+		}
+
+		role := ""
+		if foundUser.Role != nil {
+			role = *foundUser.Role
+		}
+
+		//new claims are built from the stored user so role changes are picked up on refresh
+		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, role)
+
+		rotated, err := h.Users.RotateTokens(ctx, foundUser.User_id, *body.Refresh_token, token, refreshToken)
+		if err != nil {
+			abort(c, helper.Internal("error occured while refreshing the tokens", err))
+			return
+		}
+
+		//a validly signed refresh token that is no longer on record has already been used,
+		//so assume it leaked and revoke the whole session
+		if !rotated {
+			if err := h.Users.RevokeTokens(ctx, foundUser.User_id); err != nil {
+				abort(c, helper.Internal("error occured while revoking the tokens", err))
+				return
+			}
+			abort(c, helper.Unauthorized("refresh token reuse detected, please log in again"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+	}
+}
+
+func (h *Handler) Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := h.Users.RevokeTokens(ctx, c.GetString("uid")); err != nil {
+			abort(c, helper.Internal("error occured while logging out", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+	}
+}
+
+func (h *Handler) UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := c.Param("user_id")
+		if userId == c.GetString("uid") {
+			abort(c, helper.BadRequest("you cannot change your own role"))
+			return
+		}
+
+		var body struct {
+			Role *string `json:"role" validate:"required,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			abort(c, helper.InvalidBody(err))
+			return
+		}
+
+		if validationErr := validate.Struct(body); validationErr != nil {
+			abort(c, helper.Invalid(validationErr))
+			return
+		}
+
+		updated, err := h.Users.SetRole(ctx, userId, *body.Role)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				abort(c, helper.NotFound("user not found"))
+				return
+			}
+			abort(c, helper.Internal("user role update failed", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"user_id": updated.User_id, "role": updated.Role})
 	}
 }
 
+// PasswordCost is the bcrypt cost of stored passwords. Tests lower it.
+var PasswordCost = 14
+
 func HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -262,3 +381,31 @@ func VerifyPassword(userPassword string, providedPassword string) (bool, string)
 	}
 	return check, msg
 }
+
+// DeleteUser signs the user out everywhere, as tokens are only accepted from
+// users that are not deleted.
+func (h *Handler) DeleteUser() gin.HandlerFunc {
+	deleteUser := deleteHandler("user", "user_id", h.Users, nil)
+	return func(c *gin.Context) {
+		if c.Param("user_id") == c.GetString("uid") {
+			abort(c, helper.BadRequest("you cannot delete yourself"))
+			return
+		}
+		deleteUser(c)
+	}
+}
+
+// RestoreUser revokes the tokens the user had before the deletion, so they
+// sign in again once restored.
+func (h *Handler) RestoreUser() gin.HandlerFunc {
+	return restoreViewHandler("user", "user_id", h.Users, func(ctx context.Context, user models.User) error {
+		if err := h.Users.RevokeTokens(ctx, user.User_id); err != nil {
+			return helper.Internal("error occured while revoking the tokens", err)
+		}
+		return nil
+	}, newUserView)
+}
+
+func (h *Handler) PurgeUser() gin.HandlerFunc {
+	return purgeHandler("user", "user_id", h.Users, nil)
+}
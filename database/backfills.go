@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// timestamped are the collections whose documents embed models.BaseEntity.
+var timestamped = []string{
+	"food", "menu", "table", "order", "orderItem", "invoice", "user",
+	"payment", "creditNote", "note", "kitchenTicket", "reservation",
+}
+
+// BackfillDefaults fills in what the schema validators and the lists expect
+// on documents written before it was always set: created_at, taken from the
+// time in the ObjectID, updated_at, the status of orders and the quantity
+// of order items.
+func BackfillDefaults(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, name := range timestamped {
+		collection := db.Collection(name)
+		created, err := collection.UpdateMany(ctx,
+			bson.M{"created_at": bson.M{"$in": bson.A{nil}}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "created_at", Value: bson.M{"$toDate": "$_id"}}}}}},
+		)
+		if err != nil {
+			return fmt.Errorf("backfilling %s.created_at: %w", name, err)
+		}
+		updated, err := collection.UpdateMany(ctx,
+			bson.M{"updated_at": bson.M{"$in": bson.A{nil}}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "updated_at", Value: "$created_at"}}}}},
+		)
+		if err != nil {
+			return fmt.Errorf("backfilling %s.updated_at: %w", name, err)
+		}
+		if created.ModifiedCount+updated.ModifiedCount > 0 {
+			log.Printf("backfilled the timestamps of %d %s documents", max(created.ModifiedCount, updated.ModifiedCount), name)
+		}
+	}
+
+	result, err := db.Collection("order").UpdateMany(ctx,
+		bson.M{"status": bson.M{"$in": bson.A{nil, ""}}},
+		bson.M{"$set": bson.M{"status": models.OrderStatusOpen}},
+	)
+	if err != nil {
+		return fmt.Errorf("backfilling order.status: %w", err)
+	}
+	log.Printf("set the status of %d orders without one to %s", result.ModifiedCount, models.OrderStatusOpen)
+
+	result, err = db.Collection("orderItem").UpdateMany(ctx,
+		bson.M{"quantity": bson.M{"$in": bson.A{nil}}},
+		bson.M{"$set": bson.M{"quantity": 1}},
+	)
+	if err != nil {
+		return fmt.Errorf("backfilling orderItem.quantity: %w", err)
+	}
+	log.Printf("set the quantity of %d orderItems without one to 1", result.ModifiedCount)
+	return nil
+}
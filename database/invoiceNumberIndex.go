@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateInvoiceNumberIndex makes invoice numbers unique per restaurant.
+// Invoices issued before numbering existed have no number and are left out.
+func CreateInvoiceNumberIndex(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	index := mongo.IndexModel{
+		Keys: bson.D{{Key: "restaurant_id", Value: 1}, {Key: "invoice_number", Value: 1}},
+		Options: options.Index().
+			SetName("restaurant_invoice_number").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"invoice_number": bson.M{"$type": "string"}}),
+	}
+	name, err := db.Collection("invoice").Indexes().CreateOne(ctx, index)
+	if err != nil {
+		return fmt.Errorf("creating the invoice number index: %w", err)
+	}
+	log.Printf("invoice index %s is in place", name)
+	return nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// creationOrder is the order list cursors walk, either way.
+var creationOrder = bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}
+
+// byCreation prefixes creation order with the fields a list is filtered on.
+func byCreation(fields ...string) bson.D {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	return append(keys, creationOrder...)
+}
+
+// listIndexes back the cursor paged lists, unfiltered and with the filters
+// they are most often read with.
+var listIndexes = []struct {
+	collection string
+	name       string
+	keys       bson.D
+}{
+	{"order", "created", byCreation()},
+	{"order", "status_created", byCreation("status")},
+	{"order", "table_created", byCreation("table_id")},
+	{"orderItem", "created", byCreation()},
+	{"orderItem", "order_created", byCreation("order_id")},
+	{"invoice", "created", byCreation()},
+	{"invoice", "order_created", byCreation("order_id")},
+	{"invoice", "payment_status_created", byCreation("payment_status")},
+}
+
+// CreateListIndexes creates the indexes of the cursor paged lists. Indexes
+// that already exist are left as they are.
+func CreateListIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, index := range listIndexes {
+		model := mongo.IndexModel{Keys: index.keys, Options: options.Index().SetName(index.name)}
+		if _, err := db.Collection(index.collection).Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("creating the %s index on %s: %w", index.name, index.collection, err)
+		}
+	}
+	log.Printf("%d list indexes are in place", len(listIndexes))
+	return nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// isString limits an index to documents where the field holds a string, so
+// documents from before the field existed neither collide nor get indexed.
+func isString(field string) bson.M {
+	return bson.M{field: bson.M{"$type": "string"}}
+}
+
+// lookupIndexes back the lookups the repositories make by id, and keep ids,
+// emails and phone numbers unique. Soft deleted documents keep their values,
+// a deleted user's email stays taken until the user is purged.
+var lookupIndexes = []struct {
+	collection string
+	name       string
+	keys       bson.D
+	unique     bool
+	partial    bson.M // nil to index every document
+}{
+	{"food", "food_id", bson.D{{Key: "food_id", Value: 1}}, true, nil},
+	{"food", "menu_id", bson.D{{Key: "menu_id", Value: 1}}, false, nil},
+	{"menu", "menu_id", bson.D{{Key: "menu_id", Value: 1}}, true, nil},
+	{"table", "table_id", bson.D{{Key: "table_id", Value: 1}}, true, nil},
+	{"order", "order_id", bson.D{{Key: "order_id", Value: 1}}, true, nil},
+	{"orderItem", "order_item_id", bson.D{{Key: "order_item_id", Value: 1}}, true, nil},
+	{"orderItem", "food_id", bson.D{{Key: "food_id", Value: 1}}, false, nil},
+	{"invoice", "invoice_id", bson.D{{Key: "invoice_id", Value: 1}}, true, nil},
+	{"user", "user_id", bson.D{{Key: "user_id", Value: 1}}, true, nil},
+	{"user", "email", bson.D{{Key: "email", Value: 1}}, true, isString("email")},
+	{"user", "phone", bson.D{{Key: "phone", Value: 1}}, true, isString("phone")},
+	{"payment", "payment_id", bson.D{{Key: "payment_id", Value: 1}}, true, nil},
+	{"payment", "invoice_id", bson.D{{Key: "invoice_id", Value: 1}, {Key: "paid_at", Value: 1}}, false, nil},
+	{"payment", "provider_reference", bson.D{{Key: "provider", Value: 1}, {Key: "provider_reference", Value: 1}}, false, isString("provider_reference")},
+	{"creditNote", "credit_note_id", bson.D{{Key: "credit_note_id", Value: 1}}, true, nil},
+	{"creditNote", "invoice_id", bson.D{{Key: "invoice_id", Value: 1}}, false, nil},
+	{"note", "note_id", bson.D{{Key: "note_id", Value: 1}}, true, nil},
+	{"note", "owner", byCreation("owner_type", "owner_id"), false, nil},
+	{"kitchenTicket", "ticket_id", bson.D{{Key: "ticket_id", Value: 1}}, true, nil},
+	{"kitchenTicket", "order_id", byCreation("order_id"), false, nil},
+	{"reservation", "reservation_id", bson.D{{Key: "reservation_id", Value: 1}}, true, nil},
+	{"reservation", "table_start_time", bson.D{{Key: "table_id", Value: 1}, {Key: "start_time", Value: 1}}, false, nil},
+}
+
+// CreateLookupIndexes creates the id, uniqueness and foreign key indexes.
+// A unique index fails to build while duplicates exist, which the error
+// names so they can be cleaned up before running it again.
+func CreateLookupIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, index := range lookupIndexes {
+		opts := options.Index().SetName(index.name)
+		if index.unique {
+			opts.SetUnique(true)
+		}
+		if index.partial != nil {
+			opts.SetPartialFilterExpression(index.partial)
+		}
+		model := mongo.IndexModel{Keys: index.keys, Options: opts}
+		if _, err := db.Collection(index.collection).Indexes().CreateOne(ctx, model); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return fmt.Errorf("%s has documents sharing the same %s, resolve them first: %w", index.collection, index.name, err)
+			}
+			return fmt.Errorf("creating the %s index on %s: %w", index.name, index.collection, err)
+		}
+	}
+	log.Printf("%d lookup indexes are in place", len(lookupIndexes))
+	return nil
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration is one versioned change to the indexes, validators or data of
+// the database. Each version runs once per database, in order, and is
+// recorded in the migrations collection when it succeeds. Up has to be safe
+// to run again, a run that fails halfway is retried from the start.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *mongo.Database) error
+}
+
+// Migrations lists every migration, oldest first. New ones are appended with
+// the next version; released versions are never renumbered or edited.
+func Migrations(currency string) []Migration {
+	return []Migration{
+		{1, "store money as integer minor units", func(db *mongo.Database) error {
+			return MigrateMoneyFields(db, currency)
+		}},
+		{2, "move portion sizes out of orderItem.quantity", MigrateOrderItemQuantities},
+		{3, "number invoices uniquely per restaurant", CreateInvoiceNumberIndex},
+		{4, "index the cursor paged lists", CreateListIndexes},
+		{5, "index ids and references, keep emails and phone numbers unique", CreateLookupIndexes},
+		{6, "backfill timestamps, order statuses and item quantities", BackfillDefaults},
+		{7, "validate documents with JSON schemas", ApplySchemaValidators},
+		{8, "let new accounts wait for a role", ApplySchemaValidators},
+	}
+}
+
+// ErrMigrationsLocked is returned while another instance applies migrations.
+var ErrMigrationsLocked = errors.New("migrations are being applied")
+
+// MigrationState is a migration and when it was applied, if it was.
+type MigrationState struct {
+	Migration
+	Applied_at *time.Time
+}
+
+// appliedMigration is the record a migration leaves in the collection.
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	Applied_at  time.Time `bson:"applied_at"`
+	Duration_ms int64     `bson:"duration_ms"`
+}
+
+const (
+	migrationsCollection = "migrations"
+	migrationLock        = "lock"
+	// staleLock is how long a lock is honoured. A run that crashed leaves its
+	// lock behind, the next run takes it over once it is this old.
+	staleLock = 30 * time.Minute
+)
+
+// Migrate applies the migrations that have not been applied yet. Instances
+// starting together take turns through a lock in the migrations collection;
+// the one that waits finds nothing left to do.
+func Migrate(db *mongo.Database, migrations []Migration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), staleLock)
+	defer cancel()
+
+	collection := db.Collection(migrationsCollection)
+	release, err := lockMigrations(ctx, collection)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	states, err := migrationStates(ctx, collection, migrations)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, state := range states {
+		if state.Applied_at != nil {
+			continue
+		}
+		pending++
+		log.Printf("applying migration %d: %s", state.Version, state.Description)
+		started := time.Now()
+		if err := state.Up(db); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", state.Version, state.Description, err)
+		}
+		record := appliedMigration{
+			Version:     state.Version,
+			Description: state.Description,
+			Applied_at:  time.Now(),
+			Duration_ms: time.Since(started).Milliseconds(),
+		}
+		if _, err := collection.InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("recording migration %d: %w", state.Version, err)
+		}
+	}
+	if pending == 0 {
+		log.Printf("the database is up to date at migration %d", len(migrations))
+	}
+	return nil
+}
+
+// MigrationStatus lists every migration and whether it was applied.
+func MigrationStatus(db *mongo.Database, migrations []Migration) ([]MigrationState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return migrationStates(ctx, db.Collection(migrationsCollection), migrations)
+}
+
+func migrationStates(ctx context.Context, collection *mongo.Collection, migrations []Migration) ([]MigrationState, error) {
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, fmt.Errorf("reading the applied migrations: %w", err)
+	}
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("reading the applied migrations: %w", err)
+	}
+	applied := map[int]time.Time{}
+	for _, record := range records {
+		applied[record.Version] = record.Applied_at
+		if record.Version > len(migrations) {
+			log.Printf("migration %d (%s) was applied by a newer version of this service", record.Version, record.Description)
+		}
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration %q has version %d, want %d", migration.Description, migration.Version, i+1)
+		}
+		states[i].Migration = migration
+		if at, ok := applied[migration.Version]; ok {
+			states[i].Applied_at = &at
+		}
+	}
+	return states, nil
+}
+
+// lockMigrations takes the migration lock, or a lock left behind for longer
+// than staleLock, and returns the function that releases it.
+func lockMigrations(ctx context.Context, collection *mongo.Collection) (func(), error) {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s/%d/%s", host, os.Getpid(), primitive.NewObjectID().Hex())
+	now := time.Now()
+
+	_, err := collection.InsertOne(ctx, bson.M{"_id": migrationLock, "owner": owner, "locked_at": now})
+	if mongo.IsDuplicateKeyError(err) {
+		filter := bson.M{"_id": migrationLock, "locked_at": bson.M{"$lt": now.Add(-staleLock)}}
+		update := bson.M{"$set": bson.M{"owner": owner, "locked_at": now}}
+		var held struct {
+			Owner     string    `bson:"owner"`
+			Locked_at time.Time `bson:"locked_at"`
+		}
+		err = collection.FindOneAndUpdate(ctx, filter, update).Decode(&held)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if findErr := collection.FindOne(ctx, bson.M{"_id": migrationLock}).Decode(&held); findErr == nil {
+				return nil, fmt.Errorf("%w by %s since %s", ErrMigrationsLocked, held.Owner, held.Locked_at.Format(time.RFC3339))
+			}
+			return nil, ErrMigrationsLocked
+		}
+		if err == nil {
+			log.Printf("took over the migration lock %s left behind at %s", held.Owner, held.Locked_at.Format(time.RFC3339))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": migrationLock, "owner": owner}); err != nil {
+			log.Printf("could not release the migration lock: %v", err)
+		}
+	}, nil
+}
+
+// WaitForMigrations applies the pending migrations at startup. When another
+// instance holds the lock it waits for that instance to finish, up to
+// timeout, so that no instance serves against an older schema.
+func WaitForMigrations(db *mongo.Database, migrations []Migration, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := Migrate(db, migrations)
+		if !errors.Is(err, ErrMigrationsLocked) || time.Now().After(deadline) {
+			return err
+		}
+		log.Printf("%v, waiting", err)
+		time.Sleep(5 * time.Second)
+	}
+}
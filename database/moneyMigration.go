@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// moneyFields are the amounts that used to be stored as plain floats.
+var moneyFields = []struct {
+	collection string
+	field      string
+}{
+	{"food", "price"},
+	{"orderItem", "unit_price"},
+}
+
+// MigrateMoneyFields rewrites float amounts into {amount: <minor units>,
+// currency} sub-documents. Documents that were already converted are skipped,
+// so it is safe to run more than once.
+func MigrateMoneyFields(db *mongo.Database, currency string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, money := range moneyFields {
+		filter := bson.M{money.field: bson.M{"$type": bson.A{"double", "int", "long", "decimal"}}}
+
+		// Go through Decimal128 so that e.g. 12.35 becomes 1235 and not 1234
+		minor := bson.D{{Key: "$toLong", Value: bson.D{{Key: "$round", Value: bson.A{
+			bson.D{{Key: "$multiply", Value: bson.A{bson.D{{Key: "$toDecimal", Value: "$" + money.field}}, 100}}},
+			0,
+		}}}}}
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: money.field, Value: bson.D{
+			{Key: "amount", Value: minor},
+			{Key: "currency", Value: currency},
+		}}}}}}
+
+		result, err := db.Collection(money.collection).UpdateMany(ctx, filter, update)
+		if err != nil {
+			return fmt.Errorf("migrating %s.%s: %w", money.collection, money.field, err)
+		}
+		log.Printf("converted %d %s.%s amounts to %s minor units", result.ModifiedCount, money.collection, money.field, currency)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrateOrderItemQuantities moves the S/M/L value older versions stored in
+// orderItem.quantity into portion_size, sets the quantity to 1 and captures
+// a unit price for items that never had one. Items without a recorded price
+// get the current food price, which is the best value still available.
+func MigrateOrderItemQuantities(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	orderItems := db.Collection("orderItem")
+
+	filter := bson.M{"quantity": bson.M{"$type": "string"}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "portion_size", Value: "$quantity"},
+		{Key: "quantity", Value: 1},
+	}}}}
+	result, err := orderItems.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("migrating orderItem.quantity: %w", err)
+	}
+	log.Printf("moved %d orderItem portion sizes out of quantity", result.ModifiedCount)
+
+	cursor, err := db.Collection("food").Find(ctx, bson.M{"price.amount": bson.M{"$exists": true}})
+	if err != nil {
+		return fmt.Errorf("loading food prices: %w", err)
+	}
+	var foods []struct {
+		Food_id string `bson:"food_id"`
+		Price   bson.M `bson:"price"`
+	}
+	if err := cursor.All(ctx, &foods); err != nil {
+		return fmt.Errorf("loading food prices: %w", err)
+	}
+
+	var priced int64
+	for _, food := range foods {
+		filter := bson.M{"food_id": food.Food_id, "unit_price": bson.M{"$in": bson.A{nil}}}
+		result, err := orderItems.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"unit_price": food.Price}})
+		if err != nil {
+			return fmt.Errorf("capturing unit prices for food %s: %w", food.Food_id, err)
+		}
+		priced += result.ModifiedCount
+	}
+	log.Printf("captured the unit price of %d orderItems", priced)
+	return nil
+}
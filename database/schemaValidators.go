@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Schema helpers for the fields the documents share.
+var (
+	schemaString = bson.M{"bsonType": "string"}
+	schemaInt    = bson.M{"bsonType": bson.A{"int", "long"}}
+	schemaDate   = bson.M{"bsonType": "date"}
+	schemaMoney  = bson.M{
+		"bsonType": "object",
+		"required": bson.A{"amount", "currency"},
+		"properties": bson.M{
+			"amount":   schemaInt,
+			"currency": bson.M{"bsonType": "string", "minLength": 3, "maxLength": 3},
+		},
+	}
+)
+
+// schema builds a $jsonSchema that requires the fields listed and the
+// timestamps every document carries.
+func schema(required []string, properties bson.M) bson.M {
+	properties["created_at"] = schemaDate
+	properties["updated_at"] = schemaDate
+	return bson.M{"$jsonSchema": bson.M{
+		"bsonType":   "object",
+		"required":   append(bson.A{"created_at"}, toA(required)...),
+		"properties": properties,
+	}}
+}
+
+func toA(values []string) bson.A {
+	a := make(bson.A, len(values))
+	for i, value := range values {
+		a[i] = value
+	}
+	return a
+}
+
+// schemaValidators reject documents missing their id or the references the
+// code relies on. They only hold what the application always writes, a field
+// it sets to null is not listed.
+var schemaValidators = map[string]bson.M{
+	"food": schema([]string{"food_id", "name", "price", "menu_id"}, bson.M{
+		"food_id": schemaString,
+		"name":    schemaString,
+		"price":   schemaMoney,
+		"menu_id": schemaString,
+	}),
+	"menu": schema([]string{"menu_id", "name"}, bson.M{
+		"menu_id": schemaString,
+		"name":    schemaString,
+	}),
+	"table": schema([]string{"table_id", "table_number", "number_of_guests"}, bson.M{
+		"table_id":         schemaString,
+		"table_number":     schemaInt,
+		"number_of_guests": schemaInt,
+	}),
+	"order": schema([]string{"order_id", "status"}, bson.M{
+		"order_id": schemaString,
+		"table_id": bson.M{"bsonType": bson.A{"string", "null"}},
+		"status": bson.M{"enum": bson.A{
+			models.OrderStatusOpen, models.OrderStatusFired, models.OrderStatusReady, models.OrderStatusServed,
+			models.OrderStatusClosed, models.OrderStatusCancelled, models.OrderStatusVoided,
+		}},
+	}),
+	"orderItem": schema([]string{"order_item_id", "order_id", "food_id", "quantity"}, bson.M{
+		"order_item_id": schemaString,
+		"order_id":      schemaString,
+		"food_id":       schemaString,
+		"quantity":      bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 1},
+		"portion_size":  bson.M{"enum": bson.A{"S", "M", "L"}},
+	}),
+	"invoice": schema([]string{"invoice_id", "order_id", "restaurant_id"}, bson.M{
+		"invoice_id":    schemaString,
+		"order_id":      schemaString,
+		"restaurant_id": schemaString,
+		"total":         schemaMoney,
+		"amount_due":    schemaMoney,
+	}),
+	"user": schema([]string{"user_id", "email", "password", "phone"}, bson.M{
+		"user_id":  schemaString,
+		"email":    schemaString,
+		"password": schemaString,
+		"phone":    schemaString,
+		"role": bson.M{"enum": bson.A{
+			nil, // new accounts wait for an admin to give them a role
+			models.RoleAdmin, models.RoleManager, models.RoleWaiter, models.RoleCashier, models.RoleKitchen,
+		}},
+	}),
+}
+
+// namespaceNotFound is the server error collMod answers with when the
+// collection does not exist yet.
+const namespaceNotFound = 26
+
+// ApplySchemaValidators sets the JSON schema validator of each collection,
+// creating the collections that do not exist yet. The validation level is
+// moderate: documents written by older versions that do not match can still
+// be updated, new and matching documents have to keep matching.
+func ApplySchemaValidators(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for name, validator := range schemaValidators {
+		command := bson.D{
+			{Key: "collMod", Value: name},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: "moderate"},
+			{Key: "validationAction", Value: "error"},
+		}
+		err := db.RunCommand(ctx, command).Err()
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && commandErr.Code == namespaceNotFound {
+			opts := options.CreateCollection().SetValidator(validator).SetValidationLevel("moderate").SetValidationAction("error")
+			err = db.CreateCollection(ctx, name, opts)
+		}
+		if err != nil {
+			return fmt.Errorf("setting the schema validator of %s: %w", name, err)
+		}
+	}
+	log.Printf("%d collections have schema validators", len(schemaValidators))
+	return nil
+}
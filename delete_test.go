@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteRestoreAndPurgeCatalog(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	manager := s.staff(admin, "manager@example.com", models.RoleManager)
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var food models.Food
+	s.expect(http.StatusOK, http.MethodGet, "/foods/"+foodId, admin.Token, nil, &food)
+	menuId := *food.Menu_id
+
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/menus/"+menuId, manager.Token, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/foods/"+foodId, manager.Token, nil, nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/foods/"+foodId, admin.Token, nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodDelete, "/foods/"+foodId, manager.Token, nil)
+	var foods listPage[models.Food]
+	s.expect(http.StatusOK, http.MethodGet, "/foods", admin.Token, nil, &foods)
+	if foods.Total != 0 || len(foods.Items) != 0 {
+		t.Fatalf("deleted food is still listed: %+v", foods)
+	}
+
+	// Only an admin restores, and only once the menu is back
+	s.expectError(http.StatusForbidden, helper.CodeForbidden, http.MethodPost, "/foods/"+foodId+"/restore", manager.Token, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/menus/"+menuId, manager.Token, nil, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/foods/"+foodId+"/restore", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/menus/"+menuId+"/restore", admin.Token, nil, nil)
+	var restored models.Food
+	s.expect(http.StatusOK, http.MethodPost, "/foods/"+foodId+"/restore", admin.Token, nil, &restored)
+	if restored.Deleted_at != nil || restored.Deleted_by != nil {
+		t.Fatalf("restored food is still marked deleted by %v at %v", restored.Deleted_by, restored.Deleted_at)
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/foods/"+foodId, admin.Token, nil, nil)
+
+	// Purging needs a soft delete first and cannot be undone
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodDelete, "/foods/"+foodId+"/purge", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/foods/"+foodId, admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/foods/"+foodId+"/purge", admin.Token, nil, nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodPost, "/foods/"+foodId+"/restore", admin.Token, nil)
+}
+
+func TestDeleteKeepsOrdersConsistent(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	place := func() (orderId string, itemId string) {
+		t.Helper()
+		var placed struct {
+			Order       models.Order
+			Order_items []models.OrderItem
+		}
+		s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+			"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+		}, &placed)
+		return placed.Order.Order_id, placed.Order_items[0].Order_item_id
+	}
+
+	// An invoiced order is corrected with a credit note, never deleted
+	orderId, itemId := place()
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": orderId})
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/orders/"+orderId, admin.Token, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/orderItems/"+itemId, admin.Token, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPatch, "/orderItems/"+itemId, admin.Token, gin.H{"quantity": 3})
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPatch, "/orderItems/"+itemId, admin.Token, gin.H{"portion_size": "L"})
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/invoices/"+invoiceId, admin.Token, nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodDelete, "/invoices/"+invoiceId+"/purge", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId, admin.Token, nil, nil)
+
+	// Items go before their order, and come back after it
+	orderId, itemId = place()
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/orders/"+orderId, admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/orderItems/"+itemId, admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/orders/"+orderId, admin.Token, nil, nil)
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/orders/"+orderId, admin.Token, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/orderItems/"+itemId+"/restore", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+orderId+"/restore", admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems/"+itemId+"/restore", admin.Token, nil, nil)
+}
+
+func TestPurgeKeepsWhatOrdersReferTo(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+	tableId := s.create(http.MethodPost, "/tables", admin.Token, gin.H{"number_of_guests": 4, "table_number": 3})
+
+	var food models.Food
+	s.expect(http.StatusOK, http.MethodGet, "/foods/"+foodId, admin.Token, nil, &food)
+	menuId := *food.Menu_id
+	var placed struct {
+		Order       models.Order
+		Order_items []models.OrderItem
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	orderId, itemId := placed.Order.Order_id, placed.Order_items[0].Order_item_id
+
+	// The order item still prices with the food and the category of its menu
+	s.expect(http.StatusOK, http.MethodDelete, "/foods/"+foodId, admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/menus/"+menuId, admin.Token, nil, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/foods/"+foodId+"/purge", admin.Token, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/menus/"+menuId+"/purge", admin.Token, nil)
+
+	// A voided order still shows the table it was taken at
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+orderId+"/void", admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/tables/"+tableId, admin.Token, nil, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/tables/"+tableId+"/purge", admin.Token, nil)
+
+	s.expect(http.StatusOK, http.MethodDelete, "/orderItems/"+itemId, admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/orders/"+orderId, admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/tables/"+tableId+"/purge", admin.Token, nil, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodDelete, "/menus/"+menuId+"/purge", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/foods/"+foodId+"/purge", admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/menus/"+menuId+"/purge", admin.Token, nil, nil)
+}
+
+func TestDeletedUsersAreSignedOut(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	waiter := s.staff(admin, "waiter@example.com", models.RoleWaiter)
+
+	s.expectError(http.StatusBadRequest, helper.CodeBadRequest, http.MethodDelete, "/users/"+admin.UserId, admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodDelete, "/users/"+waiter.UserId, admin.Token, nil, nil)
+
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", waiter.Token, nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeInvalidCredentials, http.MethodPost, "/users/login", "", gin.H{"email": "waiter@example.com", "Password": "secret-password"})
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/users/"+waiter.UserId, admin.Token, nil)
+	s.expectError(http.StatusConflict, helper.CodeAlreadyExists, http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      "waiter@example.com",
+		"Password":   "secret-password",
+		"phone":      "555-9999",
+	})
+
+	// A restored user signs in again, the old session stays revoked
+	s.expect(http.StatusOK, http.MethodPost, "/users/"+waiter.UserId+"/restore", admin.Token, nil, nil)
+	s.expectError(http.StatusUnauthorized, helper.CodeUnauthorized, http.MethodGet, "/foods", waiter.Token, nil)
+	waiter = s.login("waiter@example.com")
+	s.expect(http.StatusOK, http.MethodGet, "/foods", waiter.Token, nil, nil)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go-restaurant-management/repository"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestHealthProbesNeedNoLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	var health struct {
+		Status string
+		Checks map[string]string
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/healthz", "", nil, &health)
+	if health.Status != "ok" {
+		t.Fatalf("liveness reported %q, want ok", health.Status)
+	}
+
+	s.expect(http.StatusOK, http.MethodGet, "/readyz", "", nil, &health)
+	if health.Checks["mongo"] != "ok" {
+		t.Fatalf("readiness checks %v, want all ok", health.Checks)
+	}
+}
+
+func TestReadinessHidesWhyMongoFailed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI("mongodb://127.0.0.1:1/?replicaSet=rs-secret").
+		SetServerSelectionTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	s := newTestServerOn(t, testSettings(), repository.NewMongo(client.Database("restaurant")))
+
+	res := s.call(http.MethodGet, "/readyz", "", nil)
+	if res.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness answered %d with mongo down, want %d", res.Code, http.StatusServiceUnavailable)
+	}
+	var health struct {
+		Status string
+		Checks map[string]string
+	}
+	if err := json.Unmarshal(res.Body, &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.Checks["mongo"] != "unreachable" || strings.Contains(string(res.Body), "rs-secret") || strings.Contains(string(res.Body), "127.0.0.1") {
+		t.Fatalf("readiness showed %s, want only that mongo is unreachable", res.Body)
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/healthz", "", nil, nil)
+}
@@ -0,0 +1,175 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes clients can rely on. The messages next to them are meant for
+// people and may change.
+const (
+	CodeInvalidBody        = "invalid_body"
+	CodeValidationFailed   = "validation_failed"
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeAlreadyExists      = "already_exists"
+	CodeInternal           = "internal"
+	CodeUpstreamFailed     = "upstream_failed"
+	CodeUnavailable        = "unavailable"
+)
+
+var codeStatus = map[string]int{
+	CodeInvalidBody:        http.StatusBadRequest,
+	CodeValidationFailed:   http.StatusBadRequest,
+	CodeBadRequest:         http.StatusBadRequest,
+	CodeUnauthorized:       http.StatusUnauthorized,
+	CodeInvalidCredentials: http.StatusUnauthorized,
+	CodeForbidden:          http.StatusForbidden,
+	CodeNotFound:           http.StatusNotFound,
+	CodeConflict:           http.StatusConflict,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUpstreamFailed:     http.StatusBadGateway,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+}
+
+// APIError is the error every endpoint answers with. It is rendered as
+// {"error": {"code": ..., "message": ..., "details": [...]}}; the cause is
+// logged and never sent to the client.
+type APIError struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+	Cause   error        `json:"-"`
+}
+
+// FieldError explains why one field of the request was rejected. Field is
+// the JSON path, e.g. "order_items[1].portion_size".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Cause
+}
+
+// NewError builds an error with the status that belongs to the code.
+func NewError(code string, message string) *APIError {
+	status, ok := codeStatus[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *APIError {
+	return NewError(CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *APIError {
+	return NewError(CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *APIError {
+	return NewError(CodeForbidden, message)
+}
+
+func NotFound(message string) *APIError {
+	return NewError(CodeNotFound, message)
+}
+
+func Conflict(message string) *APIError {
+	return NewError(CodeConflict, message)
+}
+
+func Unavailable(message string) *APIError {
+	return NewError(CodeUnavailable, message)
+}
+
+// Internal hides the cause from the client; it is only logged.
+func Internal(message string, cause error) *APIError {
+	err := NewError(CodeInternal, message)
+	err.Cause = cause
+	return err
+}
+
+func UpstreamFailed(message string, cause error) *APIError {
+	err := NewError(CodeUpstreamFailed, message)
+	err.Cause = cause
+	return err
+}
+
+// InvalidBody reports a request body that could not be decoded.
+func InvalidBody(cause error) *APIError {
+	return NewError(CodeInvalidBody, cause.Error())
+}
+
+// Invalid reports rejected input. Errors from the validator are broken down
+// per field; any other error becomes the message.
+func Invalid(cause error) *APIError {
+	var fields validator.ValidationErrors
+	if !errors.As(cause, &fields) {
+		return NewError(CodeValidationFailed, cause.Error())
+	}
+
+	err := NewError(CodeValidationFailed, "the request has invalid fields")
+	for _, field := range fields {
+		err.Details = append(err.Details, describeField(field))
+	}
+	return err
+}
+
+func describeField(field validator.FieldError) FieldError {
+	// The namespace starts with the name of the validated struct
+	path := field.Namespace()
+	if _, rest, found := strings.Cut(path, "."); found {
+		path = rest
+	}
+
+	rule, message := field.Tag(), ""
+	switch {
+	case rule == "required":
+		message = "is required"
+	case rule == "email":
+		message = "must be a valid email address"
+	case rule == "min":
+		message = "must be at least " + field.Param()
+	case rule == "max":
+		message = "must be at most " + field.Param()
+	case rule == "dive":
+		message = "has an invalid element"
+	case rule == "eq":
+		message = "must be " + field.Param()
+	case strings.HasPrefix(rule, "eq="):
+		// eq=A|eq=B is how the models spell a list of allowed values
+		var allowed []string
+		for _, option := range strings.Split(rule, "|") {
+			allowed = append(allowed, strings.TrimPrefix(option, "eq="))
+		}
+		rule, message = "one_of", "must be one of "+strings.Join(allowed, ", ")
+	default:
+		message = "does not satisfy " + rule
+		if field.Param() != "" {
+			message += "=" + field.Param()
+		}
+	}
+	return FieldError{Field: path, Rule: rule, Message: message}
+}
@@ -0,0 +1,76 @@
+package helper
+
+import (
+	"sync"
+
+	"go-restaurant-management/models"
+)
+
+// KitchenEvent is pushed to kitchen screens whenever a ticket is created or changes.
+type KitchenEvent struct {
+	Type   string               `json:"type"`
+	Ticket models.KitchenTicket `json:"ticket"`
+}
+
+// KitchenBroker fans kitchen events out to the screens currently connected to
+// this process. Each subscriber can limit itself to a single station.
+type KitchenBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan KitchenEvent]string
+}
+
+func NewKitchenBroker() *KitchenBroker {
+	return &KitchenBroker{subscribers: make(map[chan KitchenEvent]string)}
+}
+
+// Kitchen is the broker shared by the order and kitchen handlers.
+var Kitchen = NewKitchenBroker()
+
+// Subscribe registers a screen for events of one station, or every station
+// when station is empty.
+func (b *KitchenBroker) Subscribe(station string) chan KitchenEvent {
+	ch := make(chan KitchenEvent, 16)
+
+	b.mu.Lock()
+	b.subscribers[ch] = station
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *KitchenBroker) Unsubscribe(ch chan KitchenEvent) {
+	b.mu.Lock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.mu.Unlock()
+}
+
+// Close ends every open stream, so that shutting down does not wait for
+// kitchen screens to disconnect.
+func (b *KitchenBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Publish never blocks; a screen that falls behind misses events and is
+// expected to reload the ticket list.
+func (b *KitchenBroker) Publish(event KitchenEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch, station := range b.subscribers {
+		if station != "" && station != event.Ticket.Station {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package helper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Every list endpoint reads the same query parameters:
+//
+//	page=2&limit=25           the page to return, limit is at most MaxListLimit
+//	sort=-created_at,name     fields to sort by, a leading - sorts descending
+//	status=OPEN,FIRED         exact filters, a comma separated list matches any
+//	price_min=5&price_max=20  inclusive ranges on numbers and amounts
+//	created_from=2026-01-01   ranges on times, _from inclusive and _to exclusive
+//	cursor=...                the page after next_cursor, where Keyset is set
+//
+// and answers with a ListResponse.
+
+const (
+	DefaultListLimit = 10
+	MaxListLimit     = 100
+)
+
+// FilterKind says how a filter parameter is read.
+type FilterKind int
+
+const (
+	// FilterExact matches the value, or any of a comma separated list.
+	FilterExact FilterKind = iota
+	// FilterNumber matches an integer exactly, or a range with _min and _max.
+	FilterNumber
+	// FilterMoney matches a range of decimal amounts with _min and _max.
+	FilterMoney
+	// FilterTime matches a range with _from and _to, given in RFC 3339 or
+	// as YYYY-MM-DD. A day given as _to includes the whole day.
+	FilterTime
+)
+
+// ListFilter is one filter a list endpoint accepts.
+type ListFilter struct {
+	Param string // query parameter, or its prefix for ranges
+	Field string // document field, the parameter when empty
+	Kind  FilterKind
+}
+
+// ListSpec describes what a list endpoint can be filtered and sorted by.
+type ListSpec struct {
+	Filters []ListFilter
+	// Sorts maps the names accepted by sort to document fields.
+	Sorts map[string]string
+	// Keyset offers cursors for the lists of collections too large to skip
+	// through. They follow creation order, created_at and then _id, either
+	// way, so rows inserted meanwhile never shift a page.
+	Keyset bool
+}
+
+// ListParams is a parsed list request.
+type ListParams struct {
+	repository.ListQuery
+	Page  int // 0 when paging by cursor
+	Limit int
+
+	request   *url.URL
+	direction int // of creation order when cursors can be handed out, else 0
+}
+
+// ListResponse is the envelope every list endpoint answers with. Next and
+// NextCursor are set when a cursor leads to more items. Total and Pages are
+// left out of the pages reached through a cursor: counting costs as much as
+// reading every match, and the first page already told them.
+type ListResponse[T any] struct {
+	Items      []T    `json:"items"`
+	Total      *int64 `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Pages      *int64 `json:"pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+// listCursor is what an opaque cursor holds: the position of the last item
+// handed out and the direction of the list.
+type listCursor struct {
+	Created   int64              `json:"t"` // milliseconds, the precision Mongo keeps
+	ID        primitive.ObjectID `json:"id"`
+	Direction int                `json:"d"`
+}
+
+// positioned is implemented by documents embedding models.BaseEntity.
+type positioned interface {
+	Position() (time.Time, primitive.ObjectID)
+}
+
+// NewListResponse wraps one page of items.
+func NewListResponse[T any](items []T, total int64, params ListParams) ListResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	response := ListResponse[T]{
+		Page:  params.Page,
+		Limit: params.Limit,
+	}
+	if !params.Uncounted {
+		pages := (total + int64(params.Limit) - 1) / int64(params.Limit)
+		response.Total, response.Pages = &total, &pages
+	}
+
+	// Keyset lists fetch one item more than asked to know if another page follows
+	if params.direction != 0 && len(items) > params.Limit {
+		items = items[:params.Limit]
+		if last, ok := any(items[len(items)-1]).(positioned); ok {
+			createdAt, id := last.Position()
+			response.NextCursor = encodeCursor(listCursor{Created: createdAt.UnixMilli(), ID: id, Direction: params.direction})
+			response.Next = nextLink(params.request, response.NextCursor)
+		}
+	}
+	response.Items = items
+	return response
+}
+
+// ParseList reads the paging, sorting and filter parameters of a list
+// request. Every rejected parameter is reported as a field of the error.
+func ParseList(request *url.URL, spec ListSpec) (ListParams, error) {
+	values := request.Query()
+	var invalid []FieldError
+	reject := func(param string, rule string, message string) {
+		invalid = append(invalid, FieldError{Field: param, Rule: rule, Message: message})
+	}
+
+	params := ListParams{Page: 1, Limit: DefaultListLimit, request: request}
+	if page := values.Get("page"); page != "" {
+		if number, err := strconv.Atoi(page); err != nil || number < 1 {
+			reject("page", "min", "must be a whole number of at least 1")
+		} else {
+			params.Page = number
+		}
+	}
+	// recordPerPage is still read for clients from before limit
+	limit := values.Get("limit")
+	if limit == "" {
+		limit = values.Get("recordPerPage")
+	}
+	if limit != "" {
+		if number, err := strconv.Atoi(limit); err != nil || number < 1 || number > MaxListLimit {
+			reject("limit", "range", fmt.Sprintf("must be a whole number from 1 to %d", MaxListLimit))
+		} else {
+			params.Limit = number
+		}
+	}
+	params.Skip = int64(params.Page-1) * int64(params.Limit)
+	params.ListQuery.Limit = int64(params.Limit)
+
+	if sort := values.Get("sort"); sort != "" {
+		for _, key := range strings.Split(sort, ",") {
+			direction := 1
+			if strings.HasPrefix(key, "-") {
+				key, direction = key[1:], -1
+			}
+			field, ok := spec.Sorts[key]
+			if !ok {
+				reject("sort", "one_of", "cannot sort by "+strconv.Quote(key)+", use one of "+strings.Join(sortNames(spec), ", "))
+				continue
+			}
+			params.Sort = append(params.Sort, bson.E{Key: field, Value: direction})
+		}
+	}
+
+	if spec.Keyset {
+		params.direction = creationDirection(params.Sort)
+	}
+	var cursor *listCursor
+	if encoded := values.Get("cursor"); encoded != "" {
+		decoded, err := decodeCursor(encoded)
+		switch {
+		case !spec.Keyset:
+			reject("cursor", "unsupported", "this list is paged with page and limit")
+		case err != nil:
+			reject("cursor", "cursor", "is not a cursor handed out by this list")
+		case values.Get("page") != "":
+			reject("cursor", "excluded_with", "cannot be combined with page")
+		case values.Get("sort") != "" && params.direction != decoded.Direction:
+			reject("cursor", "sort", "was handed out for a different sort")
+		default:
+			cursor = &decoded
+			params.direction = decoded.Direction
+			params.Sort = bson.D{{Key: "created_at", Value: decoded.Direction}}
+			params.Page, params.Skip = 0, 0
+			params.Uncounted = true
+		}
+	}
+	if params.direction != 0 {
+		params.ListQuery.Limit++
+	}
+
+	params.Filter = bson.M{}
+	for _, filter := range spec.Filters {
+		field := filter.Field
+		if field == "" {
+			field = filter.Param
+		}
+		condition, err := filter.condition(values)
+		if err != nil {
+			reject(err.param, err.rule, err.message)
+			continue
+		}
+		if condition != nil {
+			params.Filter[field] = condition
+		}
+	}
+	if cursor != nil {
+		params.Filter["$and"] = bson.A{cursor.after()}
+	}
+
+	if len(invalid) > 0 {
+		apiErr := NewError(CodeValidationFailed, "the list query has invalid parameters")
+		apiErr.Details = invalid
+		return params, apiErr
+	}
+	return params, nil
+}
+
+type filterError struct {
+	param, rule, message string
+}
+
+// condition returns the query condition for the filter, or nil when the
+// request does not use it.
+func (f ListFilter) condition(values url.Values) (interface{}, *filterError) {
+	switch f.Kind {
+	case FilterExact:
+		value := values.Get(f.Param)
+		if value == "" {
+			return nil, nil
+		}
+		if options := strings.Split(value, ","); len(options) > 1 {
+			return bson.M{"$in": options}, nil
+		}
+		return value, nil
+
+	case FilterNumber:
+		if value := values.Get(f.Param); value != "" {
+			number, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, &filterError{f.Param, "number", "must be a whole number"}
+			}
+			return number, nil
+		}
+		return rangeOf(values, f.Param+"_min", "$gte", f.Param+"_max", "$lte", func(param string, value string) (interface{}, *filterError) {
+			number, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, &filterError{param, "number", "must be a whole number"}
+			}
+			return number, nil
+		})
+
+	case FilterMoney:
+		return rangeOf(values, f.Param+"_min", "$gte", f.Param+"_max", "$lte", func(param string, value string) (interface{}, *filterError) {
+			amount, err := models.ParseMoney(value, "")
+			if err != nil {
+				return nil, &filterError{param, "amount", err.Error()}
+			}
+			return amount.Amount, nil
+		})
+
+	case FilterTime:
+		return rangeOf(values, f.Param+"_from", "$gte", f.Param+"_to", "$lt", func(param string, value string) (interface{}, *filterError) {
+			if at, err := time.Parse(time.RFC3339, value); err == nil {
+				return at, nil
+			}
+			day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return nil, &filterError{param, "time", "must be formatted as RFC 3339 or YYYY-MM-DD"}
+			}
+			if strings.HasSuffix(param, "_to") {
+				day = day.AddDate(0, 0, 1)
+			}
+			return day, nil
+		})
+	}
+	return nil, nil
+}
+
+// rangeOf builds a range condition from a pair of parameters.
+func rangeOf(values url.Values, lowParam string, lowOperator string, highParam string, highOperator string, parse func(param string, value string) (interface{}, *filterError)) (interface{}, *filterError) {
+	condition := bson.M{}
+	for _, bound := range []struct{ param, operator string }{{lowParam, lowOperator}, {highParam, highOperator}} {
+		value := values.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := parse(bound.param, value)
+		if err != nil {
+			return nil, err
+		}
+		condition[bound.operator] = parsed
+	}
+	if len(condition) == 0 {
+		return nil, nil
+	}
+	return condition, nil
+}
+
+// creationDirection returns 1 or -1 when the list is sorted by creation
+// alone, and 0 when cursors cannot follow the sort.
+func creationDirection(sort bson.D) int {
+	switch {
+	case len(sort) == 0:
+		return 1
+	case len(sort) == 1 && sort[0].Key == "created_at":
+		return sort[0].Value.(int)
+	}
+	return 0
+}
+
+// after matches the documents that come after the cursor.
+func (c listCursor) after() bson.M {
+	operator := "$gt"
+	if c.Direction < 0 {
+		operator = "$lt"
+	}
+	createdAt := time.UnixMilli(c.Created)
+	return bson.M{"$or": bson.A{
+		bson.M{"created_at": bson.M{operator: createdAt}},
+		bson.M{"created_at": createdAt, "_id": bson.M{operator: c.ID}},
+	}}
+}
+
+func encodeCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.ID.IsZero() || (cursor.Direction != 1 && cursor.Direction != -1) {
+		return cursor, fmt.Errorf("incomplete cursor")
+	}
+	return cursor, nil
+}
+
+// nextLink is the request again, asking for the page after the cursor.
+func nextLink(request *url.URL, cursor string) string {
+	if request == nil {
+		return ""
+	}
+	query := request.Query()
+	query.Del("page")
+	query.Set("cursor", cursor)
+	return request.Path + "?" + query.Encode()
+}
+
+func sortNames(spec ListSpec) []string {
+	names := make([]string, 0, len(spec.Sorts))
+	for name := range spec.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,158 @@
+package helper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"go-restaurant-management/models"
+)
+
+// Card tokens understood by the mock gateway. Any other token is approved
+// like MockCardApproved.
+const (
+	MockCardApproved    = "tok_visa"
+	MockCardDeclined    = "tok_declined"
+	MockCardCaptureFail = "tok_capture_fail"
+	MockCardPending     = "tok_pending"
+)
+
+// MockGateway is an in-process payment provider for development and tests.
+// Its outcome depends only on the card token, and references are numbered in
+// order, so the same calls always give the same answers. Webhooks are signed
+// with HMAC-SHA256 of the body using the shared secret.
+type MockGateway struct {
+	mu             sync.Mutex
+	secret         []byte
+	sequence       int
+	authorizations map[string]*mockAuthorization
+}
+
+type mockAuthorization struct {
+	cardToken string
+	amount    models.Money
+	captured  models.Money
+	refunded  models.Money
+	voided    bool
+}
+
+func NewMockGateway(webhookSecret string) *MockGateway {
+	return &MockGateway{
+		secret:         []byte(webhookSecret),
+		authorizations: make(map[string]*mockAuthorization),
+	}
+}
+
+func (g *MockGateway) Name() string {
+	return "mock"
+}
+
+func (g *MockGateway) Authorize(ctx context.Context, amount models.Money, cardToken string) (ProviderResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sequence++
+	reference := fmt.Sprintf("mock_%06d", g.sequence)
+	if cardToken == MockCardDeclined {
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "card declined"}, nil
+	}
+	if amount.Amount <= 0 {
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "amount must be greater than zero"}, nil
+	}
+
+	zero := models.NewMoney(0, amount.Currency)
+	g.authorizations[reference] = &mockAuthorization{cardToken: cardToken, amount: amount, captured: zero, refunded: zero}
+	return ProviderResult{Reference: reference, Status: ProviderApproved}, nil
+}
+
+func (g *MockGateway) Capture(ctx context.Context, reference string, amount models.Money) (ProviderResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	authorization, ok := g.authorizations[reference]
+	if !ok {
+		return ProviderResult{}, fmt.Errorf("unknown authorization %s", reference)
+	}
+	switch {
+	case authorization.voided:
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "authorization was voided"}, nil
+	case !authorization.captured.IsZero():
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "authorization was already captured"}, nil
+	case amount.Amount > authorization.amount.Amount:
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "capture exceeds the authorized amount"}, nil
+	case authorization.cardToken == MockCardCaptureFail:
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "capture failed"}, nil
+	}
+
+	authorization.captured = amount
+	if authorization.cardToken == MockCardPending {
+		return ProviderResult{Reference: reference, Status: ProviderPending}, nil
+	}
+	return ProviderResult{Reference: reference, Status: ProviderApproved}, nil
+}
+
+func (g *MockGateway) Refund(ctx context.Context, reference string, amount models.Money) (ProviderResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	authorization, ok := g.authorizations[reference]
+	if !ok {
+		return ProviderResult{}, fmt.Errorf("unknown authorization %s", reference)
+	}
+	if amount.Amount <= 0 || authorization.refunded.Amount+amount.Amount > authorization.captured.Amount {
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "refund exceeds the captured amount"}, nil
+	}
+
+	authorization.refunded = authorization.refunded.Add(amount)
+	return ProviderResult{Reference: reference, Status: ProviderApproved}, nil
+}
+
+func (g *MockGateway) Void(ctx context.Context, reference string) (ProviderResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	authorization, ok := g.authorizations[reference]
+	if !ok {
+		return ProviderResult{}, fmt.Errorf("unknown authorization %s", reference)
+	}
+	if !authorization.captured.IsZero() {
+		return ProviderResult{Reference: reference, Status: ProviderDeclined, Reason: "captured payments must be refunded"}, nil
+	}
+
+	authorization.voided = true
+	return ProviderResult{Reference: reference, Status: ProviderApproved}, nil
+}
+
+// ParseWebhook checks the signature of a callback and decodes it.
+func (g *MockGateway) ParseWebhook(payload []byte, signature string) (WebhookEvent, error) {
+	var event WebhookEvent
+	if len(g.secret) == 0 || !hmac.Equal([]byte(g.sign(payload)), []byte(signature)) {
+		return event, ErrInvalidWebhookSignature
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// Webhook builds the signed callback the gateway sends when a pending capture
+// settles, as the body and the signature header value.
+func (g *MockGateway) Webhook(eventType string, reference string, reason string) ([]byte, string) {
+	g.mu.Lock()
+	g.sequence++
+	id := fmt.Sprintf("evt_%06d", g.sequence)
+	g.mu.Unlock()
+
+	payload, _ := json.Marshal(WebhookEvent{Id: id, Type: eventType, Reference: reference, Reason: reason})
+	return payload, g.sign(payload)
+}
+
+func (g *MockGateway) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
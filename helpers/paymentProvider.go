@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"context"
+	"errors"
+
+	"go-restaurant-management/models"
+)
+
+// Outcomes a payment provider reports for an operation.
+const (
+	ProviderApproved = "APPROVED"
+	ProviderDeclined = "DECLINED"
+	ProviderPending  = "PENDING"
+)
+
+// Webhook event types sent by providers once a pending operation settles.
+const (
+	WebhookPaymentCaptured = "payment.captured"
+	WebhookPaymentFailed   = "payment.failed"
+)
+
+// ErrInvalidWebhookSignature is returned for callbacks that were not signed by the provider.
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// ProviderResult is the answer of the provider to one operation. Declines are
+// results, not errors; errors mean the provider could not be asked.
+type ProviderResult struct {
+	Reference string
+	Status    string
+	Reason    string
+}
+
+// WebhookEvent is a verified callback from the provider.
+type WebhookEvent struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason"`
+}
+
+// PaymentProvider is a card payment gateway. Amounts are authorized first and
+// captured separately; captured amounts can be refunded, authorizations that
+// were never captured can be voided.
+type PaymentProvider interface {
+	Name() string
+	Authorize(ctx context.Context, amount models.Money, cardToken string) (ProviderResult, error)
+	Capture(ctx context.Context, reference string, amount models.Money) (ProviderResult, error)
+	Refund(ctx context.Context, reference string, amount models.Money) (ProviderResult, error)
+	Void(ctx context.Context, reference string) (ProviderResult, error)
+	ParseWebhook(payload []byte, signature string) (WebhookEvent, error)
+}
+
+// Payments is the provider card payments go through. main replaces it with
+// one configured from the environment.
+var Payments PaymentProvider = NewMockGateway("")
@@ -0,0 +1,213 @@
+package helper
+
+import (
+	"fmt"
+
+	"go-restaurant-management/models"
+)
+
+// PricingLine is one ordered item handed to the pricing engine.
+type PricingLine struct {
+	Order_item_id string
+	Name          string
+	Category      string
+	Quantity      int64
+	Unit_price    models.Money
+}
+
+// PriceLines works out the invoice breakdown for the given lines:
+//
+//  1. the subtotal is the sum of unit price times quantity;
+//  2. discounts are applied in order to what is left of the subtotal and
+//     spread over the lines in proportion to their totals;
+//  3. the service charge is a percentage of the discounted subtotal;
+//  4. tax is computed per rate on the discounted lines (and the service
+//     charge when it is taxable), either added on top or, for tax-inclusive
+//     prices, extracted from the amounts.
+//
+// All rounding is half away from zero on minor units. The lines and amount
+// discounts have to be in one currency, ErrCurrencyMismatch is returned when
+// they are not.
+func PriceLines(lines []PricingLine, rules models.PricingRules, discounts []models.Discount) (models.InvoiceBreakdown, error) {
+	currency := models.DefaultCurrency
+	if len(lines) > 0 && lines[0].Unit_price.Currency != "" {
+		currency = lines[0].Unit_price.Currency
+	}
+	zero := models.NewMoney(0, currency)
+	for _, line := range lines {
+		if _, err := zero.CheckedAdd(line.Unit_price); err != nil {
+			return models.InvoiceBreakdown{}, fmt.Errorf("pricing %s: %w", line.Name, err)
+		}
+	}
+	for _, discount := range discounts {
+		if discount.Type != models.DiscountAmount || discount.Amount == nil {
+			continue
+		}
+		if _, err := zero.CheckedAdd(*discount.Amount); err != nil {
+			return models.InvoiceBreakdown{}, fmt.Errorf("discount %s: %w", discount.Name, err)
+		}
+	}
+
+	breakdown := models.InvoiceBreakdown{
+		Lines:          make([]models.PricedLine, 0, len(lines)),
+		Subtotal:       zero,
+		Discounts:      []models.DiscountLine{},
+		Discount_total: zero,
+		Service_charge: zero,
+		Taxes:          []models.TaxLine{},
+		Tax_total:      zero,
+		Tax_inclusive:  rules.Tax_inclusive,
+	}
+
+	for _, line := range lines {
+		tax := taxRateFor(rules, line.Category)
+		lineTotal := line.Unit_price.Multiply(line.Quantity)
+		breakdown.Lines = append(breakdown.Lines, models.PricedLine{
+			Order_item_id: line.Order_item_id,
+			Name:          line.Name,
+			Category:      line.Category,
+			Quantity:      line.Quantity,
+			Unit_price:    line.Unit_price,
+			Line_total:    lineTotal,
+			Discount:      zero,
+			Tax_name:      tax.Name,
+			Tax_rate_bps:  tax.Rate_bps,
+		})
+		breakdown.Subtotal = breakdown.Subtotal.Add(lineTotal)
+	}
+
+	// Discounts never take the subtotal below zero
+	remaining := breakdown.Subtotal
+	for _, discount := range discounts {
+		var amount models.Money
+		switch discount.Type {
+		case models.DiscountPercent:
+			amount = applyRate(remaining, discount.Percent_bps)
+		case models.DiscountAmount:
+			if discount.Amount != nil {
+				amount = zero.Add(*discount.Amount)
+			}
+		}
+		if amount.Amount > remaining.Amount {
+			amount.Amount = remaining.Amount
+		}
+		if amount.Amount <= 0 {
+			continue
+		}
+
+		remaining = remaining.Sub(amount)
+		breakdown.Discount_total = breakdown.Discount_total.Add(amount)
+		breakdown.Discounts = append(breakdown.Discounts, models.DiscountLine{Name: discount.Name, Amount: amount})
+	}
+	allocateDiscount(breakdown.Lines, breakdown.Discount_total, breakdown.Subtotal)
+
+	breakdown.Service_charge = applyRate(remaining, rules.Service_charge_bps)
+
+	// Group the discounted amounts by tax rate
+	var taxes []models.TaxLine
+	addTaxable := func(name string, rateBps int64, amount models.Money) {
+		for i := range taxes {
+			if taxes[i].Name == name && taxes[i].Rate_bps == rateBps {
+				taxes[i].Taxable = taxes[i].Taxable.Add(amount)
+				return
+			}
+		}
+		taxes = append(taxes, models.TaxLine{Name: name, Rate_bps: rateBps, Taxable: amount, Tax: zero})
+	}
+	for _, line := range breakdown.Lines {
+		addTaxable(line.Tax_name, line.Tax_rate_bps, line.Line_total.Sub(line.Discount))
+	}
+	if rules.Service_charge_taxable && !breakdown.Service_charge.IsZero() {
+		tax := taxRateFor(rules, "")
+		addTaxable(tax.Name, tax.Rate_bps, breakdown.Service_charge)
+	}
+
+	for i := range taxes {
+		if rules.Tax_inclusive {
+			// The amounts already contain the tax: net = gross / (1 + rate)
+			gross := taxes[i].Taxable
+			net := divideRound(gross.Amount*models.BasisPoints, models.BasisPoints+taxes[i].Rate_bps)
+			taxes[i].Taxable = models.NewMoney(net, currency)
+			taxes[i].Tax = gross.Sub(taxes[i].Taxable)
+		} else {
+			taxes[i].Tax = applyRate(taxes[i].Taxable, taxes[i].Rate_bps)
+		}
+		if taxes[i].Rate_bps > 0 {
+			breakdown.Taxes = append(breakdown.Taxes, taxes[i])
+			breakdown.Tax_total = breakdown.Tax_total.Add(taxes[i].Tax)
+		}
+	}
+
+	breakdown.Total = remaining.Add(breakdown.Service_charge)
+	if !rules.Tax_inclusive {
+		breakdown.Total = breakdown.Total.Add(breakdown.Tax_total)
+	}
+	return breakdown, nil
+}
+
+// taxRateFor returns the tax rate of a menu category, falling back to the default rate.
+func taxRateFor(rules models.PricingRules, category string) models.TaxRate {
+	tax := rules.Default_tax
+	for _, categoryTax := range rules.Category_taxes {
+		if category != "" && categoryTax.Category == category {
+			tax = categoryTax.Tax
+			break
+		}
+	}
+	if tax.Name == "" {
+		tax.Name = "Tax"
+	}
+	return tax
+}
+
+// allocateDiscount spreads the discount over the lines in proportion to their
+// totals. The cents lost to rounding go to the largest line.
+func allocateDiscount(lines []models.PricedLine, discount models.Money, subtotal models.Money) {
+	if discount.Amount <= 0 || subtotal.Amount <= 0 {
+		return
+	}
+
+	allocated := int64(0)
+	for i := range lines {
+		share := lines[i].Line_total.Amount * discount.Amount / subtotal.Amount
+		lines[i].Discount = models.NewMoney(share, discount.Currency)
+		allocated += share
+	}
+
+	for allocated < discount.Amount {
+		largest := -1
+		for i := range lines {
+			if lines[i].Discount.Amount >= lines[i].Line_total.Amount {
+				continue
+			}
+			if largest == -1 || lines[i].Line_total.Amount > lines[largest].Line_total.Amount {
+				largest = i
+			}
+		}
+		if largest == -1 {
+			return
+		}
+		lines[largest].Discount.Amount++
+		allocated++
+	}
+}
+
+// ShareOf returns the part/whole share of an amount, rounded half away from zero.
+func ShareOf(amount models.Money, part int64, whole int64) models.Money {
+	if whole == 0 {
+		return models.NewMoney(0, amount.Currency)
+	}
+	return models.NewMoney(divideRound(amount.Amount*part, whole), amount.Currency)
+}
+
+// applyRate returns amount * rate_bps / 10000, rounded half away from zero.
+func applyRate(amount models.Money, rateBps int64) models.Money {
+	return models.NewMoney(divideRound(amount.Amount*rateBps, models.BasisPoints), amount.Currency)
+}
+
+func divideRound(numerator int64, denominator int64) int64 {
+	if numerator < 0 {
+		return -divideRound(-numerator, denominator)
+	}
+	return (numerator + denominator/2) / denominator
+}
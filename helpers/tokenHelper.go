@@ -1,19 +1,12 @@
 package helper
 
 import (
-	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
-	"go-restaurant-management/database"
-
 	jwt "github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SignedDetails struct {
@@ -21,32 +14,51 @@ type SignedDetails struct {
 	First_name string
 	Last_name  string
 	Uid        string
+	Role       string
+	Token_type string
 	jwt.StandardClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+// Token types carried in Token_type. The refresh key defaults to the access
+// key, so the type is what keeps one token from being presented as the other.
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+// main sets the keys and lifetimes from the configuration.
+var SECRET_KEY string
+var REFRESH_SECRET_KEY string
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
+var AccessTokenTTL = 24 * time.Hour
+var RefreshTokenTTL = 168 * time.Hour
 
-func GenerateAllTokens(email string, firstName string, lastName string, uid string) (signedToken string, signedRefreshToken string, err error) {
+func GenerateAllTokens(email string, firstName string, lastName string, uid string, role string) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Email:      email,
 		First_name: firstName,
 		Last_name:  lastName,
 		Uid:        uid,
+		Role:       role,
+		Token_type: AccessToken,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			Id:        primitive.NewObjectID().Hex(),
+			ExpiresAt: time.Now().Local().Add(AccessTokenTTL).Unix(),
 		},
 	}
 
+	// the refresh token only identifies the user, the unique id keeps every rotated token distinct
 	refreshClaims := &SignedDetails{
+		Uid:        uid,
+		Token_type: RefreshToken,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
+			Id:        primitive.NewObjectID().Hex(),
+			ExpiresAt: time.Now().Local().Add(RefreshTokenTTL).Unix(),
 		},
 	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(REFRESH_SECRET_KEY))
 
 	if err != nil {
 		log.Panic(err)
@@ -56,64 +68,52 @@ func GenerateAllTokens(email string, firstName string, lastName string, uid stri
 	return token, refreshToken, err
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
-
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-
-	var updateObj primitive.D
-
-	updateObj = append(updateObj, bson.E{"token", signedToken})
-	updateObj = append(updateObj, bson.E{"refresh_token", signedRefreshToken})
-
-	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
-
-	upsert := true
-	filter := bson.M{"user_id": userId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+// ValidateToken checks an access token. Access tokens issued before token
+// types existed have none; they still have to be the token on record.
+func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = validateSignedToken(signedToken, SECRET_KEY)
+	if claims != nil && claims.Token_type != AccessToken && claims.Token_type != "" {
+		return nil, "the token is not an access token"
 	}
+	return claims, msg
+}
 
-	_, err := userCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{"$set", updateObj},
-		},
-		&opt,
-	)
-	defer cancel()
-
-	if err != nil {
-		log.Panic(err)
-		return
+// ValidateRefreshToken checks a token presented to the refresh endpoint.
+func ValidateRefreshToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = validateSignedToken(signedToken, REFRESH_SECRET_KEY)
+	if claims != nil && claims.Token_type != RefreshToken {
+		return nil, "the token is not a refresh token"
 	}
-	return
+	return claims, msg
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
-
+func validateSignedToken(signedToken string, key string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return []byte(key), nil
 		},
 	)
+	if err != nil {
+		msg = err.Error()
+		return nil, msg
+	}
 
 	//the token is invalid
 	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
+	if !ok || !token.Valid {
 		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
-		return
+		return nil, msg
 	}
 
 	//the token is expired
 	if claims.ExpiresAt < time.Now().Local().Unix() {
 		msg = fmt.Sprint("token is expired")
-		msg = err.Error()
-		return
+		return nil, msg
 	}
 	return claims, msg
 }
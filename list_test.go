@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListPagingSortingAndFilters(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+
+	lunch := s.create(http.MethodPost, "/menus", admin.Token, gin.H{"name": "Lunch", "category": "Mains"})
+	dinner := s.create(http.MethodPost, "/menus", admin.Token, gin.H{"name": "Dinner", "category": "Mains"})
+	for _, food := range []struct{ name, price, menuId string }{
+		{"Soup", "5.00", lunch},
+		{"Burger", "12.50", lunch},
+		{"Steak", "20.00", dinner},
+	} {
+		s.create(http.MethodPost, "/foods", admin.Token, gin.H{
+			"name":       food.name,
+			"price":      food.price,
+			"food_image": "food.png",
+			"menu_id":    food.menuId,
+		})
+	}
+
+	list := func(query string) listPage[models.Food] {
+		t.Helper()
+		var page listPage[models.Food]
+		s.expect(http.StatusOK, http.MethodGet, "/foods"+query, admin.Token, nil, &page)
+		return page
+	}
+	names := func(page listPage[models.Food]) []string {
+		found := []string{}
+		for _, food := range page.Items {
+			found = append(found, *food.Name)
+		}
+		return found
+	}
+
+	first := list("?limit=2")
+	if first.Total != 3 || first.Pages != 2 || first.Limit != 2 || len(first.Items) != 2 {
+		t.Fatalf("first page is %+v, want 2 of 3 items on 2 pages", first)
+	}
+	if got := names(list("?limit=2&page=2")); len(got) != 1 || got[0] != "Steak" {
+		t.Fatalf("second page lists %v, want [Steak]", got)
+	}
+	if got := names(list("?sort=-price")); got[0] != "Steak" || got[2] != "Soup" {
+		t.Fatalf("sorted by price descending: %v", got)
+	}
+	if got := names(list("?price_min=10&price_max=15")); len(got) != 1 || got[0] != "Burger" {
+		t.Fatalf("price between 10 and 15 lists %v, want [Burger]", got)
+	}
+	if page := list("?menu_id=" + lunch); page.Total != 2 {
+		t.Fatalf("lunch menu lists %d foods, want 2", page.Total)
+	}
+	if page := list("?name=Soup,Steak&sort=name"); page.Total != 2 || *page.Items[0].Name != "Soup" {
+		t.Fatalf("names Soup and Steak list %v", names(page))
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	if page := list("?created_from=" + tomorrow); page.Total != 0 {
+		t.Fatalf("foods created from tomorrow: %v", names(page))
+	}
+	if page := list("?created_to=" + tomorrow); page.Total != 3 {
+		t.Fatalf("foods created until tomorrow: %v", names(page))
+	}
+
+	var menus listPage[models.Menu]
+	s.expect(http.StatusOK, http.MethodGet, "/menus?sort=name", admin.Token, nil, &menus)
+	if menus.Total != 2 || menus.Items[0].Name != "Dinner" {
+		t.Fatalf("menus sorted by name: %+v", menus.Items)
+	}
+}
+
+func TestListRejectsInvalidParameters(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+
+	apiErr := s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/foods?page=0&limit=1000&sort=calories&price_min=cheap", admin.Token, nil)
+	fields := map[string]bool{}
+	for _, detail := range apiErr.Details {
+		fields[detail.Field] = true
+	}
+	for _, field := range []string{"page", "limit", "sort", "price_min"} {
+		if !fields[field] {
+			t.Errorf("no detail for %s in %+v", field, apiErr.Details)
+		}
+	}
+
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/orders?created_from=yesterday", admin.Token, nil)
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/tables?table_number=seven", admin.Token, nil)
+}
+
+func TestCursorPagingIsStableUnderInserts(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	tableId := s.create(http.MethodPost, "/tables", admin.Token, gin.H{"number_of_guests": 4, "table_number": 1})
+	newOrder := func() string {
+		t.Helper()
+		return s.create(http.MethodPost, "/orders", admin.Token, gin.H{"table_id": tableId, "order_date": time.Now()})
+	}
+	var created []string
+	for i := 0; i < 5; i++ {
+		created = append(created, newOrder())
+	}
+
+	type orderPage struct {
+		listPage[models.Order]
+		Total       *int64
+		Next_cursor string
+		Next        string
+	}
+	walk := func(path string, insertAfterFirst bool) []string {
+		t.Helper()
+		var seen []string
+		for path != "" {
+			var page orderPage
+			s.expect(http.StatusOK, http.MethodGet, path, admin.Token, nil, &page)
+			for _, order := range page.Items {
+				seen = append(seen, order.Order_id)
+			}
+			if (page.Next == "") != (page.Next_cursor == "") {
+				t.Fatalf("next %q and next_cursor %q disagree", page.Next, page.Next_cursor)
+			}
+			if afterCursor := len(seen) > len(page.Items); afterCursor != (page.Total == nil) {
+				t.Fatalf("%s: got total %v, want one on the first page only", path, page.Total)
+			}
+			if insertAfterFirst {
+				created = append(created, newOrder())
+				insertAfterFirst = false
+			}
+			path = page.Next
+		}
+		return seen
+	}
+
+	// Oldest first, an order placed meanwhile shows up at the end
+	seen := walk("/orders?limit=2", true)
+	if len(seen) != 6 || seen[0] != created[0] || seen[5] != created[5] {
+		t.Fatalf("walked %v, want %v", seen, created)
+	}
+
+	// Newest first, an order placed meanwhile neither repeats nor shifts a page
+	seen = walk("/orders?limit=2&sort=-created_at", true)
+	if len(seen) != 6 || seen[0] != created[5] || seen[5] != created[0] {
+		t.Fatalf("walked %v, want %v reversed", seen, created[:6])
+	}
+	unique := map[string]bool{}
+	for _, id := range seen {
+		unique[id] = true
+	}
+	if len(unique) != len(seen) {
+		t.Fatalf("an order was listed twice: %v", seen)
+	}
+
+	var first orderPage
+	s.expect(http.StatusOK, http.MethodGet, "/orders?limit=2", admin.Token, nil, &first)
+	cursor := first.Next_cursor
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/orders?cursor="+cursor+"&page=2", admin.Token, nil)
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/orders?cursor="+cursor+"&sort=-created_at", admin.Token, nil)
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/orders?cursor=not-a-cursor", admin.Token, nil)
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodGet, "/foods?cursor="+cursor, admin.Token, nil)
+}
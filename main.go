@@ -1,36 +1,202 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"go-restaurant-management/config"
+	controller "go-restaurant-management/controllers"
+	"go-restaurant-management/database"
+	helper "go-restaurant-management/helpers"
 	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
+	"go-restaurant-management/printer"
+	"go-restaurant-management/repository"
 	routes "go-restaurant-management/routes"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
-	port := os.Getenv("PORT")
+	// The configuration is loaded and validated before the database connects
+	settings, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	models.DefaultCurrency = settings.Restaurant.Currency
+	models.RestaurantId = settings.Restaurant.Id
+	models.FiscalYearStart = time.Month(settings.Restaurant.FiscalYearStartMonth)
+	models.RestaurantName = settings.Restaurant.Name
+	models.RestaurantAddress = settings.Restaurant.Address
+	helper.SECRET_KEY = settings.Auth.SecretKey
+	helper.REFRESH_SECRET_KEY = settings.Auth.RefreshSecretKey
+	helper.AccessTokenTTL = settings.Auth.AccessTokenTTL.Duration
+	helper.RefreshTokenTTL = settings.Auth.RefreshTokenTTL.Duration
+
+	client, err := database.Connect(settings.Mongo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db := client.Database(settings.Mongo.Database)
+
+	// `migrate` applies the pending migrations and exits, `migrate status`
+	// lists them.
+	migrations := database.Migrations(models.DefaultCurrency)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if len(os.Args) > 2 && os.Args[2] == "status" {
+			err = printMigrationStatus(db, migrations)
+		} else {
+			err = database.Migrate(db, migrations)
+		}
+		disconnect(client)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// `grant-admin <email>` makes a signed up account an admin, which is how
+	// the first admin is made.
+	if len(os.Args) > 1 && os.Args[1] == "grant-admin" {
+		if len(os.Args) != 3 {
+			log.Fatal("usage: grant-admin <email>")
+		}
+		err = grantAdmin(repository.NewMongo(db).Users, os.Args[2])
+		disconnect(client)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s is an admin", os.Args[2])
+		return
+	}
+	if settings.Mongo.MigrateOnStart {
+		if err := database.WaitForMigrations(db, migrations, 10*time.Minute); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Card payments go through the mock gateway until a real provider is configured
+	helper.Payments = helper.NewMockGateway(settings.Payments.WebhookSecret)
+
+	// e.g. PRINTERS="receipt=tcp://10.0.0.20:9100,grill=file:///var/spool/grill"
+	if err := printer.Printers.RegisterList(settings.Printers); err != nil {
+		log.Fatal(err)
+	}
+
+	router := newRouter(controller.New(repository.NewMongo(db)), settings)
+
+	server := &http.Server{
+		Addr:              ":" + settings.Port,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Kitchen screens keep their stream open, so end them instead of waiting
+	server.RegisterOnShutdown(helper.Kitchen.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	log.Printf("listening on %s", server.Addr)
+
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	// A second signal stops the process without waiting
+	stop()
+
+	log.Printf("shutting down, in-flight requests have %s to finish", settings.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("requests still running were dropped: %v", err)
+	}
+	disconnect(client)
+	log.Println("stopped")
+}
+
+// disconnect closes the connection pool, giving up after a few seconds.
+func disconnect(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Disconnect(ctx); err != nil {
+		log.Printf("could not disconnect from MongoDB: %v", err)
+	}
+}
 
-	if port == "" {
-		port = "8000"
+// printMigrationStatus lists every migration and when it was applied.
+func printMigrationStatus(db *mongo.Database, migrations []database.Migration) error {
+	states, err := database.MigrationStatus(db, migrations)
+	if err != nil {
+		return err
 	}
+	for _, state := range states {
+		applied := "pending"
+		if state.Applied_at != nil {
+			applied = "applied " + state.Applied_at.Local().Format(time.RFC3339)
+		}
+		fmt.Printf("%3d  %-28s  %s\n", state.Version, applied, state.Description)
+	}
+	return nil
+}
+
+// grantAdmin gives the account signed up with email the admin role.
+func grantAdmin(users repository.UserRepository, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := users.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no account signed up with %s", email)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = users.SetRole(ctx, user.User_id, models.RoleAdmin)
+	return err
+}
 
+// newRouter registers every route on a new engine. The health probes come
+// first so they are not logged, then the user routes and the payment webhook,
+// which come before Authentication, then everything else, which needs an
+// account with a role.
+func newRouter(h *controller.Handler, settings config.Config) *gin.Engine {
 	router := gin.New()
+	routes.HealthRoutes(router, h)
 	router.Use(gin.Logger())
-	routes.UserRoutes(router)
-	router.Use(middleware.Authentication())
-
-	routes.FoodRoutes(router)
-	routes.MenuRoutes(router)
-	routes.TableRoutes(router)
-	routes.OrderRoutes(router)
-	routes.OrderItemRoutes(router)
-	routes.InvoiceRoutes(router)
-
-	router.Run(":" + port)
-	//err := router.Run(":" + port)
-	//if err != nil {
-	//	return
-	//}
+	router.Use(middleware.Errors())
+	router.Use(middleware.Recovery())
+	router.NoRoute(middleware.NoRoute())
+	router.Use(middleware.CORS(settings.CORS))
+	routes.UserRoutes(router, h)
+	routes.PaymentWebhookRoutes(router, h)
+	router.Use(middleware.Authentication(h.Users))
+	router.Use(middleware.Authorize(models.StaffRoles...))
+
+	routes.FoodRoutes(router, h)
+	routes.MenuRoutes(router, h)
+	routes.TableRoutes(router, h)
+	routes.OrderRoutes(router, h)
+	routes.OrderItemRoutes(router, h)
+	routes.InvoiceRoutes(router, h)
+	routes.KitchenRoutes(router, h)
+	routes.ReservationRoutes(router, h)
+	routes.NoteRoutes(router, h)
+	routes.PricingRoutes(router, h)
+	routes.ReportRoutes(router, h)
+	routes.PrinterRoutes(router, h)
+	return router
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"go-restaurant-management/config"
+	controller "go-restaurant-management/controllers"
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The end-to-end tests drive the router main builds, with the in-memory
+// repositories in place of MongoDB. Every test starts from an empty store.
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	helper.SECRET_KEY = testSettings().Auth.SecretKey
+	helper.REFRESH_SECRET_KEY = testSettings().Auth.RefreshSecretKey
+	helper.Payments = helper.NewMockGateway("test-webhook-secret")
+	controller.PasswordCost = bcrypt.MinCost
+	models.DefaultCurrency = "USD"
+
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	repos  repository.Repositories
+	users  int // accounts signed up so far, keeps phone numbers unique
+}
+
+// testSettings is a valid configuration for the router, with test secrets.
+func testSettings() config.Config {
+	settings := config.Defaults()
+	settings.Auth.SecretKey = "test-secret-test-secret-test-secret"
+	settings.Auth.RefreshSecretKey = "test-refresh-secret-test-refresh-secret"
+	return settings
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWith(t, testSettings())
+}
+
+func newTestServerWith(t *testing.T, settings config.Config) *testServer {
+	t.Helper()
+	return newTestServerOn(t, settings, repository.NewMemory())
+}
+
+// newTestServerOn serves the given repositories, for tests that make the
+// store fail.
+func newTestServerOn(t *testing.T, settings config.Config, repos repository.Repositories) *testServer {
+	t.Helper()
+	h := controller.New(repos)
+	return &testServer{t: t, router: newRouter(h, settings), repos: repos}
+}
+
+type response struct {
+	Code int
+	Body []byte
+}
+
+// call sends body as JSON, with the access token when one is given.
+func (s *testServer) call(method string, path string, token string, body interface{}) response {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("encoding the %s %s body: %v", method, path, err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("token", token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return response{Code: rec.Code, Body: rec.Body.Bytes()}
+}
+
+// expect sends the request, fails the test unless it answers with the
+// wanted status and decodes the reply into out when out is not nil.
+func (s *testServer) expect(want int, method string, path string, token string, body interface{}, out interface{}) {
+	s.t.Helper()
+
+	res := s.call(method, path, token, body)
+	if res.Code != want {
+		s.t.Fatalf("%s %s: got status %d, want %d: %s", method, path, res.Code, want, res.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(res.Body, out); err != nil {
+			s.t.Fatalf("%s %s: decoding %s: %v", method, path, res.Body, err)
+		}
+	}
+}
+
+type apiError struct {
+	Code    string
+	Message string
+	Details []struct {
+		Field string
+		Rule  string
+	}
+}
+
+// expectError sends the request and checks that it fails with the status
+// and error code.
+func (s *testServer) expectError(status int, code string, method string, path string, token string, body interface{}) apiError {
+	s.t.Helper()
+
+	var envelope struct {
+		Error apiError
+	}
+	s.expect(status, method, path, token, body, &envelope)
+	if envelope.Error.Code != code {
+		s.t.Fatalf("%s %s: got error code %q, want %q", method, path, envelope.Error.Code, code)
+	}
+	return envelope.Error
+}
+
+// listPage is the envelope of the list endpoints.
+type listPage[T any] struct {
+	Items []T
+	Total int64
+	Page  int
+	Limit int
+	Pages int64
+}
+
+// create posts body and returns the id of the new document.
+func (s *testServer) create(method string, path string, token string, body interface{}) string {
+	s.t.Helper()
+
+	var created struct {
+		InsertedID string
+	}
+	s.expect(http.StatusOK, method, path, token, body, &created)
+	if created.InsertedID == "" {
+		s.t.Fatalf("%s %s: no InsertedID in the reply", method, path)
+	}
+	return created.InsertedID
+}
+
+type session struct {
+	UserId       string  `json:"user_id"`
+	Role         *string `json:"role"`
+	Token        string  `json:"token"`
+	RefreshToken string  `json:"refresh_token"`
+}
+
+func (s *testServer) signUp(email string) string {
+	s.t.Helper()
+
+	s.users++
+	return s.create(http.MethodPost, "/users/signup", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      email,
+		"Password":   "secret-password",
+		"phone":      fmt.Sprintf("555-%04d", s.users),
+	})
+}
+
+func (s *testServer) login(email string) session {
+	s.t.Helper()
+
+	var logged session
+	s.expect(http.StatusOK, http.MethodPost, "/users/login", "", gin.H{"email": email, "Password": "secret-password"}, &logged)
+	return logged
+}
+
+// admin signs up an account, makes it an admin the way the grant-admin
+// command does and logs in.
+func (s *testServer) admin() session {
+	s.t.Helper()
+
+	s.signUp("admin@example.com")
+	if err := grantAdmin(s.repos.Users, "admin@example.com"); err != nil {
+		s.t.Fatalf("granting admin: %v", err)
+	}
+	return s.login("admin@example.com")
+}
+
+// staff signs up an account, has the admin give it a role and logs in.
+func (s *testServer) staff(admin session, email string, role string) session {
+	s.t.Helper()
+
+	userId := s.signUp(email)
+	s.expect(http.StatusOK, http.MethodPatch, "/users/"+userId+"/role", admin.Token, gin.H{"role": role}, nil)
+	return s.login(email)
+}
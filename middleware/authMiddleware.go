@@ -1,34 +1,76 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	helper "go-restaurant-management/helpers"
-	"net/http"
+	"go-restaurant-management/repository"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Authentication() gin.HandlerFunc {
+func Authentication(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientToken := c.Request.Header.Get("token")
 		if clientToken == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "No Authorization header provided"})
+			c.Error(helper.Unauthorized("No Authorization header provided"))
 			c.Abort()
 			return
 		}
 
 		claims, err := helper.ValidateToken(clientToken)
 		if err != "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err})
+			c.Error(helper.Unauthorized(err))
 			c.Abort()
 			return
 		}
 
-		// Set claims in the context
+		// Tokens that were rotated away or revoked on logout are no longer accepted
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+		user, lookupErr := users.FindByToken(ctx, claims.Uid, clientToken)
+		if errors.Is(lookupErr, repository.ErrNotFound) {
+			c.Error(helper.Unauthorized("the token has been revoked"))
+			c.Abort()
+			return
+		}
+		if lookupErr != nil {
+			c.Error(helper.Internal("error occurred while checking the token", lookupErr))
+			c.Abort()
+			return
+		}
+
+		// Set claims in the context. The role comes from the stored user
+		// rather than the claim, so a role change applies to tokens already
+		// handed out.
+		role := ""
+		if user.Role != nil {
+			role = *user.Role
+		}
 		c.Set("email", claims.Email)
 		c.Set("first_name", claims.First_name)
 		c.Set("last_name", claims.Last_name)
 		c.Set("uid", claims.Uid)
+		c.Set("role", role)
 
 		c.Next()
 	}
 }
+
+// Authorize only lets the request through when the role set by
+// Authentication is one of the allowed roles.
+func Authorize(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.Error(helper.Forbidden("you are not allowed to perform this action"))
+		c.Abort()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-restaurant-management/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS answers preflight requests and adds the CORS headers for the allowed
+// origins. Requests from other origins get no CORS headers, which makes the
+// browser block them. No origins are allowed by default.
+func CORS(settings config.CORS) gin.HandlerFunc {
+	allowAll := false
+	allowed := map[string]bool{}
+	for _, origin := range settings.AllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(settings.AllowedMethods, ", ")
+	headers := strings.Join(settings.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(settings.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		if origin == "" || !(allowAll || allowed[origin]) {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Origin")
+		c.Header("Access-Control-Allow-Origin", origin)
+		if settings.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if c.Request.Method == http.MethodOptions && c.Request.Header.Get("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+
+	helper "go-restaurant-management/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors renders the last error a handler recorded with c.Error in the
+// common envelope. Errors that are not an APIError become internal errors,
+// and the cause of every server error is logged.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var apiErr *helper.APIError
+		if !errors.As(err, &apiErr) {
+			apiErr = helper.Internal("an unexpected error occurred", err)
+		}
+		if apiErr.Status >= 500 && apiErr.Cause != nil {
+			log.Printf("%s %s: %s: %v", c.Request.Method, c.Request.URL.Path, apiErr.Message, apiErr.Cause)
+		}
+
+		c.JSON(apiErr.Status, gin.H{"error": apiErr})
+	}
+}
+
+// NoRoute answers requests for paths no route matches.
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Error(helper.NotFound("no endpoint matches " + c.Request.Method + " " + c.Request.URL.Path))
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	helper "go-restaurant-management/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery turns a panic in a handler into an internal error, which Errors
+// renders in the common envelope, instead of dropping the connection. It
+// has to come after Errors.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// The client went away; net/http handles this one quietly
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			log.Printf("%s %s: panic: %v\n%s", c.Request.Method, c.Request.URL.Path, recovered, debug.Stack())
+			c.Error(helper.Internal("an unexpected error occurred", fmt.Errorf("panic: %v", recovered)))
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
@@ -9,4 +9,12 @@ type BaseEntity struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty"` // Use omitempty to skip if not set
     Created_at time.Time          `json:"created_at,omitempty"`
     Updated_at time.Time          `json:"updated_at,omitempty"`
-}
\ No newline at end of file
+    Deleted_at *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"` // set while soft deleted
+    Deleted_by *string            `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"` // user id of whoever deleted it
+}
+
+// Position is where the document sits in creation order, which is what list
+// cursors point at.
+func (b BaseEntity) Position() (time.Time, primitive.ObjectID) {
+	return b.Created_at, b.ID
+}
@@ -0,0 +1,35 @@
+package models
+
+// Reasons a credit note can be issued for.
+const (
+	CreditReasonComplaint  = "CUSTOMER_COMPLAINT"
+	CreditReasonWrongItem  = "WRONG_ITEM"
+	CreditReasonOvercharge = "OVERCHARGE"
+	CreditReasonNotServed  = "NOT_SERVED"
+	CreditReasonOther      = "OTHER"
+)
+
+// CreditNote reduces what is owed on an issued invoice, for the whole
+// invoice or for some quantity of its lines. The invoice itself is never
+// changed; its balance takes the credit notes into account.
+type CreditNote struct {
+	BaseEntity     `bson:",inline"`
+	Credit_note_id string           `json:"credit_note_id"`
+	Invoice_id     string           `json:"invoice_id"`
+	Order_id       string           `json:"order_id"`
+	Reason_code    string           `json:"reason_code" validate:"required,eq=CUSTOMER_COMPLAINT|eq=WRONG_ITEM|eq=OVERCHARGE|eq=NOT_SERVED|eq=OTHER"`
+	Reason         string           `json:"reason" validate:"max=500"`
+	Full           bool             `json:"full"`
+	Lines          []CreditNoteLine `json:"lines"`
+	Total          Money            `json:"total"`
+	Created_by     string           `json:"created_by"`
+}
+
+// CreditNoteLine credits part of one invoice line. Amount is the share of the
+// invoice total the quantity stands for, tax and service charge included.
+type CreditNoteLine struct {
+	Order_item_id string `json:"order_item_id"`
+	Name          string `json:"name"`
+	Quantity      int64  `json:"quantity"`
+	Amount        Money  `json:"amount"`
+}
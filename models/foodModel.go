@@ -6,8 +6,9 @@ import(
 type Food struct {
 	BaseEntity					  `bson:",inline"` // Embeded base entity
 	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
+	Price      *Money             `json:"price" validate:"required"`
 	Food_image *string            `json:"food_image" validate:"required"`
 	Food_id    string             `json:"food_id"`
 	Menu_id    *string            `json:"menu_id" validate:"required"`
+	Station    *string            `json:"station"`
 }
\ No newline at end of file
@@ -1,14 +1,94 @@
 package models
 
 import(
+	"fmt"
 	"time"
 )
 
+// RestaurantId and FiscalYearStart scope invoice numbers; the name and
+// address head printed invoices. main sets them from the environment.
+var (
+	RestaurantId      = "default"
+	FiscalYearStart   = time.January
+	RestaurantName    = "Restaurant"
+	RestaurantAddress []string
+)
+
+// Invoice payment statuses, derived from the payments recorded against it.
+const (
+	InvoiceStatusUnpaid   = "UNPAID"
+	InvoiceStatusPartial  = "PARTIAL"
+	InvoiceStatusPaid     = "PAID"
+	InvoiceStatusOverpaid = "OVERPAID"
+	InvoiceStatusRefunded = "REFUNDED"
+)
+
+// Ways an order can be split into several invoices.
+const (
+	SplitByItems = "ITEMS"
+	SplitEvenly  = "EVEN"
+)
+
 type Invoice struct {
 	BaseEntity     					    `bson:",inline"`
 	Invoice_id       string             `json:"invoice_id"`
+	Invoice_number   string             `json:"invoice_number"` // e.g. 2026-000123, gap-free per restaurant and fiscal year
+	Restaurant_id    string             `json:"restaurant_id"`
 	Order_id         string             `json:"order_id"`
-	Payment_method   *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	Payment_status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
+	Order_item_ids   []string           `json:"order_item_ids"` // items billed when the order is split by item
+	Split            *InvoiceSplit      `json:"split"`          // set when the order is shared evenly
+	Payment_status   *string            `json:"payment_status" validate:"omitempty,eq=UNPAID|eq=PARTIAL|eq=PAID|eq=OVERPAID|eq=REFUNDED"`
 	Payment_due_date time.Time          `json:"payment_due_date"`
-}
\ No newline at end of file
+	Discounts        []Discount         `json:"discounts" validate:"dive"`
+	Table_number     *int               `json:"table_number"`
+	Breakdown        *InvoiceBreakdown  `json:"breakdown"` // totals as issued, never recomputed
+	Total            Money              `json:"total"`
+	Amount_credited  Money              `json:"amount_credited"` // credit notes issued against the total
+	Amount_paid      Money              `json:"amount_paid"`     // payments less refunds
+	Amount_refunded  Money              `json:"amount_refunded"`
+	Tip_total        Money              `json:"tip_total"`
+	Amount_due       Money              `json:"amount_due"`
+}
+
+// InvoiceSplit records which share of an evenly split order an invoice is.
+type InvoiceSplit struct {
+	Guest  int `json:"guest"`
+	Guests int `json:"guests"`
+}
+
+// InvoicePaymentStatus derives the status of an invoice from its total, the
+// credit notes issued against it and the net amount paid, tips excluded. The
+// amounts are expected in the currency of the invoice.
+func InvoicePaymentStatus(total Money, credited Money, paid Money) string {
+	open := total.Amount - credited.Amount
+	switch {
+	case credited.Amount > 0 && open <= 0 && paid.Amount <= 0:
+		return InvoiceStatusRefunded
+	case paid.Amount > open:
+		return InvoiceStatusOverpaid
+	case paid.Amount == open:
+		return InvoiceStatusPaid
+	case paid.Amount > 0:
+		return InvoiceStatusPartial
+	default:
+		return InvoiceStatusUnpaid
+	}
+}
+
+// FiscalYear returns the fiscal year a moment falls in, named after the
+// calendar year it starts in.
+func FiscalYear(t time.Time) int {
+	if t.Month() < FiscalYearStart {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// InvoiceNumberSequence is the counter invoice numbers of a fiscal year are taken from.
+func InvoiceNumberSequence(restaurantId string, fiscalYear int) string {
+	return fmt.Sprintf("invoice:%s:%d", restaurantId, fiscalYear)
+}
+
+func FormatInvoiceNumber(fiscalYear int, seq int64) string {
+	return fmt.Sprintf("%d-%06d", fiscalYear, seq)
+}
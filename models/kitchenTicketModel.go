@@ -0,0 +1,36 @@
+package models
+
+// Preparation statuses shared by kitchen tickets, their items and order items.
+const (
+	KitchenStatusPending    = "PENDING"
+	KitchenStatusInProgress = "IN_PROGRESS"
+	KitchenStatusReady      = "READY"
+	// Cancelled items were removed from the order or the order was voided.
+	// A ticket is cancelled once all of its items are.
+	KitchenStatusCancelled = "CANCELLED"
+)
+
+// DefaultKitchenStation is used for foods that are not assigned to a station.
+const DefaultKitchenStation = "GENERAL"
+
+type KitchenTicketItem struct {
+	Order_item_id string  `json:"order_item_id"`
+	Food_id       string  `json:"food_id"`
+	Food_name     string  `json:"food_name"`
+	Portion_size  *string `json:"portion_size"`
+	Quantity      *int    `json:"quantity"`
+	Status        string  `json:"status"`
+	Notes         []Note  `json:"notes,omitempty" bson:"-"` // attached when the ticket is read
+}
+
+type KitchenTicket struct {
+	BaseEntity   `bson:",inline"`
+	Ticket_id    string              `json:"ticket_id"`
+	Order_id     string              `json:"order_id"`
+	Table_id     *string             `json:"table_id"`
+	Table_number *int                `json:"table_number"`
+	Station      string              `json:"station"`
+	Status       string              `json:"status"`
+	Items        []KitchenTicketItem `json:"items"`
+	Notes        []Note              `json:"notes,omitempty" bson:"-"` // order notes, attached when the ticket is read
+}
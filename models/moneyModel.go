@@ -0,0 +1,201 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinorDigits is the number of decimal places kept for every amount.
+const MinorDigits = 2
+
+const minorFactor = 100
+
+// DefaultCurrency is used for amounts sent without a currency code.
+var DefaultCurrency = "USD"
+
+// Money is an exact amount stored as an integer number of minor units
+// (cents) together with its ISO 4217 currency code. In Mongo it is the
+// sub-document {amount: <int64 cents>, currency: "USD"}; in JSON the amount
+// is a decimal string such as {"amount": "12.50", "currency": "USD"}.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal amount such as "12.5" or "-3.05". More decimal
+// places than MinorDigits are rejected rather than rounded.
+func ParseMoney(value string, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+
+	sign := ""
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" {
+		return Money{}, errors.New("amount is empty")
+	}
+	if len(fraction) > MinorDigits {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places", value, MinorDigits)
+	}
+	for _, digit := range whole + fraction {
+		if digit < '0' || digit > '9' {
+			return Money{}, fmt.Errorf("amount %q is not a decimal number", value)
+		}
+	}
+
+	if whole == "" {
+		whole = "0"
+	}
+	fraction += strings.Repeat("0", MinorDigits-len(fraction))
+
+	minor, err := strconv.ParseInt(sign+whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %q is out of range", value)
+	}
+	return NewMoney(minor, currency), nil
+}
+
+// ErrCurrencyMismatch is returned for amounts in different currencies that
+// were to be added up.
+var ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+
+// Add returns the sum of two amounts in the same currency. Amounts are
+// checked against the currency they are used in where they come in, so Add
+// panics when the currencies still differ; CheckedAdd is for amounts that
+// were not checked.
+func (m Money) Add(other Money) Money {
+	return m.must(m.CheckedAdd(other))
+}
+
+// Sub is Add for the difference of two amounts.
+func (m Money) Sub(other Money) Money {
+	return m.must(m.CheckedSub(other))
+}
+
+// CheckedAdd returns the sum of two amounts, or ErrCurrencyMismatch when
+// they are in different currencies.
+func (m Money) CheckedAdd(other Money) (Money, error) {
+	currency, err := m.sharedCurrency(other)
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}, err
+}
+
+// CheckedSub returns the difference of two amounts, or ErrCurrencyMismatch
+// when they are in different currencies.
+func (m Money) CheckedSub(other Money) (Money, error) {
+	currency, err := m.sharedCurrency(other)
+	return Money{Amount: m.Amount - other.Amount, Currency: currency}, err
+}
+
+// SumMoney adds amounts up from zero in the currency. It returns
+// ErrCurrencyMismatch when one of them is in another currency.
+func SumMoney(currency string, amounts ...Money) (Money, error) {
+	sum := NewMoney(0, currency)
+	for _, amount := range amounts {
+		var err error
+		if sum, err = sum.CheckedAdd(amount); err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+func (m Money) Multiply(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount with MinorDigits decimal places, without the currency.
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorFactor, amount%minorFactor)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount": "12.50", "currency": "USD"} as well as a
+// bare number or decimal string in the default currency, so clients sending
+// the old float prices keep working.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	currency := DefaultCurrency
+	if len(data) > 0 && data[0] == '{' {
+		var raw struct {
+			Amount   json.RawMessage `json:"amount"`
+			Currency string          `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		if raw.Currency != "" {
+			currency = strings.ToUpper(raw.Currency)
+		}
+		data = bytes.TrimSpace(raw.Amount)
+	}
+
+	value := string(data)
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(value, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// sharedCurrency returns the currency of both amounts. An amount without a
+// currency, such as the zero Money, takes the currency of the other.
+func (m Money) sharedCurrency(other Money) (string, error) {
+	switch {
+	case m.Currency == "":
+		return other.Currency, nil
+	case other.Currency == "" || other.Currency == m.Currency:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+func (m Money) must(result Money, err error) Money {
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
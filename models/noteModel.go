@@ -3,9 +3,20 @@ package models
 import(
 )
 
+// Kinds of documents a note can be attached to.
+const (
+	NoteOwnerOrder     = "ORDER"
+	NoteOwnerOrderItem = "ORDER_ITEM"
+	NoteOwnerTable     = "TABLE"
+	NoteOwnerInvoice   = "INVOICE"
+)
+
 type Note struct {
 	BaseEntity					  `bson:",inline"`
-	Text       string             `json:"text"`
-	Title      string             `json:"title"`
+	Text       string             `json:"text" validate:"required,max=500"`
+	Title      string             `json:"title" validate:"max=100"`
 	Note_id    string             `json:"note_id"`
-}
\ No newline at end of file
+	Owner_type string             `json:"owner_type" validate:"required,eq=ORDER|eq=ORDER_ITEM|eq=TABLE|eq=INVOICE"`
+	Owner_id   string             `json:"owner_id" validate:"required"`
+	Created_by string             `json:"created_by"`
+}
@@ -5,9 +5,11 @@ import(
 
 type OrderItem struct {
 	BaseEntity						 `bson:",inline"`
-	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
+	Portion_size  *string            `json:"portion_size" validate:"required,eq=S|eq=M|eq=L"`
+	Quantity      *int               `json:"quantity" validate:"omitempty,min=1,max=1000"`
+	Unit_price    *Money             `json:"unit_price"` // captured from the food price when ordered
 	Food_id       *string            `json:"food_id" validate:"required"`
 	Order_item_id string             `json:"order_item_id"`
 	Order_id      string             `json:"order_id" validate:"required"`
+	Status        string             `json:"status"`
 }
\ No newline at end of file
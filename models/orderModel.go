@@ -4,9 +4,50 @@ import(
 	"time"
 )
 
+// Order lifecycle statuses.
+const (
+	OrderStatusOpen      = "OPEN"
+	OrderStatusFired     = "FIRED"
+	OrderStatusReady     = "READY"
+	OrderStatusServed    = "SERVED"
+	OrderStatusClosed    = "CLOSED"
+	OrderStatusCancelled = "CANCELLED"
+	OrderStatusVoided    = "VOIDED"
+)
+
+// OrderTransitions lists the statuses an order may move to from each status.
+// Orders can be cancelled before they reach the kitchen and voided after.
+var OrderTransitions = map[string][]string{
+	OrderStatusOpen:   {OrderStatusFired, OrderStatusCancelled},
+	OrderStatusFired:  {OrderStatusReady, OrderStatusVoided},
+	OrderStatusReady:  {OrderStatusServed, OrderStatusVoided},
+	OrderStatusServed: {OrderStatusClosed, OrderStatusVoided},
+}
+
+// CanTransitionOrder reports whether an order may move from one status to another.
+func CanTransitionOrder(from string, to string) bool {
+	for _, next := range OrderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+type OrderStatusChange struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
 type Order struct {
 	BaseEntity					  `bson:",inline"`		// Embeded base entity
 	Order_Date time.Time          `json:"order_date" validate:"required"`
 	Order_id   string             `json:"order_id"`
 	Table_id   *string            `json:"table_id" validate:"required"`
-}
\ No newline at end of file
+	Status         string              `json:"status"`
+	Status_history []OrderStatusChange `json:"status_history"`
+	Invoiced       bool                `json:"invoiced"`
+}
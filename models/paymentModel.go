@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// Payment methods accepted at the till.
+const (
+	PaymentMethodCard     = "CARD"
+	PaymentMethodCash     = "CASH"
+	PaymentMethodGiftCard = "GIFT_CARD"
+	PaymentMethodVoucher  = "VOUCHER"
+	PaymentMethodOther    = "OTHER"
+)
+
+// Payment statuses. Only CAPTURED payments count towards the invoice.
+const (
+	PaymentStatusPending  = "PENDING"
+	PaymentStatusCaptured = "CAPTURED"
+	PaymentStatusFailed   = "FAILED"
+)
+
+// Ledger entry types. Refunds give money back and are subtracted from what
+// was paid.
+const (
+	PaymentTypePayment = "PAYMENT"
+	PaymentTypeRefund  = "REFUND"
+)
+
+// Payment is one entry of an invoice's payment ledger. Amount counts towards
+// the invoice total; Tip is paid on top and kept apart.
+type Payment struct {
+	BaseEntity  `bson:",inline"`
+	Payment_id  string    `json:"payment_id"`
+	Invoice_id  string    `json:"invoice_id"`
+	Method      *string   `json:"method" validate:"required,eq=CARD|eq=CASH|eq=GIFT_CARD|eq=VOUCHER|eq=OTHER"`
+	Amount      *Money    `json:"amount" validate:"required"`
+	Tip         *Money    `json:"tip"`
+	Reference   string    `json:"reference"`
+	Received_by string    `json:"received_by"`
+	Paid_at     time.Time `json:"paid_at"`
+
+	// Refunds point at the credit note and, for cards, the payment they return
+	Type                string `json:"type"`
+	Credit_note_id      string `json:"credit_note_id,omitempty"`
+	Refunded_payment_id string `json:"refunded_payment_id,omitempty"`
+
+	// Card payments go through the payment provider
+	Status             string `json:"status"`
+	Card_token         string `json:"card_token,omitempty" bson:"-"`
+	Provider           string `json:"provider,omitempty"`
+	Provider_reference string `json:"provider_reference,omitempty"`
+	Failure_reason     string `json:"failure_reason,omitempty"`
+}
+
+// CountsTowardsInvoice reports whether the money was actually received.
+// Payments recorded before statuses existed were always settled.
+func (p Payment) CountsTowardsInvoice() bool {
+	return p.Status == PaymentStatusCaptured || p.Status == ""
+}
+
+func (p Payment) IsRefund() bool {
+	return p.Type == PaymentTypeRefund
+}
@@ -0,0 +1,82 @@
+package models
+
+// Discount types applied to a whole invoice.
+const (
+	DiscountPercent = "PERCENT"
+	DiscountAmount  = "AMOUNT"
+)
+
+// BasisPoints is the denominator of every rate: 750 basis points is 7.50%.
+const BasisPoints = 10000
+
+type TaxRate struct {
+	Name     string `json:"name"`
+	Rate_bps int64  `json:"rate_bps" validate:"min=0,max=10000"`
+}
+
+// CategoryTax overrides the default tax rate for foods on menus of one category.
+type CategoryTax struct {
+	Category string  `json:"category" validate:"required"`
+	Tax      TaxRate `json:"tax"`
+}
+
+// PricingRules configures how invoices are priced. There is a single
+// document, identified by DefaultPricingId.
+type PricingRules struct {
+	BaseEntity             `bson:",inline"`
+	Pricing_id             string        `json:"pricing_id"`
+	Tax_inclusive          bool          `json:"tax_inclusive"`
+	Default_tax            TaxRate       `json:"default_tax"`
+	Category_taxes         []CategoryTax `json:"category_taxes" validate:"dive"`
+	Service_charge_bps     int64         `json:"service_charge_bps" validate:"min=0,max=10000"`
+	Service_charge_taxable bool          `json:"service_charge_taxable"`
+}
+
+const DefaultPricingId = "default"
+
+type Discount struct {
+	Name        string `json:"name" validate:"required"`
+	Type        string `json:"type" validate:"required,eq=PERCENT|eq=AMOUNT"`
+	Percent_bps int64  `json:"percent_bps" validate:"min=0,max=10000"`
+	Amount      *Money `json:"amount"`
+}
+
+// PricedLine is a line of the invoice breakdown. Line_total is the unit price
+// captured when the item was ordered times the quantity, Discount the share
+// of the invoice discounts it carries.
+type PricedLine struct {
+	Order_item_id string `json:"order_item_id"`
+	Name          string `json:"name"`
+	Category      string `json:"category"`
+	Quantity      int64  `json:"quantity"`
+	Unit_price    Money  `json:"unit_price"`
+	Line_total    Money  `json:"line_total"`
+	Discount      Money  `json:"discount"`
+	Tax_name      string `json:"tax_name"`
+	Tax_rate_bps  int64  `json:"tax_rate_bps"`
+}
+
+type DiscountLine struct {
+	Name   string `json:"name"`
+	Amount Money  `json:"amount"`
+}
+
+// TaxLine totals one tax rate. Taxable is the amount excluding the tax.
+type TaxLine struct {
+	Name     string `json:"name"`
+	Rate_bps int64  `json:"rate_bps"`
+	Taxable  Money  `json:"taxable"`
+	Tax      Money  `json:"tax"`
+}
+
+type InvoiceBreakdown struct {
+	Lines          []PricedLine   `json:"lines"`
+	Subtotal       Money          `json:"subtotal"`
+	Discounts      []DiscountLine `json:"discounts"`
+	Discount_total Money          `json:"discount_total"`
+	Service_charge Money          `json:"service_charge"`
+	Taxes          []TaxLine      `json:"taxes"`
+	Tax_total      Money          `json:"tax_total"`
+	Tax_inclusive  bool           `json:"tax_inclusive"`
+	Total          Money          `json:"total"`
+}
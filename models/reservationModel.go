@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Reservation statuses. Only booked and seated reservations hold a table.
+const (
+	ReservationStatusBooked    = "BOOKED"
+	ReservationStatusSeated    = "SEATED"
+	ReservationStatusNoShow    = "NO_SHOW"
+	ReservationStatusCancelled = "CANCELLED"
+)
+
+// DefaultReservationMinutes is used when a reservation does not say how long it lasts.
+const DefaultReservationMinutes = 90
+
+type Reservation struct {
+	BaseEntity       `bson:",inline"`
+	Reservation_id   string     `json:"reservation_id"`
+	Table_id         *string    `json:"table_id" validate:"required"`
+	Guest_name       *string    `json:"guest_name" validate:"required,min=2,max=100"`
+	Phone            *string    `json:"phone" validate:"required"`
+	Party_size       *int       `json:"party_size" validate:"required,min=1"`
+	Start_time       *time.Time `json:"start_time" validate:"required"`
+	Duration_minutes *int       `json:"duration_minutes" validate:"omitempty,min=15,max=720"`
+	End_time         time.Time  `json:"end_time"`
+	Status           string     `json:"status" validate:"omitempty,eq=BOOKED|eq=SEATED|eq=NO_SHOW|eq=CANCELLED"`
+}
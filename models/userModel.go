@@ -1,5 +1,17 @@
 package models
 
+// Staff roles carried on the user document and in the JWT claims.
+const (
+	RoleAdmin   = "ADMIN"
+	RoleManager = "MANAGER"
+	RoleWaiter  = "WAITER"
+	RoleCashier = "CASHIER"
+	RoleKitchen = "KITCHEN"
+)
+
+// StaffRoles are all the roles. Accounts without one can only sign in and out.
+var StaffRoles = []string{RoleAdmin, RoleManager, RoleWaiter, RoleCashier, RoleKitchen}
+
 type User struct {
 	BaseEntity 			  `bson:",inline"` // Flatten BaseEntity fields into the parent document
 	First_name    *string `json:"first_name" validate:"required,min=2,max=100"`
@@ -8,6 +20,7 @@ type User struct {
 	Email         *string `json:"email" validate:"email,required"`
 	Avatar        *string `json:"avatar"`
 	Phone         *string `json:"phone" validate:"required"`
+	Role          *string `json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
 	Token         *string `json:"token"`
 	Refresh_Token *string `json:"refresh_token"`
 	User_id       string  `json:"user_id"`
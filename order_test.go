@@ -0,0 +1,813 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type invoiceView struct {
+	Invoice_id     string
+	Order_id       string
+	Payment_status *string
+	Amount_paid    money
+	Tip_total      money
+	Amount_due     money
+	Breakdown      *struct {
+		Total money `json:"total"`
+	}
+}
+
+// menuWithFood sets up a menu holding one food and returns the food id.
+func (s *testServer) menuWithFood(token string, price string) string {
+	s.t.Helper()
+
+	menuId := s.create(http.MethodPost, "/menus", token, gin.H{"name": "Dinner", "category": "Mains"})
+	return s.create(http.MethodPost, "/foods", token, gin.H{
+		"name":       "Burger",
+		"price":      price,
+		"food_image": "burger.png",
+		"menu_id":    menuId,
+	})
+}
+
+func TestOrderToPaymentFlow(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	waiter := s.staff(admin, "waiter@example.com", models.RoleWaiter)
+	cashier := s.staff(admin, "cashier@example.com", models.RoleCashier)
+
+	foodId := s.menuWithFood(admin.Token, "12.50")
+	tableId := s.create(http.MethodPost, "/tables", admin.Token, gin.H{"number_of_guests": 4, "table_number": 7})
+
+	var placed struct {
+		InsertedIDs []string
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", waiter.Token, gin.H{
+		"table_id": tableId,
+		"order_items": []gin.H{
+			{"food_id": foodId, "portion_size": "M", "quantity": 2},
+		},
+	}, &placed)
+	if len(placed.InsertedIDs) != 1 {
+		t.Fatalf("placed %d order items, want 1", len(placed.InsertedIDs))
+	}
+
+	var item models.OrderItem
+	s.expect(http.StatusOK, http.MethodGet, "/orderItems/"+placed.InsertedIDs[0], waiter.Token, nil, &item)
+	if item.Unit_price == nil || item.Unit_price.Amount != 1250 {
+		t.Fatalf("order item captured unit price %v, want 12.50", item.Unit_price)
+	}
+
+	var order models.Order
+	s.expect(http.StatusOK, http.MethodGet, "/orders/"+item.Order_id, waiter.Token, nil, &order)
+	if order.Status != models.OrderStatusFired {
+		t.Fatalf("order is %s after placing items, want %s", order.Status, models.OrderStatusFired)
+	}
+
+	var tickets []models.KitchenTicket
+	s.expect(http.StatusOK, http.MethodGet, "/kitchen/tickets", waiter.Token, nil, &tickets)
+	if len(tickets) != 1 || tickets[0].Order_id != item.Order_id {
+		t.Fatalf("got %d kitchen tickets, want one for the order", len(tickets))
+	}
+
+	invoiceId := s.create(http.MethodPost, "/invoices", waiter.Token, gin.H{"order_id": item.Order_id})
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/invoices", waiter.Token, gin.H{"order_id": item.Order_id})
+
+	var invoice invoiceView
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId, cashier.Token, nil, &invoice)
+	if invoice.Breakdown == nil || invoice.Breakdown.Total.Amount != "25.00" {
+		t.Fatalf("invoice breakdown %+v, want a total of 25.00", invoice.Breakdown)
+	}
+	if invoice.Amount_due.Amount != "25.00" || *invoice.Payment_status != models.InvoiceStatusUnpaid {
+		t.Fatalf("new invoice is %s with %s due, want UNPAID with 25.00", *invoice.Payment_status, invoice.Amount_due.Amount)
+	}
+
+	var paid struct {
+		Invoice models.Invoice
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/invoices/"+invoiceId+"/payments", cashier.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": "20.00",
+	}, &paid)
+	if *paid.Invoice.Payment_status != models.InvoiceStatusPartial || paid.Invoice.Amount_due.Amount != 500 {
+		t.Fatalf("invoice is %s with %s due after a partial payment", *paid.Invoice.Payment_status, paid.Invoice.Amount_due)
+	}
+
+	s.expect(http.StatusOK, http.MethodPost, "/invoices/"+invoiceId+"/payments", cashier.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": "5.00",
+		"tip":    "3.00",
+	}, &paid)
+	if *paid.Invoice.Payment_status != models.InvoiceStatusPaid || !paid.Invoice.Amount_due.IsZero() {
+		t.Fatalf("invoice is %s with %s due after paying in full", *paid.Invoice.Payment_status, paid.Invoice.Amount_due)
+	}
+	if paid.Invoice.Tip_total.Amount != 300 {
+		t.Fatalf("invoice tips are %s, want 3.00", paid.Invoice.Tip_total)
+	}
+
+	var payments []models.Payment
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId+"/payments", cashier.Token, nil, &payments)
+	if len(payments) != 2 {
+		t.Fatalf("invoice lists %d payments, want 2", len(payments))
+	}
+
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/orders/"+item.Order_id+"/close", cashier.Token, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+item.Order_id+"/ready", admin.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+item.Order_id+"/serve", waiter.Token, nil, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+item.Order_id+"/close", cashier.Token, nil, &order)
+	if order.Status != models.OrderStatusClosed {
+		t.Fatalf("order is %s after closing, want %s", order.Status, models.OrderStatusClosed)
+	}
+}
+
+func TestCatalogValidation(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+
+	s.expect(http.StatusBadRequest, http.MethodPost, "/menus", admin.Token, gin.H{"name": "Dinner"}, nil)
+	menuId := s.create(http.MethodPost, "/menus", admin.Token, gin.H{"name": "Dinner", "category": "Mains"})
+
+	s.expect(http.StatusBadRequest, http.MethodPost, "/foods", admin.Token, gin.H{
+		"price":      "12.50",
+		"food_image": "burger.png",
+		"menu_id":    menuId,
+	}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, "/foods", admin.Token, gin.H{
+		"name":       "Burger",
+		"price":      "12.505",
+		"food_image": "burger.png",
+		"menu_id":    menuId,
+	}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, "/foods", admin.Token, gin.H{
+		"name":       "Burger",
+		"price":      "0",
+		"food_image": "burger.png",
+		"menu_id":    menuId,
+	}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, "/foods", admin.Token, gin.H{
+		"name":       "Burger",
+		"price":      gin.H{"amount": "12.50", "currency": "EUR"},
+		"food_image": "burger.png",
+		"menu_id":    menuId,
+	}, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, "/foods", admin.Token, gin.H{
+		"name":       "Burger",
+		"price":      "12.50",
+		"food_image": "burger.png",
+		"menu_id":    "000000000000000000000000",
+	}, nil)
+
+	foodId := s.create(http.MethodPost, "/foods", admin.Token, gin.H{
+		"name":       "Burger",
+		"price":      "12.50",
+		"food_image": "burger.png",
+		"menu_id":    menuId,
+	})
+	var food models.Food
+	s.expect(http.StatusOK, http.MethodGet, "/foods/"+foodId, admin.Token, nil, &food)
+	if *food.Name != "Burger" || food.Price.Amount != 1250 {
+		t.Fatalf("stored food %s at %s, want Burger at 12.50", *food.Name, food.Price)
+	}
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/foods/000000000000000000000000", admin.Token, nil)
+
+	s.expect(http.StatusBadRequest, http.MethodPost, "/tables", admin.Token, gin.H{"table_number": 7}, nil)
+}
+
+func TestOrderValidation(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	apiErr := s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "XL"}},
+	})
+	if len(apiErr.Details) != 1 || apiErr.Details[0].Rule != "one_of" {
+		t.Fatalf("got details %+v, want one one_of rule", apiErr.Details)
+	}
+	s.expect(http.StatusBadRequest, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M", "quantity": 0}},
+	}, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": "000000000000000000000000", "portion_size": "M"}},
+	}, nil)
+
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": "000000000000000000000000"})
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodPost, "/invoices", admin.Token, gin.H{
+		"order_id":  placed.Order.Order_id,
+		"discounts": []gin.H{{"name": "Voucher", "type": models.DiscountAmount, "amount": gin.H{"amount": "5.00", "currency": "EUR"}}},
+	})
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodGet, "/invoices/000000000000000000000000", admin.Token, nil)
+}
+
+func TestPlacingAnOrderIsAllOrNothing(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+	tableId := s.create(http.MethodPost, "/tables", admin.Token, gin.H{"number_of_guests": 4, "table_number": 3})
+
+	s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{},
+	})
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"table_id":    "000000000000000000000000",
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	})
+	apiErr := s.expectError(http.StatusBadRequest, helper.CodeValidationFailed, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}, {"food_id": foodId, "portion_size": "XL"}},
+	})
+	if len(apiErr.Details) != 1 || apiErr.Details[0].Field != "order_items[1].portion_size" {
+		t.Fatalf("got details %+v, want order_items[1].portion_size", apiErr.Details)
+	}
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}, {"food_id": "000000000000000000000000", "portion_size": "M"}},
+	})
+
+	var orders listPage[models.Order]
+	s.expect(http.StatusOK, http.MethodGet, "/orders", admin.Token, nil, &orders)
+	if orders.Total != 0 {
+		t.Fatalf("rejected orders were stored: %+v", orders.Items)
+	}
+
+	var placed struct {
+		Order       models.Order
+		Order_items []models.OrderItem
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"table_id":    tableId,
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}, {"food_id": foodId, "portion_size": "L", "quantity": 2}},
+	}, &placed)
+	if placed.Order.Status != models.OrderStatusFired || *placed.Order.Table_id != tableId {
+		t.Fatalf("placed order is %s at table %v, want %s at %s", placed.Order.Status, placed.Order.Table_id, models.OrderStatusFired, tableId)
+	}
+	if len(placed.Order_items) != 2 || placed.Order_items[1].Order_id != placed.Order.Order_id || *placed.Order_items[1].Quantity != 2 {
+		t.Fatalf("placed items %+v do not belong to order %s", placed.Order_items, placed.Order.Order_id)
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/orders", admin.Token, nil, &orders)
+	if orders.Total != 1 {
+		t.Fatalf("%d orders stored, want 1", orders.Total)
+	}
+}
+
+// brokenOrderItems stores the first item of an order and then fails, the
+// way a connection lost halfway through an insert would.
+type brokenOrderItems struct {
+	repository.OrderItemRepository
+}
+
+func (r brokenOrderItems) Create(ctx context.Context, orderItems ...models.OrderItem) error {
+	if err := r.OrderItemRepository.Create(ctx, orderItems[:1]...); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+// brokenKitchenTickets fails to store the tickets, the last write of an order.
+type brokenKitchenTickets struct {
+	repository.KitchenTicketRepository
+}
+
+func (brokenKitchenTickets) Create(ctx context.Context, tickets ...models.KitchenTicket) error {
+	return errors.New("connection reset")
+}
+
+func TestFailedOrderInsertLeavesNothingBehind(t *testing.T) {
+	for name, breakStore := range map[string]func(repos *repository.Repositories){
+		"items": func(repos *repository.Repositories) { repos.OrderItems = brokenOrderItems{repos.OrderItems} },
+		"tickets": func(repos *repository.Repositories) {
+			repos.KitchenTickets = brokenKitchenTickets{repos.KitchenTickets}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repos := repository.NewMemory()
+			breakStore(&repos)
+			s := newTestServerOn(t, testSettings(), repos)
+			admin := s.admin()
+			foodId := s.menuWithFood(admin.Token, "12.50")
+
+			s.expectError(http.StatusInternalServerError, helper.CodeInternal, http.MethodPost, "/orderItems", admin.Token, gin.H{
+				"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}, {"food_id": foodId, "portion_size": "L"}},
+			})
+
+			var orders listPage[models.Order]
+			s.expect(http.StatusOK, http.MethodGet, "/orders", admin.Token, nil, &orders)
+			var orderItems listPage[models.OrderItem]
+			s.expect(http.StatusOK, http.MethodGet, "/orderItems", admin.Token, nil, &orderItems)
+			if orders.Total != 0 || orderItems.Total != 0 {
+				t.Fatalf("a failed order left %d orders and %d items behind", orders.Total, orderItems.Total)
+			}
+		})
+	}
+}
+
+// racingKitchenTickets lets another cook bump the last item of a ticket
+// right after the first read of it.
+type racingKitchenTickets struct {
+	repository.KitchenTicketRepository
+	raced *bool
+}
+
+func (r racingKitchenTickets) Get(ctx context.Context, ticketId string) (models.KitchenTicket, error) {
+	ticket, err := r.KitchenTicketRepository.Get(ctx, ticketId)
+	if err != nil || *r.raced {
+		return ticket, err
+	}
+	*r.raced = true
+
+	items := append([]models.KitchenTicketItem(nil), ticket.Items...)
+	items[len(items)-1].Status = models.KitchenStatusReady
+	_, err = r.KitchenTicketRepository.Update(ctx, ticketId, ticket.Updated_at, bson.M{
+		"items":      items,
+		"updated_at": ticket.Updated_at.Add(time.Second),
+	})
+	return ticket, err
+}
+
+func TestConcurrentKitchenBumpsAreKept(t *testing.T) {
+	repos := repository.NewMemory()
+	repos.KitchenTickets = racingKitchenTickets{KitchenTicketRepository: repos.KitchenTickets, raced: new(bool)}
+	s := newTestServerOn(t, testSettings(), repos)
+	admin := s.admin()
+	cook := s.staff(admin, "cook@example.com", models.RoleKitchen)
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		InsertedIDs []string
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}, {"food_id": foodId, "portion_size": "L"}},
+	}, &placed)
+
+	var tickets []models.KitchenTicket
+	s.expect(http.StatusOK, http.MethodGet, "/kitchen/tickets", cook.Token, nil, &tickets)
+	if len(tickets) != 1 || len(tickets[0].Items) != 2 {
+		t.Fatalf("got %d kitchen tickets, want one with both items", len(tickets))
+	}
+
+	var ticket models.KitchenTicket
+	path := "/kitchen/tickets/" + tickets[0].Ticket_id + "/items/" + placed.InsertedIDs[0]
+	s.expect(http.StatusOK, http.MethodPatch, path, cook.Token, gin.H{"status": models.KitchenStatusReady}, &ticket)
+	for _, item := range ticket.Items {
+		if item.Status != models.KitchenStatusReady {
+			t.Fatalf("item %s is %s after both were bumped, want %s", item.Order_item_id, item.Status, models.KitchenStatusReady)
+		}
+	}
+	if ticket.Status != models.KitchenStatusReady {
+		t.Fatalf("ticket is %s with every item ready, want %s", ticket.Status, models.KitchenStatusReady)
+	}
+}
+
+func TestKitchenTicketsFollowOrderChanges(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+	events := helper.Kitchen.Subscribe("")
+	defer helper.Kitchen.Unsubscribe(events)
+
+	var placed struct {
+		Order       models.Order
+		InsertedIDs []string
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}, {"food_id": foodId, "portion_size": "L"}},
+	}, &placed)
+	kept, removed := placed.InsertedIDs[0], placed.InsertedIDs[1]
+
+	next := func() helper.KitchenEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no kitchen event was published")
+			return helper.KitchenEvent{}
+		}
+	}
+	next()
+	ticketItems := func() (models.KitchenTicket, map[string]models.KitchenTicketItem) {
+		t.Helper()
+		event := next()
+		if event.Type != "updated" {
+			t.Fatalf("got a %s kitchen event, want updated", event.Type)
+		}
+		items := map[string]models.KitchenTicketItem{}
+		for _, item := range event.Ticket.Items {
+			items[item.Order_item_id] = item
+		}
+		return event.Ticket, items
+	}
+
+	// Edits are rewritten on the ticket, deleted items are cancelled
+	s.expect(http.StatusOK, http.MethodPatch, "/orderItems/"+kept, admin.Token, gin.H{"quantity": 3}, nil)
+	if _, items := ticketItems(); items[kept].Quantity == nil || *items[kept].Quantity != 3 {
+		t.Fatalf("the ticket shows quantity %v after the edit, want 3", items[kept].Quantity)
+	}
+	s.expect(http.StatusOK, http.MethodDelete, "/orderItems/"+removed, admin.Token, nil, nil)
+	ticket, items := ticketItems()
+	if items[removed].Status != models.KitchenStatusCancelled || ticket.Status != models.KitchenStatusPending {
+		t.Fatalf("the deleted item is %s on a %s ticket, want it cancelled on a pending one", items[removed].Status, ticket.Status)
+	}
+
+	// A voided order leaves the kitchen screens
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+placed.Order.Order_id+"/void", admin.Token, nil, nil)
+	if ticket, _ := ticketItems(); ticket.Status != models.KitchenStatusCancelled {
+		t.Fatalf("the ticket of the voided order is %s, want %s", ticket.Status, models.KitchenStatusCancelled)
+	}
+	var tickets []models.KitchenTicket
+	s.expect(http.StatusOK, http.MethodGet, "/kitchen/tickets", admin.Token, nil, &tickets)
+	if len(tickets) != 0 {
+		t.Fatalf("the kitchen still shows %d tickets of the voided order", len(tickets))
+	}
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPatch, "/kitchen/tickets/"+ticket.Ticket_id, admin.Token, gin.H{"status": models.KitchenStatusReady})
+}
+
+func TestInvoiceShowsTheLinesItWasIssuedWith(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M", "quantity": 2}},
+	}, &placed)
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+	s.expect(http.StatusOK, http.MethodPatch, "/foods/"+foodId, admin.Token, gin.H{"name": "Cheeseburger", "price": "14.00"}, nil)
+
+	var invoice struct {
+		Order_details []struct {
+			Food_name string `json:"food_name"`
+			Quantity  int    `json:"quantity"`
+			Amount    money  `json:"amount"`
+		}
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId, admin.Token, nil, &invoice)
+	if len(invoice.Order_details) != 1 {
+		t.Fatalf("invoice shows %d lines, want 1", len(invoice.Order_details))
+	}
+	if line := invoice.Order_details[0]; line.Food_name != "Burger" || line.Quantity != 2 || line.Amount.Amount != "25.00" {
+		t.Fatalf("invoice shows %d %s for %s after the food changed, want 2 Burger for 25.00", line.Quantity, line.Food_name, line.Amount.Amount)
+	}
+}
+
+// flakyInvoices fails the first insert and stores the ones after it.
+type flakyInvoices struct {
+	repository.InvoiceRepository
+	failed *bool
+}
+
+func (r flakyInvoices) Create(ctx context.Context, invoices ...models.Invoice) error {
+	if !*r.failed {
+		*r.failed = true
+		return errors.New("connection reset")
+	}
+	return r.InvoiceRepository.Create(ctx, invoices...)
+}
+
+func TestFailedInvoiceInsertLeavesNoGapInNumbers(t *testing.T) {
+	repos := repository.NewMemory()
+	repos.Invoices = flakyInvoices{InvoiceRepository: repos.Invoices, failed: new(bool)}
+	s := newTestServerOn(t, testSettings(), repos)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+
+	s.expectError(http.StatusInternalServerError, helper.CodeInternal, http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+
+	var invoice models.Invoice
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId, admin.Token, nil, &invoice)
+	if !strings.HasSuffix(invoice.Invoice_number, "-000001") {
+		t.Fatalf("invoice after a failed one is numbered %s, want the first number", invoice.Invoice_number)
+	}
+}
+
+// racingInvoices invoices the order a second time while the first request
+// is still checking it.
+type racingInvoices struct {
+	repository.InvoiceRepository
+	race func(orderId string)
+}
+
+func (r *racingInvoices) CountByOrder(ctx context.Context, orderId string) (int64, error) {
+	if race := r.race; race != nil {
+		r.race = nil
+		race(orderId)
+	}
+	return r.InvoiceRepository.CountByOrder(ctx, orderId)
+}
+
+func TestOrderIsInvoicedOnceWhenRequestsRace(t *testing.T) {
+	repos := repository.NewMemory()
+	invoices := &racingInvoices{InvoiceRepository: repos.Invoices}
+	repos.Invoices = invoices
+	s := newTestServerOn(t, testSettings(), repos)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+
+	var raced response
+	invoices.race = func(orderId string) {
+		raced = s.call(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": orderId})
+	}
+	s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+	if raced.Code != http.StatusConflict {
+		t.Fatalf("invoicing an order that is being invoiced got status %d, want %d: %s", raced.Code, http.StatusConflict, raced.Body)
+	}
+
+	var page listPage[models.Invoice]
+	s.expect(http.StatusOK, http.MethodGet, "/invoices", admin.Token, nil, &page)
+	if page.Total != 1 {
+		t.Fatalf("the order was invoiced %d times, want once", page.Total)
+	}
+}
+
+func TestOnlyLiveOrdersWithItemsAreInvoiced(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+	tableId := s.create(http.MethodPost, "/tables", admin.Token, gin.H{"number_of_guests": 4, "table_number": 3})
+
+	// A voided order is not billed, whole or split
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	voidedId := placed.Order.Order_id
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+voidedId+"/void", admin.Token, nil, nil)
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": voidedId})
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/orders/"+voidedId+"/split", admin.Token, gin.H{"mode": models.SplitEvenly, "guests": 2})
+
+	// Neither is an order without items, before or after it is cancelled
+	emptyId := s.create(http.MethodPost, "/orders", admin.Token, gin.H{"table_id": tableId, "order_date": time.Now()})
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": emptyId})
+	s.expect(http.StatusOK, http.MethodPost, "/orders/"+emptyId+"/cancel", admin.Token, nil, nil)
+	apiErr := s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": emptyId})
+	if !strings.Contains(apiErr.Message, "cancelled") {
+		t.Fatalf("invoicing a cancelled order failed with %q", apiErr.Message)
+	}
+
+	s.expectError(http.StatusNotFound, helper.CodeNotFound, http.MethodPost, "/orders/000000000000000000000000/split", admin.Token, gin.H{"mode": models.SplitEvenly, "guests": 2})
+
+	var page listPage[models.Invoice]
+	s.expect(http.StatusOK, http.MethodGet, "/invoices", admin.Token, nil, &page)
+	if page.Total != 0 {
+		t.Fatalf("%d invoices were issued, want none", page.Total)
+	}
+}
+
+// racingCreditNotes credits the invoice in full right after the first
+// request listed its credit notes.
+type racingCreditNotes struct {
+	repository.CreditNoteRepository
+	race func(invoiceId string)
+}
+
+func (r *racingCreditNotes) ListByInvoice(ctx context.Context, invoiceId string) ([]models.CreditNote, error) {
+	creditNotes, err := r.CreditNoteRepository.ListByInvoice(ctx, invoiceId)
+	if race := r.race; race != nil {
+		r.race = nil
+		race(invoiceId)
+	}
+	return creditNotes, err
+}
+
+func TestInvoiceIsCreditedOnceWhenRequestsRace(t *testing.T) {
+	repos := repository.NewMemory()
+	creditNotes := &racingCreditNotes{CreditNoteRepository: repos.CreditNotes}
+	repos.CreditNotes = creditNotes
+	s := newTestServerOn(t, testSettings(), repos)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+	s.expect(http.StatusOK, http.MethodPost, "/invoices/"+invoiceId+"/payments", admin.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": "12.50",
+	}, nil)
+
+	fullRefund := gin.H{"full": true, "reason_code": models.CreditReasonComplaint, "refund_method": models.PaymentMethodCash}
+	creditNotes.race = func(invoiceId string) {
+		s.expect(http.StatusOK, http.MethodPost, "/invoices/"+invoiceId+"/credit-notes", admin.Token, fullRefund, nil)
+	}
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/invoices/"+invoiceId+"/credit-notes", admin.Token, fullRefund)
+
+	var issued []models.CreditNote
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId+"/credit-notes", admin.Token, nil, &issued)
+	var payments []models.Payment
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId+"/payments", admin.Token, nil, &payments)
+	if len(issued) != 1 || len(payments) != 2 {
+		t.Fatalf("racing full credits left %d credit notes and %d payments, want 1 and the payment with its refund", len(issued), len(payments))
+	}
+}
+
+// brokenPayments fails to record any payment.
+type brokenPayments struct {
+	repository.PaymentRepository
+}
+
+func (brokenPayments) Create(ctx context.Context, payments ...models.Payment) error {
+	return errors.New("connection reset")
+}
+
+// countingProvider counts the authorizations asked of the provider.
+type countingProvider struct {
+	helper.PaymentProvider
+	authorized *int
+}
+
+func (p countingProvider) Authorize(ctx context.Context, amount models.Money, cardToken string) (helper.ProviderResult, error) {
+	*p.authorized++
+	return p.PaymentProvider.Authorize(ctx, amount, cardToken)
+}
+
+func TestCardIsNotChargedWhenThePaymentCannotBeRecorded(t *testing.T) {
+	authorized := 0
+	provider := helper.Payments
+	helper.Payments = countingProvider{PaymentProvider: provider, authorized: &authorized}
+	defer func() { helper.Payments = provider }()
+
+	repos := repository.NewMemory()
+	repos.Payments = brokenPayments{repos.Payments}
+	s := newTestServerOn(t, testSettings(), repos)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+
+	s.expectError(http.StatusInternalServerError, helper.CodeInternal, http.MethodPost, "/invoices/"+invoiceId+"/payments", admin.Token, gin.H{
+		"method":     models.PaymentMethodCard,
+		"amount":     "12.50",
+		"card_token": helper.MockCardApproved,
+	})
+	if authorized != 0 {
+		t.Fatalf("the card was authorized %d times for a payment that was never recorded", authorized)
+	}
+}
+
+func TestPaymentValidation(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		InsertedIDs []string
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	var item models.OrderItem
+	s.expect(http.StatusOK, http.MethodGet, "/orderItems/"+placed.InsertedIDs[0], admin.Token, nil, &item)
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": item.Order_id})
+
+	path := "/invoices/" + invoiceId + "/payments"
+	s.expect(http.StatusBadRequest, http.MethodPost, path, admin.Token, gin.H{"method": "BARTER", "amount": "12.50"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, path, admin.Token, gin.H{"method": models.PaymentMethodCash}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, path, admin.Token, gin.H{"method": models.PaymentMethodCash, "amount": "0"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, path, admin.Token, gin.H{"method": models.PaymentMethodCash, "amount": "12.50", "tip": "-1"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, path, admin.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": gin.H{"amount": "12.50", "currency": "EUR"},
+	}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, path, admin.Token, gin.H{"method": models.PaymentMethodCard, "amount": "12.50"}, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, "/invoices/000000000000000000000000/payments", admin.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": "12.50",
+	}, nil)
+
+	var paid struct {
+		Invoice models.Invoice
+	}
+	s.expect(http.StatusOK, http.MethodPost, path, admin.Token, gin.H{"method": models.PaymentMethodCash, "amount": "12.50"}, &paid)
+	if *paid.Invoice.Payment_status != models.InvoiceStatusPaid {
+		t.Fatalf("invoice is %s after paying in full, want %s", *paid.Invoice.Payment_status, models.InvoiceStatusPaid)
+	}
+}
+
+func TestStrayCurrencyFailsTheRequestNotTheServer(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+
+	// A ledger entry in another currency cannot be totalled with the rest
+	stray := models.Payment{Payment_id: "stray", Invoice_id: invoiceId, Amount: &models.Money{Amount: 500, Currency: "EUR"}}
+	stray.Created_at = time.Now()
+	if err := s.repos.Payments.Create(context.Background(), stray); err != nil {
+		t.Fatal(err)
+	}
+	apiErr := s.expectError(http.StatusInternalServerError, helper.CodeInternal, http.MethodPost, "/invoices/"+invoiceId+"/payments", admin.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": "1.00",
+	})
+	if !strings.Contains(apiErr.Message, "invoice could not be updated") {
+		t.Fatalf("the payment failed with %q, want the invoice update to fail", apiErr.Message)
+	}
+
+	// Whatever still panics is answered like any other error
+	s.router.GET("/panics", func(c *gin.Context) { panic("boom") })
+	s.expectError(http.StatusInternalServerError, helper.CodeInternal, http.MethodGet, "/panics", admin.Token, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/invoices/"+invoiceId, admin.Token, nil, nil)
+}
+
+func TestSalesReportRefusesMixedCurrencies(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.admin()
+	foodId := s.menuWithFood(admin.Token, "12.50")
+
+	var placed struct {
+		Order models.Order
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/orderItems", admin.Token, gin.H{
+		"order_items": []gin.H{{"food_id": foodId, "portion_size": "M"}},
+	}, &placed)
+	invoiceId := s.create(http.MethodPost, "/invoices", admin.Token, gin.H{"order_id": placed.Order.Order_id})
+	s.expect(http.StatusOK, http.MethodPost, "/invoices/"+invoiceId+"/payments", admin.Token, gin.H{
+		"method": models.PaymentMethodCash,
+		"amount": "12.50",
+		"tip":    "1.00",
+	}, nil)
+
+	var report struct {
+		From, To                    string
+		Invoice_count               int64
+		Gross_sales, Payments, Tips money
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/reports/sales", admin.Token, nil, &report)
+	today := time.Now().Format("2006-01-02")
+	if report.From != today || report.To != today {
+		t.Fatalf("the report covers %s to %s, want %s on its own", report.From, report.To, today)
+	}
+	if report.Invoice_count != 1 || report.Gross_sales.Amount != "12.50" || report.Payments.Amount != "12.50" || report.Tips.Amount != "1.00" {
+		t.Fatalf("the report has %d invoices for %s, %s paid and %s tips, want 1 for 12.50, 12.50 and 1.00",
+			report.Invoice_count, report.Gross_sales.Amount, report.Payments.Amount, report.Tips.Amount)
+	}
+
+	// An amount in another currency is not added to the rest as if it matched
+	stray := models.Invoice{Order_id: placed.Order.Order_id, Total: models.NewMoney(500, "EUR")}
+	stray.Created_at = time.Now()
+	stray.Invoice_id = "stray"
+	if err := s.repos.Invoices.Create(context.Background(), stray); err != nil {
+		t.Fatal(err)
+	}
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodGet, "/reports/sales", admin.Token, nil)
+}
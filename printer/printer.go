@@ -0,0 +1,106 @@
+package printer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Printer sends a rendered ESC/POS job to a device.
+type Printer interface {
+	Print(ctx context.Context, data []byte) error
+}
+
+// TCPPrinter writes jobs to a network printer's raw socket, usually port 9100.
+type TCPPrinter struct {
+	Address string
+	Timeout time.Duration
+}
+
+func (p TCPPrinter) Print(ctx context.Context, data []byte) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+// FilePrinter stores every job as a numbered file in a directory, for
+// testing layouts without paper.
+type FilePrinter struct {
+	Dir string
+
+	mu   sync.Mutex
+	jobs int
+}
+
+func (p *FilePrinter) Print(ctx context.Context, data []byte) error {
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.jobs++
+	name := fmt.Sprintf("%s-%04d.bin", time.Now().Format("20060102-150405"), p.jobs)
+	p.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(p.Dir, name), data, 0o644)
+}
+
+// Parse builds a printer from a URL: tcp://host:port for a network printer,
+// file:///path/to/dir for a file sink.
+func Parse(rawURL string) (Printer, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "tcp":
+		address := parsed.Host
+		if parsed.Port() == "" {
+			address = net.JoinHostPort(parsed.Hostname(), "9100")
+		}
+		return TCPPrinter{Address: address}, nil
+	case "file":
+		return &FilePrinter{Dir: parsed.Path}, nil
+	default:
+		return nil, fmt.Errorf("printer %q: unsupported scheme %q", rawURL, parsed.Scheme)
+	}
+}
+
+// RegisterList registers the printers of a "name=url,name=url" list.
+func (r *Registry) RegisterList(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rawURL, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("printer %q should be name=url", entry)
+		}
+		printer, err := Parse(strings.TrimSpace(rawURL))
+		if err != nil {
+			return err
+		}
+		r.Register(strings.TrimSpace(name), printer)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package printer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job statuses.
+const (
+	JobQueued   = "QUEUED"
+	JobPrinting = "PRINTING"
+	JobDone     = "DONE"
+	JobFailed   = "FAILED"
+)
+
+var (
+	ErrUnknownPrinter = errors.New("unknown printer")
+	ErrQueueFull      = errors.New("the printer queue is full")
+)
+
+// Job is one document sent to a printer.
+type Job struct {
+	Job_id     string    `json:"job_id"`
+	Printer    string    `json:"printer"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+
+	data []byte
+}
+
+// Queue prints jobs in the background. Each printer has its own worker, so
+// jobs for one printer come out in the order they were sent and a printer
+// that is offline does not hold up the others. Failed attempts are retried
+// with a growing pause.
+type Queue struct {
+	registry    *Registry
+	maxAttempts int
+	backoff     time.Duration
+
+	mu      sync.Mutex
+	workers map[string]chan string
+	jobs    map[string]*Job
+	order   []string
+	next    int
+}
+
+// keptJobs is how many finished and pending jobs the queue remembers.
+const keptJobs = 500
+
+func NewQueue(registry *Registry, maxAttempts int, backoff time.Duration) *Queue {
+	return &Queue{
+		registry:    registry,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		workers:     make(map[string]chan string),
+		jobs:        make(map[string]*Job),
+	}
+}
+
+// Printers is the registry print jobs are sent to; main fills it from the environment.
+var Printers = NewRegistry()
+
+// Jobs is the queue shared by the handlers.
+var Jobs = NewQueue(Printers, 5, 2*time.Second)
+
+// Submit queues data for the named printer.
+func (q *Queue) Submit(printerName string, data []byte) (Job, error) {
+	name := strings.ToLower(printerName)
+	if _, ok := q.registry.Get(name); !ok {
+		return Job{}, fmt.Errorf("%w %q", ErrUnknownPrinter, printerName)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.next++
+	now := time.Now()
+	job := &Job{
+		Job_id:     fmt.Sprintf("job-%06d", q.next),
+		Printer:    name,
+		Status:     JobQueued,
+		Created_at: now,
+		Updated_at: now,
+		data:       data,
+	}
+
+	worker, ok := q.workers[name]
+	if !ok {
+		worker = make(chan string, 100)
+		q.workers[name] = worker
+		go q.work(name, worker)
+	}
+	select {
+	case worker <- job.Job_id:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	q.jobs[job.Job_id] = job
+	q.order = append(q.order, job.Job_id)
+	q.forgetOldJobs()
+	return *job, nil
+}
+
+// Job returns the current state of a job.
+func (q *Queue) Job(jobId string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[jobId]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (q *Queue) work(name string, jobIds chan string) {
+	for jobId := range jobIds {
+		q.mu.Lock()
+		job, ok := q.jobs[jobId]
+		var data []byte
+		if ok {
+			data = job.data
+		}
+		q.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		for attempt := 1; attempt <= q.maxAttempts; attempt++ {
+			q.update(jobId, func(job *Job) {
+				job.Status = JobPrinting
+				job.Attempts = attempt
+			})
+
+			err := q.print(name, data)
+			if err == nil {
+				q.update(jobId, func(job *Job) {
+					job.Status = JobDone
+					job.Error = ""
+					job.data = nil
+				})
+				break
+			}
+
+			log.Printf("print job %s on %s failed (attempt %d of %d): %v", jobId, name, attempt, q.maxAttempts, err)
+			if attempt == q.maxAttempts {
+				q.update(jobId, func(job *Job) {
+					job.Status = JobFailed
+					job.Error = err.Error()
+					job.data = nil
+				})
+				break
+			}
+			q.update(jobId, func(job *Job) {
+				job.Status = JobQueued
+				job.Error = err.Error()
+			})
+			time.Sleep(q.backoff * time.Duration(attempt))
+		}
+	}
+}
+
+func (q *Queue) print(name string, data []byte) error {
+	printer, ok := q.registry.Get(name)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownPrinter, name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return printer.Print(ctx, data)
+}
+
+func (q *Queue) update(jobId string, change func(job *Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job, ok := q.jobs[jobId]; ok {
+		change(job)
+		job.Updated_at = time.Now()
+	}
+}
+
+// forgetOldJobs drops the oldest finished jobs beyond keptJobs. Callers hold q.mu.
+func (q *Queue) forgetOldJobs() {
+	for len(q.order) > keptJobs {
+		oldest := q.jobs[q.order[0]]
+		if oldest != nil && (oldest.Status == JobQueued || oldest.Status == JobPrinting) {
+			return
+		}
+		delete(q.jobs, q.order[0])
+		q.order = q.order[1:]
+	}
+}
@@ -0,0 +1,43 @@
+package printer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry maps printer names to printers. Names are case-insensitive, so a
+// printer named "grill" receives the tickets of the GRILL station.
+type Registry struct {
+	mu       sync.RWMutex
+	printers map[string]Printer
+}
+
+func NewRegistry() *Registry {
+	return &Registry{printers: make(map[string]Printer)}
+}
+
+func (r *Registry) Register(name string, printer Printer) {
+	r.mu.Lock()
+	r.printers[strings.ToLower(name)] = printer
+	r.mu.Unlock()
+}
+
+func (r *Registry) Get(name string) (Printer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	printer, ok := r.printers[strings.ToLower(name)]
+	return printer, ok
+}
+
+// Names lists the registered printers in alphabetical order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.printers))
+	for name := range r.printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,33 @@
+package receipt
+
+// Document is a printable receipt or invoice. It holds text that is already
+// formatted; renderers only lay it out, so the PDF and the ticket printer
+// show the same thing.
+type Document struct {
+	Title    string   // e.g. "INVOICE"
+	Header   []string // restaurant name first, then address lines
+	Fields   []Field  // invoice number, date, table...
+	Lines    []Line
+	Totals   []Field // the last one is printed in bold
+	Payments []Field
+	Footer   []string
+
+	// Kitchen documents have no prices; their items are printed large so
+	// they can be read from across the pass.
+	Kitchen bool
+}
+
+// Field is a label with its value, such as "Table" and "12".
+type Field struct {
+	Label string
+	Value string
+}
+
+// Line is one item of the document. Notes are printed under the item.
+type Line struct {
+	Name     string
+	Quantity string
+	Price    string
+	Amount   string
+	Notes    []string
+}
@@ -0,0 +1,126 @@
+package receipt
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Paper widths in characters of the normal ESC/POS font.
+const (
+	Width80mm = 48
+	Width58mm = 32
+)
+
+// ESC/POS commands used by the renderer.
+var (
+	escInit        = []byte{0x1b, 0x40}
+	escCodePage    = []byte{0x1b, 0x74, 16} // WPC1252, matching winAnsi
+	escAlignLeft   = []byte{0x1b, 0x61, 0}
+	escAlignCenter = []byte{0x1b, 0x61, 1}
+	escBoldOn      = []byte{0x1b, 0x45, 1}
+	escBoldOff     = []byte{0x1b, 0x45, 0}
+	escSizeNormal  = []byte{0x1d, 0x21, 0x00}
+	escSizeTall    = []byte{0x1d, 0x21, 0x01}
+	escSizeDouble  = []byte{0x1d, 0x21, 0x11}
+	escFeedCut     = []byte{0x1d, 0x56, 66, 3} // feed three lines and cut
+)
+
+// RenderESCPOS turns the document into the byte stream of an ESC/POS
+// printer whose paper fits width characters per line.
+func RenderESCPOS(doc Document, width int) []byte {
+	var out bytes.Buffer
+	out.Write(escInit)
+	out.Write(escCodePage)
+
+	line := func(text string) {
+		out.WriteString(winAnsi(text))
+		out.WriteByte('\n')
+	}
+	rule := func() {
+		line(strings.Repeat("-", width))
+	}
+	fields := func(fields []Field, boldLast bool) {
+		for i, field := range fields {
+			if boldLast && i == len(fields)-1 {
+				out.Write(escBoldOn)
+				line(spread(field.Label, field.Value, width))
+				out.Write(escBoldOff)
+				continue
+			}
+			line(spread(field.Label, field.Value, width))
+		}
+	}
+
+	// Header, centred
+	out.Write(escAlignCenter)
+	for i, text := range doc.Header {
+		if i == 0 {
+			out.Write(escBoldOn)
+			out.Write(escSizeDouble)
+			line(truncate(text, width/2))
+			out.Write(escSizeNormal)
+			out.Write(escBoldOff)
+			continue
+		}
+		line(truncate(text, width))
+	}
+	if doc.Title != "" {
+		out.Write(escBoldOn)
+		out.Write(escSizeTall)
+		line(truncate(doc.Title, width))
+		out.Write(escSizeNormal)
+		out.Write(escBoldOff)
+	}
+	out.Write(escAlignLeft)
+	line("")
+	fields(doc.Fields, false)
+	rule()
+
+	for _, item := range doc.Lines {
+		if doc.Kitchen {
+			// Double width halves the characters per line
+			out.Write(escBoldOn)
+			out.Write(escSizeDouble)
+			for _, text := range wrap(item.Quantity+" x "+item.Name, width/2) {
+				line(text)
+			}
+			out.Write(escSizeNormal)
+			out.Write(escBoldOff)
+		} else {
+			amountWidth := len(item.Amount) + 1
+			names := wrap(item.Quantity+" x "+item.Name, width-amountWidth)
+			line(padRight(names[0], width-amountWidth) + padLeft(item.Amount, amountWidth))
+			for _, name := range names[1:] {
+				line("  " + name)
+			}
+			if item.Quantity != "1" && item.Price != "" {
+				line("    @ " + item.Price)
+			}
+		}
+		for _, note := range item.Notes {
+			for _, text := range wrap("  * "+note, width) {
+				line(text)
+			}
+		}
+	}
+
+	if len(doc.Totals) > 0 {
+		rule()
+		fields(doc.Totals, true)
+	}
+	if len(doc.Payments) > 0 {
+		line("")
+		fields(doc.Payments, false)
+	}
+	if len(doc.Footer) > 0 {
+		line("")
+		out.Write(escAlignCenter)
+		for _, text := range doc.Footer {
+			line(truncate(text, width))
+		}
+		out.Write(escAlignLeft)
+	}
+
+	out.Write(escFeedCut)
+	return out.Bytes()
+}
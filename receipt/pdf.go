@@ -0,0 +1,199 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// The PDF uses the Courier fonts every PDF reader ships with, so nothing has
+// to be embedded and columns line up by character count.
+const (
+	pageWidth    = 595.0 // A4 in points
+	pageHeight   = 842.0
+	pageMargin   = 50.0
+	bodySize     = 10.0
+	courierWidth = 0.6 // advance of every Courier glyph, in text space units
+)
+
+// columns is how many body characters fit between the margins:
+// (595 - 2*50) / (10 * 0.6) = 82.5.
+const columns = 82
+
+// Widths of the item table; the name takes whatever is left.
+const (
+	quantityWidth = 6
+	priceWidth    = 14
+	amountWidth   = 16
+)
+
+type pdfRow struct {
+	text     string
+	bold     bool
+	size     float64
+	centered bool
+}
+
+// RenderPDF lays the document out on A4 pages and returns the PDF file.
+func RenderPDF(doc Document) []byte {
+	pages := paginate(pdfRows(doc))
+
+	var out bytes.Buffer
+	var offsets []int
+	addObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 page tree, 3 and 4 fonts, then a page and its content per page
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	addObject("<< /Type /Catalog /Pages 2 0 R >>")
+	addObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	addObject("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, rows := range pages {
+		content := pageContent(rows)
+		addObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		addObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfRows turns the document into the rows printed from top to bottom.
+func pdfRows(doc Document) []pdfRow {
+	var rows []pdfRow
+	body := func(text string, bold bool) {
+		rows = append(rows, pdfRow{text: text, bold: bold, size: bodySize})
+	}
+	rule := func() {
+		body(strings.Repeat("-", columns), false)
+	}
+	fields := func(fields []Field, boldLast bool) {
+		for i, field := range fields {
+			body(spread(field.Label, field.Value, columns), boldLast && i == len(fields)-1)
+		}
+	}
+
+	for i, line := range doc.Header {
+		if i == 0 {
+			rows = append(rows, pdfRow{text: line, bold: true, size: 16, centered: true})
+			continue
+		}
+		rows = append(rows, pdfRow{text: line, size: bodySize, centered: true})
+	}
+	if doc.Title != "" {
+		body("", false)
+		rows = append(rows, pdfRow{text: doc.Title, bold: true, size: 13, centered: true})
+	}
+	body("", false)
+	fields(doc.Fields, false)
+
+	nameWidth := columns - quantityWidth - priceWidth - amountWidth
+	body("", false)
+	body(padRight("Item", nameWidth)+padLeft("Qty", quantityWidth)+padLeft("Price", priceWidth)+padLeft("Amount", amountWidth), true)
+	rule()
+	for _, line := range doc.Lines {
+		names := wrap(line.Name, nameWidth-1)
+		body(padRight(names[0], nameWidth)+padLeft(line.Quantity, quantityWidth)+padLeft(line.Price, priceWidth)+padLeft(line.Amount, amountWidth), false)
+		for _, name := range names[1:] {
+			body(name, false)
+		}
+		for _, note := range line.Notes {
+			for _, text := range wrap("  * "+note, columns) {
+				body(text, false)
+			}
+		}
+	}
+	rule()
+	fields(doc.Totals, true)
+
+	if len(doc.Payments) > 0 {
+		body("", false)
+		body("Payments", true)
+		fields(doc.Payments, false)
+	}
+	if len(doc.Footer) > 0 {
+		body("", false)
+		for _, line := range doc.Footer {
+			rows = append(rows, pdfRow{text: line, size: bodySize, centered: true})
+		}
+	}
+	return rows
+}
+
+// paginate splits the rows into pages that fit between the margins.
+func paginate(rows []pdfRow) [][]pdfRow {
+	var pages [][]pdfRow
+	var page []pdfRow
+	used := 0.0
+	for _, row := range rows {
+		height := row.size * 1.4
+		if used+height > pageHeight-2*pageMargin && len(page) > 0 {
+			pages = append(pages, page)
+			page, used = nil, 0
+		}
+		page = append(page, row)
+		used += height
+	}
+	return append(pages, page)
+}
+
+func pageContent(rows []pdfRow) string {
+	var content strings.Builder
+	y := pageHeight - pageMargin
+	for _, row := range rows {
+		y -= row.size * 1.4
+		if row.text == "" {
+			continue
+		}
+		font := "F1"
+		if row.bold {
+			font = "F2"
+		}
+		text := winAnsi(row.text)
+		if fits := int((pageWidth - 2*pageMargin) / (row.size * courierWidth)); len(text) > fits {
+			text = text[:fits]
+		}
+		x := pageMargin
+		if row.centered {
+			x = (pageWidth - float64(len(text))*row.size*courierWidth) / 2
+		}
+		fmt.Fprintf(&content, "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, row.size, x, y, escape(text))
+	}
+	return content.String()
+}
+
+// winAnsi converts text to the single-byte encoding of the standard fonts.
+// Characters it cannot show are printed as "?".
+func winAnsi(text string) string {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			encoded = append(encoded, byte(r))
+		case r == '€':
+			encoded = append(encoded, 0x80)
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return string(encoded)
+}
+
+func escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(text)
+}
@@ -0,0 +1,64 @@
+package receipt
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Helpers for fixed-width layouts. Widths count characters, not bytes.
+
+func padRight(text string, width int) string {
+	text = truncate(text, width)
+	return text + strings.Repeat(" ", width-utf8.RuneCountInString(text))
+}
+
+func padLeft(text string, width int) string {
+	text = truncate(text, width)
+	return strings.Repeat(" ", width-utf8.RuneCountInString(text)) + text
+}
+
+// spread puts the label on the left and the value on the right of a line.
+func spread(label string, value string, width int) string {
+	valueWidth := utf8.RuneCountInString(value)
+	if valueWidth >= width {
+		return truncate(value, width)
+	}
+	return padRight(label, width-valueWidth-1) + " " + value
+}
+
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	return string([]rune(text)[:width])
+}
+
+// wrap breaks text into lines of at most width characters, at spaces where
+// possible. It always returns at least one line.
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for utf8.RuneCountInString(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, string([]rune(word)[:width]))
+			word = string([]rune(word)[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// collection is the storage the repositories are written against. Filters
+// and updates use the MongoDB query language; the in-memory collection
+// understands the subset the repositories use.
+type collection[T any] interface {
+	Find(ctx context.Context, filter bson.M, opts findOptions) ([]T, error)
+	// FindOne returns ErrNotFound when nothing matches.
+	FindOne(ctx context.Context, filter bson.M) (T, error)
+	Count(ctx context.Context, filter bson.M) (int64, error)
+	Insert(ctx context.Context, documents ...T) error
+	// UpdateOne applies the update to the first match and returns the
+	// document as it is afterwards, or ErrNotFound. With upsert a missing
+	// document is created from the equality fields of the filter first.
+	UpdateOne(ctx context.Context, filter bson.M, update bson.M, upsert bool) (T, error)
+	UpdateMany(ctx context.Context, filter bson.M, update bson.M) (int64, error)
+	// Replace swaps the first match for the document, inserting it when
+	// nothing matches.
+	Replace(ctx context.Context, filter bson.M, document T) error
+	Delete(ctx context.Context, filter bson.M) (int64, error)
+}
+
+type findOptions struct {
+	sort  bson.D // field names and 1 or -1
+	skip  int64
+	limit int64 // 0 for no limit
+}
+
+// byCreation lists documents in the order they were created.
+var byCreation = bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}
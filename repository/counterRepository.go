@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type CounterRepository interface {
+	// Next reserves count consecutive numbers of the named sequence and
+	// returns the first one. Sequences start at 1. The increment is a single
+	// atomic update, so concurrent callers never get the same number.
+	Next(ctx context.Context, name string, count int64) (int64, error)
+	// Release hands back count numbers from first on that were reserved but
+	// never used. It only can while they are still the last numbers taken,
+	// and reports whether it did.
+	Release(ctx context.Context, name string, first int64, count int64) (bool, error)
+}
+
+type counter struct {
+	Name string `bson:"_id"`
+	Seq  int64  `bson:"seq"`
+}
+
+type counterRepository struct {
+	counters collection[counter]
+}
+
+func (r *counterRepository) Next(ctx context.Context, name string, count int64) (int64, error) {
+	updated, err := r.counters.UpdateOne(ctx, bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": count}}, true)
+	if err != nil {
+		return 0, err
+	}
+	return updated.Seq - count + 1, nil
+}
+
+func (r *counterRepository) Release(ctx context.Context, name string, first int64, count int64) (bool, error) {
+	filter := bson.M{"_id": name, "seq": first + count - 1}
+	_, err := r.counters.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"seq": -count}}, false)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type CreditNoteRepository interface {
+	// ListByInvoice returns the credit notes of an invoice, oldest first.
+	ListByInvoice(ctx context.Context, invoiceId string) ([]models.CreditNote, error)
+	// ListIssuedBetween returns the credit notes created in [from, to).
+	ListIssuedBetween(ctx context.Context, from time.Time, to time.Time) ([]models.CreditNote, error)
+	Get(ctx context.Context, creditNoteId string) (models.CreditNote, error)
+	Create(ctx context.Context, creditNote models.CreditNote) error
+}
+
+type creditNoteRepository struct {
+	creditNotes collection[models.CreditNote]
+}
+
+func (r *creditNoteRepository) ListByInvoice(ctx context.Context, invoiceId string) ([]models.CreditNote, error) {
+	return r.creditNotes.Find(ctx, bson.M{"invoice_id": invoiceId}, findOptions{sort: byCreation})
+}
+
+func (r *creditNoteRepository) ListIssuedBetween(ctx context.Context, from time.Time, to time.Time) ([]models.CreditNote, error) {
+	return r.creditNotes.Find(ctx, bson.M{"created_at": bson.M{"$gte": from, "$lt": to}}, findOptions{sort: byCreation})
+}
+
+func (r *creditNoteRepository) Get(ctx context.Context, creditNoteId string) (models.CreditNote, error) {
+	return r.creditNotes.FindOne(ctx, bson.M{"credit_note_id": creditNoteId})
+}
+
+func (r *creditNoteRepository) Create(ctx context.Context, creditNote models.CreditNote) error {
+	return r.creditNotes.Insert(ctx, creditNote)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type FoodRepository interface {
+	// List returns a page of foods and how many foods match in total.
+	List(ctx context.Context, query ListQuery) ([]models.Food, int64, error)
+	Get(ctx context.Context, foodId string) (models.Food, error)
+	// GetMany also returns deleted foods, so orders placed before a food
+	// was deleted still show what was served.
+	GetMany(ctx context.Context, foodIds []string) ([]models.Food, error)
+	CountByMenu(ctx context.Context, menuId string) (int64, error)
+	// CountAllByMenu also counts deleted foods, which still show the
+	// category of their menu on orders placed before they were deleted.
+	CountAllByMenu(ctx context.Context, menuId string) (int64, error)
+	Create(ctx context.Context, food models.Food) error
+	// Update sets the given fields and returns the updated food.
+	Update(ctx context.Context, foodId string, set bson.M) (models.Food, error)
+	SoftDeleter[models.Food]
+}
+
+type foodRepository struct {
+	foods collection[models.Food]
+	softDeletes[models.Food]
+}
+
+func newFoodRepository(foods collection[models.Food]) *foodRepository {
+	return &foodRepository{foods: foods, softDeletes: softDeletes[models.Food]{documents: foods, idField: "food_id"}}
+}
+
+func (r *foodRepository) List(ctx context.Context, query ListQuery) ([]models.Food, int64, error) {
+	return listPage(ctx, r.foods, liveOnly(query))
+}
+
+func (r *foodRepository) Get(ctx context.Context, foodId string) (models.Food, error) {
+	return r.foods.FindOne(ctx, live(bson.M{"food_id": foodId}))
+}
+
+func (r *foodRepository) GetMany(ctx context.Context, foodIds []string) ([]models.Food, error) {
+	if len(foodIds) == 0 {
+		return nil, nil
+	}
+	return r.foods.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}}, findOptions{})
+}
+
+func (r *foodRepository) CountByMenu(ctx context.Context, menuId string) (int64, error) {
+	return r.foods.Count(ctx, live(bson.M{"menu_id": menuId}))
+}
+
+func (r *foodRepository) CountAllByMenu(ctx context.Context, menuId string) (int64, error) {
+	return r.foods.Count(ctx, bson.M{"menu_id": menuId})
+}
+
+func (r *foodRepository) Create(ctx context.Context, food models.Food) error {
+	undeleted(&food.BaseEntity)
+	return r.foods.Insert(ctx, food)
+}
+
+func (r *foodRepository) Update(ctx context.Context, foodId string, set bson.M) (models.Food, error) {
+	return r.foods.UpdateOne(ctx, live(bson.M{"food_id": foodId}), bson.M{"$set": set}, false)
+}
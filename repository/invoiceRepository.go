@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type InvoiceRepository interface {
+	// List returns a page of invoices and how many invoices match in total.
+	List(ctx context.Context, query ListQuery) ([]models.Invoice, int64, error)
+	Get(ctx context.Context, invoiceId string) (models.Invoice, error)
+	// FindByReference finds an invoice by its id or by its invoice number.
+	FindByReference(ctx context.Context, reference string) (models.Invoice, error)
+	CountByOrder(ctx context.Context, orderId string) (int64, error)
+	// CountByOrderSince counts the invoices issued for the order at or after since.
+	CountByOrderSince(ctx context.Context, orderId string, since time.Time) (int64, error)
+	// ListIssuedBetween returns the invoices created in [from, to), numbered
+	// invoices included even when they were deleted.
+	ListIssuedBetween(ctx context.Context, from time.Time, to time.Time) ([]models.Invoice, error)
+	Create(ctx context.Context, invoices ...models.Invoice) error
+	// Update sets the given fields and returns the updated invoice.
+	Update(ctx context.Context, invoiceId string, set bson.M) (models.Invoice, error)
+	// Credit adds amount to what was credited on the invoice, as long as the
+	// invoice is still credited for credited. It returns ErrNotFound when it
+	// is not, after another credit note was issued meanwhile.
+	Credit(ctx context.Context, invoiceId string, credited models.Money, amount models.Money) (models.Invoice, error)
+	SoftDeleter[models.Invoice]
+}
+
+type invoiceRepository struct {
+	invoices collection[models.Invoice]
+	softDeletes[models.Invoice]
+}
+
+func newInvoiceRepository(invoices collection[models.Invoice]) *invoiceRepository {
+	return &invoiceRepository{invoices: invoices, softDeletes: softDeletes[models.Invoice]{documents: invoices, idField: "invoice_id"}}
+}
+
+func (r *invoiceRepository) List(ctx context.Context, query ListQuery) ([]models.Invoice, int64, error) {
+	return listPage(ctx, r.invoices, liveOnly(query))
+}
+
+func (r *invoiceRepository) Get(ctx context.Context, invoiceId string) (models.Invoice, error) {
+	return r.invoices.FindOne(ctx, live(bson.M{"invoice_id": invoiceId}))
+}
+
+func (r *invoiceRepository) FindByReference(ctx context.Context, reference string) (models.Invoice, error) {
+	return r.invoices.FindOne(ctx, live(bson.M{"$or": bson.A{bson.M{"invoice_id": reference}, bson.M{"invoice_number": reference}}}))
+}
+
+func (r *invoiceRepository) CountByOrder(ctx context.Context, orderId string) (int64, error) {
+	return r.invoices.Count(ctx, live(bson.M{"order_id": orderId}))
+}
+
+func (r *invoiceRepository) CountByOrderSince(ctx context.Context, orderId string, since time.Time) (int64, error) {
+	return r.invoices.Count(ctx, live(bson.M{"order_id": orderId, "created_at": bson.M{"$gte": since}}))
+}
+
+func (r *invoiceRepository) ListIssuedBetween(ctx context.Context, from time.Time, to time.Time) ([]models.Invoice, error) {
+	filter := bson.M{
+		"created_at": bson.M{"$gte": from, "$lt": to},
+		// A numbered invoice was issued whatever happened to it later
+		"$or": bson.A{
+			bson.M{"deleted_at": notDeleted},
+			bson.M{"invoice_number": bson.M{"$nin": bson.A{nil, ""}}},
+		},
+	}
+	return r.invoices.Find(ctx, filter, findOptions{sort: byCreation})
+}
+
+func (r *invoiceRepository) Create(ctx context.Context, invoices ...models.Invoice) error {
+	for i := range invoices {
+		undeleted(&invoices[i].BaseEntity)
+	}
+	return r.invoices.Insert(ctx, invoices...)
+}
+
+func (r *invoiceRepository) Update(ctx context.Context, invoiceId string, set bson.M) (models.Invoice, error) {
+	return r.invoices.UpdateOne(ctx, live(bson.M{"invoice_id": invoiceId}), bson.M{"$set": set}, false)
+}
+
+func (r *invoiceRepository) Credit(ctx context.Context, invoiceId string, credited models.Money, amount models.Money) (models.Invoice, error) {
+	unchanged := bson.A{bson.M{"amount_credited.amount": credited.Amount}}
+	if credited.IsZero() {
+		// Invoices from before credit notes never had anything credited
+		unchanged = append(unchanged, bson.M{"amount_credited": bson.M{"$exists": false}})
+	}
+	filter := live(bson.M{"invoice_id": invoiceId, "$or": unchanged})
+	update := bson.M{"$set": bson.M{"amount_credited": credited.Add(amount), "updated_at": time.Now()}}
+	return r.invoices.UpdateOne(ctx, filter, update, false)
+}
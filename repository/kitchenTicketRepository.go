@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type KitchenTicketRepository interface {
+	// List returns the tickets in one of the statuses, oldest first, limited
+	// to a station when it is not empty.
+	List(ctx context.Context, statuses []string, station string) ([]models.KitchenTicket, error)
+	ListByOrder(ctx context.Context, orderId string) ([]models.KitchenTicket, error)
+	// ListByOrderItem returns the tickets an order item is on.
+	ListByOrderItem(ctx context.Context, orderItemId string) ([]models.KitchenTicket, error)
+	Get(ctx context.Context, ticketId string) (models.KitchenTicket, error)
+	// CountNotReady counts the tickets of an order the kitchen is still working on.
+	CountNotReady(ctx context.Context, orderId string) (int64, error)
+	Create(ctx context.Context, tickets ...models.KitchenTicket) error
+	// DiscardByOrder removes the tickets of an order that failed to be placed.
+	DiscardByOrder(ctx context.Context, orderId string) error
+	// Update sets the given fields and returns the updated ticket, as long as
+	// the ticket was not updated since it was read with updatedAt. It returns
+	// ErrNotFound when it was, so the caller can read it again.
+	Update(ctx context.Context, ticketId string, updatedAt time.Time, set bson.M) (models.KitchenTicket, error)
+}
+
+type kitchenTicketRepository struct {
+	tickets collection[models.KitchenTicket]
+}
+
+func (r *kitchenTicketRepository) List(ctx context.Context, statuses []string, station string) ([]models.KitchenTicket, error) {
+	filter := bson.M{"status": bson.M{"$in": statuses}}
+	if station != "" {
+		filter["station"] = station
+	}
+	return r.tickets.Find(ctx, filter, findOptions{sort: byCreation})
+}
+
+func (r *kitchenTicketRepository) ListByOrder(ctx context.Context, orderId string) ([]models.KitchenTicket, error) {
+	return r.tickets.Find(ctx, bson.M{"order_id": orderId}, findOptions{sort: byCreation})
+}
+
+func (r *kitchenTicketRepository) ListByOrderItem(ctx context.Context, orderItemId string) ([]models.KitchenTicket, error) {
+	return r.tickets.Find(ctx, bson.M{"items.order_item_id": orderItemId}, findOptions{sort: byCreation})
+}
+
+func (r *kitchenTicketRepository) Get(ctx context.Context, ticketId string) (models.KitchenTicket, error) {
+	return r.tickets.FindOne(ctx, bson.M{"ticket_id": ticketId})
+}
+
+func (r *kitchenTicketRepository) CountNotReady(ctx context.Context, orderId string) (int64, error) {
+	done := bson.A{models.KitchenStatusReady, models.KitchenStatusCancelled}
+	return r.tickets.Count(ctx, bson.M{"order_id": orderId, "status": bson.M{"$nin": done}})
+}
+
+func (r *kitchenTicketRepository) Create(ctx context.Context, tickets ...models.KitchenTicket) error {
+	return r.tickets.Insert(ctx, tickets...)
+}
+
+func (r *kitchenTicketRepository) Update(ctx context.Context, ticketId string, updatedAt time.Time, set bson.M) (models.KitchenTicket, error) {
+	return r.tickets.UpdateOne(ctx, bson.M{"ticket_id": ticketId, "updated_at": updatedAt}, bson.M{"$set": set}, false)
+}
+
+func (r *kitchenTicketRepository) DiscardByOrder(ctx context.Context, orderId string) error {
+	_, err := r.tickets.Delete(ctx, bson.M{"order_id": orderId})
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ListQuery selects one page of a list endpoint: the documents matching
+// Filter, in Sort order. Filter uses the MongoDB query language on document
+// fields; an empty Sort lists documents in the order they were created.
+type ListQuery struct {
+	Filter bson.M
+	Sort   bson.D
+	Skip   int64
+	Limit  int64 // 0 for no limit
+	// Uncounted leaves out counting the matches, which is as slow as reading
+	// them all. The total is then returned as 0.
+	Uncounted bool
+}
+
+// listPage runs the query and counts every document it matches, unless the
+// query is uncounted.
+func listPage[T any](ctx context.Context, documents collection[T], query ListQuery) ([]T, int64, error) {
+	filter := query.Filter
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	var total int64
+	if !query.Uncounted {
+		var err error
+		if total, err = documents.Count(ctx, filter); err != nil {
+			return nil, 0, err
+		}
+	}
+	found, err := documents.Find(ctx, filter, findOptions{sort: stableSort(query.Sort), skip: query.Skip, limit: query.Limit})
+	return found, total, err
+}
+
+// liveOnly leaves soft deleted documents out of the query.
+func liveOnly(query ListQuery) ListQuery {
+	filter := bson.M{}
+	for key, value := range query.Filter {
+		filter[key] = value
+	}
+	query.Filter = live(filter)
+	return query
+}
+
+// stableSort ends the sort on _id, so documents that tie keep their order
+// from one page to the next.
+func stableSort(sort bson.D) bson.D {
+	if len(sort) == 0 {
+		return byCreation
+	}
+	for _, key := range sort {
+		if key.Key == "_id" {
+			return sort
+		}
+	}
+	stable := make(bson.D, 0, len(sort)+1)
+	stable = append(stable, sort...)
+	return append(stable, bson.E{Key: "_id", Value: sort[len(sort)-1].Value})
+}
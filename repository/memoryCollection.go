@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// memoryCollection keeps documents in memory, stored in their BSON form so
+// that callers never share state with the store and values behave as they
+// would after a round trip through MongoDB.
+type memoryCollection[T any] struct {
+	mu        sync.RWMutex
+	documents []bson.M // in insertion order
+}
+
+func newMemoryCollection[T any]() *memoryCollection[T] {
+	return &memoryCollection[T]{}
+}
+
+func (m *memoryCollection[T]) Find(ctx context.Context, filter bson.M, opts findOptions) ([]T, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var matched []bson.M
+	for _, document := range m.documents {
+		if matches(document, query) {
+			matched = append(matched, document)
+		}
+	}
+	m.mu.RUnlock()
+
+	if opts.sort != nil {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return lessBySort(matched[i], matched[j], opts.sort)
+		})
+	}
+	if opts.skip > 0 {
+		matched = matched[min(opts.skip, int64(len(matched))):]
+	}
+	if opts.limit > 0 && int64(len(matched)) > opts.limit {
+		matched = matched[:opts.limit]
+	}
+
+	documents := make([]T, 0, len(matched))
+	for _, document := range matched {
+		decoded, err := fromDocument[T](document)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, decoded)
+	}
+	return documents, nil
+}
+
+func (m *memoryCollection[T]) FindOne(ctx context.Context, filter bson.M) (T, error) {
+	var none T
+	query, err := toDocument(filter)
+	if err != nil {
+		return none, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, document := range m.documents {
+		if matches(document, query) {
+			return fromDocument[T](document)
+		}
+	}
+	return none, ErrNotFound
+}
+
+func (m *memoryCollection[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, document := range m.documents {
+		if matches(document, query) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryCollection[T]) Insert(ctx context.Context, documents ...T) error {
+	encoded := make([]bson.M, 0, len(documents))
+	for _, document := range documents {
+		value, err := toDocument(document)
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, value)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.documents = append(m.documents, encoded...)
+	return nil
+}
+
+func (m *memoryCollection[T]) UpdateOne(ctx context.Context, filter bson.M, update bson.M, upsert bool) (T, error) {
+	var none T
+	query, err := toDocument(filter)
+	if err != nil {
+		return none, err
+	}
+	changes, err := toDocument(update)
+	if err != nil {
+		return none, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, document := range m.documents {
+		if !matches(document, query) {
+			continue
+		}
+		updated, err := applyUpdate(document, changes)
+		if err != nil {
+			return none, err
+		}
+		m.documents[i] = updated
+		return fromDocument[T](updated)
+	}
+	if !upsert {
+		return none, ErrNotFound
+	}
+
+	created, err := applyUpdate(upsertBase(query), changes)
+	if err != nil {
+		return none, err
+	}
+	m.documents = append(m.documents, created)
+	return fromDocument[T](created)
+}
+
+func (m *memoryCollection[T]) UpdateMany(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+	changes, err := toDocument(update)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matched int64
+	for i, document := range m.documents {
+		if !matches(document, query) {
+			continue
+		}
+		updated, err := applyUpdate(document, changes)
+		if err != nil {
+			return matched, err
+		}
+		m.documents[i] = updated
+		matched++
+	}
+	return matched, nil
+}
+
+func (m *memoryCollection[T]) Replace(ctx context.Context, filter bson.M, document T) error {
+	query, err := toDocument(filter)
+	if err != nil {
+		return err
+	}
+	replacement, err := toDocument(document)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.documents {
+		if matches(existing, query) {
+			m.documents[i] = replacement
+			return nil
+		}
+	}
+	m.documents = append(m.documents, replacement)
+	return nil
+}
+
+func (m *memoryCollection[T]) Delete(ctx context.Context, filter bson.M) (int64, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.documents[:0]
+	var deleted int64
+	for _, document := range m.documents {
+		if matches(document, query) {
+			deleted++
+			continue
+		}
+		kept = append(kept, document)
+	}
+	m.documents = kept
+	return deleted, nil
+}
+
+// toDocument encodes a value the way the driver would store it. Filters and
+// updates go through it too, so they compare against stored values.
+func toDocument(value interface{}) (bson.M, error) {
+	if value == nil {
+		return bson.M{}, nil
+	}
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var document bson.M
+	if err := bson.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+func fromDocument[T any](document bson.M) (T, error) {
+	var value T
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return value, err
+	}
+	err = bson.Unmarshal(data, &value)
+	return value, err
+}
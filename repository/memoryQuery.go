@@ -0,0 +1,426 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The in-memory collection evaluates the part of the MongoDB query language
+// the repositories use: equality, $eq, $ne, $in, $nin, $gt, $gte, $lt, $lte,
+// $exists, $or and $and on dotted paths, and the $set, $unset, $inc and $push
+// updates. Documents and queries are in the form toDocument produces.
+// Anything else panics, so a repository that needs more fails loudly in tests
+// instead of matching the wrong documents.
+
+func matches(document bson.M, query bson.M) bool {
+	for key, condition := range query {
+		switch key {
+		case "$or":
+			if !anyMatches(document, condition) {
+				return false
+			}
+		case "$and":
+			for _, clause := range condition.(bson.A) {
+				if !matches(document, clause.(bson.M)) {
+					return false
+				}
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				panic("repository: unsupported query operator " + key)
+			}
+			if !matchField(lookup(document, strings.Split(key, ".")), condition) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func anyMatches(document bson.M, clauses interface{}) bool {
+	for _, clause := range clauses.(bson.A) {
+		if matches(document, clause.(bson.M)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the values at the path, descending into arrays of
+// documents the way MongoDB does. A missing field gives no values.
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	switch typed := value.(type) {
+	case bson.M:
+		child, ok := typed[path[0]]
+		if !ok {
+			return nil
+		}
+		return lookup(child, path[1:])
+	case bson.A:
+		var found []interface{}
+		for _, element := range typed {
+			if _, ok := element.(bson.M); ok {
+				found = append(found, lookup(element, path)...)
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+func matchField(values []interface{}, condition interface{}) bool {
+	operators, ok := condition.(bson.M)
+	if !ok || !isOperatorDocument(operators) {
+		return equalsAny(values, condition)
+	}
+
+	for operator, operand := range operators {
+		var ok bool
+		switch operator {
+		case "$eq":
+			ok = equalsAny(values, operand)
+		case "$ne":
+			ok = !equalsAny(values, operand)
+		case "$in":
+			ok = inList(values, operand)
+		case "$nin":
+			ok = !inList(values, operand)
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = comparesAny(values, operator, operand)
+		case "$exists":
+			ok = (len(values) > 0) == truthy(operand)
+		default:
+			panic("repository: unsupported query operator " + operator)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func isOperatorDocument(document bson.M) bool {
+	if len(document) == 0 {
+		return false
+	}
+	for key := range document {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// equalsAny matches like MongoDB equality: null matches a missing field,
+// and an array matches when it or one of its elements is equal.
+func equalsAny(values []interface{}, want interface{}) bool {
+	if want == nil && len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if equal(value, want) {
+			return true
+		}
+		if array, ok := value.(bson.A); ok {
+			for _, element := range array {
+				if equal(element, want) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func inList(values []interface{}, list interface{}) bool {
+	for _, want := range list.(bson.A) {
+		if equalsAny(values, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func comparesAny(values []interface{}, operator string, operand interface{}) bool {
+	for _, value := range values {
+		order, ok := compare(value, operand)
+		if !ok {
+			continue
+		}
+		switch {
+		case operator == "$gt" && order > 0,
+			operator == "$gte" && order >= 0,
+			operator == "$lt" && order < 0,
+			operator == "$lte" && order <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a interface{}, b interface{}) bool {
+	if order, ok := compare(a, b); ok {
+		return order == 0
+	}
+	switch left := a.(type) {
+	case bson.M:
+		right, ok := b.(bson.M)
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for key, value := range left {
+			other, ok := right[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case bson.A:
+		right, ok := b.(bson.A)
+		if !ok || len(left) != len(right) {
+			return false
+		}
+		for i := range left {
+			if !equal(left[i], right[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two values of the same kind. It reports false for values
+// that cannot be compared, such as a string and a number.
+func compare(a interface{}, b interface{}) (int, bool) {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return sign(x - y), true
+		}
+		return 0, false
+	}
+	switch left := a.(type) {
+	case string:
+		if right, ok := b.(string); ok {
+			return strings.Compare(left, right), true
+		}
+	case primitive.DateTime:
+		if right, ok := b.(primitive.DateTime); ok {
+			return sign(float64(left) - float64(right)), true
+		}
+	case primitive.ObjectID:
+		if right, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(left[:], right[:]), true
+		}
+	case bool:
+		if right, ok := b.(bool); ok {
+			switch {
+			case left == right:
+				return 0, true
+			case right:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func number(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case int32:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case float64:
+		return typed, true
+	}
+	return 0, false
+}
+
+func sign(difference float64) int {
+	switch {
+	case difference < 0:
+		return -1
+	case difference > 0:
+		return 1
+	}
+	return 0
+}
+
+func truthy(value interface{}) bool {
+	if flag, ok := value.(bool); ok {
+		return flag
+	}
+	if n, ok := number(value); ok {
+		return n != 0
+	}
+	return value != nil
+}
+
+// lessBySort reports whether a sorts before b. Values of different kinds
+// follow MongoDB's order, with missing fields and null first.
+func lessBySort(a bson.M, b bson.M, sortBy bson.D) bool {
+	for _, key := range sortBy {
+		path := strings.Split(key.Key, ".")
+		left, right := first(lookup(a, path)), first(lookup(b, path))
+
+		order, ok := compare(left, right)
+		if !ok {
+			order = sign(float64(kindRank(left) - kindRank(right)))
+		}
+		if order == 0 {
+			continue
+		}
+		if descending(key.Value) {
+			return order > 0
+		}
+		return order < 0
+	}
+	return false
+}
+
+func descending(direction interface{}) bool {
+	switch typed := direction.(type) {
+	case int:
+		return typed < 0
+	case int32:
+		return typed < 0
+	case int64:
+		return typed < 0
+	case float64:
+		return typed < 0
+	}
+	return false
+}
+
+func first(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+func kindRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case int32, int64, float64:
+		return 1
+	case string:
+		return 2
+	case bson.M:
+		return 3
+	case bson.A:
+		return 4
+	case primitive.ObjectID:
+		return 5
+	case bool:
+		return 6
+	case primitive.DateTime:
+		return 7
+	}
+	return 8
+}
+
+// applyUpdate returns a copy of the document with the update applied.
+func applyUpdate(document bson.M, update bson.M) (bson.M, error) {
+	updated, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	for operator, fields := range update {
+		changes, ok := fields.(bson.M)
+		if !ok {
+			return nil, fmt.Errorf("repository: %s needs a document", operator)
+		}
+		for path, value := range changes {
+			keys := strings.Split(path, ".")
+			switch operator {
+			case "$set":
+				setPath(updated, keys, value)
+			case "$unset":
+				unsetPath(updated, keys)
+			case "$inc":
+				current, _ := number(first(lookup(updated, keys)))
+				by, ok := number(value)
+				if !ok {
+					return nil, fmt.Errorf("repository: cannot $inc %s by %v", path, value)
+				}
+				if _, isFloat := value.(float64); isFloat {
+					setPath(updated, keys, current+by)
+				} else {
+					setPath(updated, keys, int64(current+by))
+				}
+			case "$push":
+				array, _ := first(lookup(updated, keys)).(bson.A)
+				if each, ok := value.(bson.M); ok && each["$each"] != nil {
+					array = append(array, each["$each"].(bson.A)...)
+				} else {
+					array = append(array, value)
+				}
+				setPath(updated, keys, array)
+			default:
+				panic("repository: unsupported update operator " + operator)
+			}
+		}
+	}
+	// Encode once more so no two documents share a value from the update
+	return toDocument(updated)
+}
+
+func setPath(document bson.M, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := document[key].(bson.M)
+		if !ok {
+			child = bson.M{}
+			document[key] = child
+		}
+		document = child
+	}
+	document[path[len(path)-1]] = value
+}
+
+func unsetPath(document bson.M, path []string) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := document[key].(bson.M)
+		if !ok {
+			return
+		}
+		document = child
+	}
+	delete(document, path[len(path)-1])
+}
+
+// upsertBase is the document an upsert starts from: the equality fields of
+// the query and a new _id when the query does not name one.
+func upsertBase(query bson.M) bson.M {
+	document := bson.M{}
+	for key, condition := range query {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		if operators, ok := condition.(bson.M); ok && isOperatorDocument(operators) {
+			continue
+		}
+		setPath(document, strings.Split(key, "."), condition)
+	}
+	if _, ok := document["_id"]; !ok {
+		document["_id"] = primitive.NewObjectID()
+	}
+	return document
+}
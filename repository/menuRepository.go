@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type MenuRepository interface {
+	// List returns a page of menus and how many menus match in total.
+	List(ctx context.Context, query ListQuery) ([]models.Menu, int64, error)
+	Get(ctx context.Context, menuId string) (models.Menu, error)
+	// GetMany also returns deleted menus, like FoodRepository.GetMany.
+	GetMany(ctx context.Context, menuIds []string) ([]models.Menu, error)
+	Create(ctx context.Context, menu models.Menu) error
+	// Update sets the given fields and returns the updated menu.
+	Update(ctx context.Context, menuId string, set bson.M) (models.Menu, error)
+	SoftDeleter[models.Menu]
+}
+
+type menuRepository struct {
+	menus collection[models.Menu]
+	softDeletes[models.Menu]
+}
+
+func newMenuRepository(menus collection[models.Menu]) *menuRepository {
+	return &menuRepository{menus: menus, softDeletes: softDeletes[models.Menu]{documents: menus, idField: "menu_id"}}
+}
+
+func (r *menuRepository) List(ctx context.Context, query ListQuery) ([]models.Menu, int64, error) {
+	return listPage(ctx, r.menus, liveOnly(query))
+}
+
+func (r *menuRepository) Get(ctx context.Context, menuId string) (models.Menu, error) {
+	return r.menus.FindOne(ctx, live(bson.M{"menu_id": menuId}))
+}
+
+func (r *menuRepository) GetMany(ctx context.Context, menuIds []string) ([]models.Menu, error) {
+	if len(menuIds) == 0 {
+		return nil, nil
+	}
+	return r.menus.Find(ctx, bson.M{"menu_id": bson.M{"$in": menuIds}}, findOptions{})
+}
+
+func (r *menuRepository) Create(ctx context.Context, menu models.Menu) error {
+	undeleted(&menu.BaseEntity)
+	return r.menus.Insert(ctx, menu)
+}
+
+func (r *menuRepository) Update(ctx context.Context, menuId string, set bson.M) (models.Menu, error) {
+	return r.menus.UpdateOne(ctx, live(bson.M{"menu_id": menuId}), bson.M{"$set": set}, false)
+}
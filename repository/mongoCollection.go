@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoCollection[T any] struct {
+	collection *mongo.Collection
+}
+
+func newMongoCollection[T any](db *mongo.Database, name string) *mongoCollection[T] {
+	return &mongoCollection[T]{collection: db.Collection(name)}
+}
+
+func (m *mongoCollection[T]) Find(ctx context.Context, filter bson.M, opts findOptions) ([]T, error) {
+	findOpts := options.Find()
+	if opts.sort != nil {
+		findOpts.SetSort(opts.sort)
+	}
+	if opts.skip > 0 {
+		findOpts.SetSkip(opts.skip)
+	}
+	if opts.limit > 0 {
+		findOpts.SetLimit(opts.limit)
+	}
+
+	cursor, err := m.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	documents := []T{}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (m *mongoCollection[T]) FindOne(ctx context.Context, filter bson.M) (T, error) {
+	var document T
+	err := m.collection.FindOne(ctx, filter).Decode(&document)
+	return document, notFound(err)
+}
+
+func (m *mongoCollection[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return m.collection.CountDocuments(ctx, filter)
+}
+
+func (m *mongoCollection[T]) Insert(ctx context.Context, documents ...T) error {
+	if len(documents) == 0 {
+		return nil
+	}
+	if len(documents) == 1 {
+		_, err := m.collection.InsertOne(ctx, documents[0])
+		return duplicate(err)
+	}
+	many := make([]interface{}, len(documents))
+	for i := range documents {
+		many[i] = documents[i]
+	}
+	_, err := m.collection.InsertMany(ctx, many)
+	return duplicate(err)
+}
+
+func (m *mongoCollection[T]) UpdateOne(ctx context.Context, filter bson.M, update bson.M, upsert bool) (T, error) {
+	var document T
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(upsert)
+	err := m.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&document)
+	return document, notFound(err)
+}
+
+func (m *mongoCollection[T]) UpdateMany(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	result, err := m.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+func (m *mongoCollection[T]) Replace(ctx context.Context, filter bson.M, document T) error {
+	_, err := m.collection.ReplaceOne(ctx, filter, document, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *mongoCollection[T]) Delete(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := m.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// notFound translates the driver's "no documents" error to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicate translates unique index violations to ErrDuplicate.
+func duplicate(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
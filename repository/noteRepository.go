@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type NoteRepository interface {
+	// List returns a page of notes and how many notes match in total.
+	List(ctx context.Context, query ListQuery) ([]models.Note, int64, error)
+	// ListByOwner returns the notes of one owner, oldest first.
+	ListByOwner(ctx context.Context, ownerType string, ownerId string) ([]models.Note, error)
+	// ListByOwners returns the notes of the owners of one type, oldest first.
+	ListByOwners(ctx context.Context, ownerType string, ownerIds []string) ([]models.Note, error)
+	Get(ctx context.Context, noteId string) (models.Note, error)
+	Create(ctx context.Context, note models.Note) error
+	// Update sets the given fields and returns the updated note.
+	Update(ctx context.Context, noteId string, set bson.M) (models.Note, error)
+	// Delete removes the note and reports how many notes were removed.
+	Delete(ctx context.Context, noteId string) (int64, error)
+}
+
+type noteRepository struct {
+	notes collection[models.Note]
+}
+
+func (r *noteRepository) List(ctx context.Context, query ListQuery) ([]models.Note, int64, error) {
+	return listPage(ctx, r.notes, query)
+}
+
+func (r *noteRepository) ListByOwner(ctx context.Context, ownerType string, ownerId string) ([]models.Note, error) {
+	return r.notes.Find(ctx, bson.M{"owner_type": ownerType, "owner_id": ownerId}, findOptions{sort: byCreation})
+}
+
+func (r *noteRepository) ListByOwners(ctx context.Context, ownerType string, ownerIds []string) ([]models.Note, error) {
+	if len(ownerIds) == 0 {
+		return nil, nil
+	}
+	filter := bson.M{"owner_type": ownerType, "owner_id": bson.M{"$in": ownerIds}}
+	return r.notes.Find(ctx, filter, findOptions{sort: byCreation})
+}
+
+func (r *noteRepository) Get(ctx context.Context, noteId string) (models.Note, error) {
+	return r.notes.FindOne(ctx, bson.M{"note_id": noteId})
+}
+
+func (r *noteRepository) Create(ctx context.Context, note models.Note) error {
+	return r.notes.Insert(ctx, note)
+}
+
+func (r *noteRepository) Update(ctx context.Context, noteId string, set bson.M) (models.Note, error) {
+	return r.notes.UpdateOne(ctx, bson.M{"note_id": noteId}, bson.M{"$set": set}, false)
+}
+
+func (r *noteRepository) Delete(ctx context.Context, noteId string) (int64, error) {
+	return r.notes.Delete(ctx, bson.M{"note_id": noteId})
+}
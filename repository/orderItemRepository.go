@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type OrderItemRepository interface {
+	// List returns a page of order items and how many order items match in total.
+	List(ctx context.Context, query ListQuery) ([]models.OrderItem, int64, error)
+	Get(ctx context.Context, orderItemId string) (models.OrderItem, error)
+	// ListByOrder returns the items of an order in the order they were added.
+	ListByOrder(ctx context.Context, orderId string) ([]models.OrderItem, error)
+	CountByFood(ctx context.Context, foodId string) (int64, error)
+	Create(ctx context.Context, orderItems ...models.OrderItem) error
+	// Update sets the given fields and returns the updated item.
+	Update(ctx context.Context, orderItemId string, set bson.M) (models.OrderItem, error)
+	// SetStatus records the kitchen status of the items.
+	SetStatus(ctx context.Context, orderItemIds []string, status string, at time.Time) error
+	// DiscardByOrder removes the items of an order for good, along with an
+	// order that failed to be placed.
+	DiscardByOrder(ctx context.Context, orderId string) error
+	SoftDeleter[models.OrderItem]
+}
+
+type orderItemRepository struct {
+	orderItems collection[models.OrderItem]
+	softDeletes[models.OrderItem]
+}
+
+func newOrderItemRepository(orderItems collection[models.OrderItem]) *orderItemRepository {
+	return &orderItemRepository{orderItems: orderItems, softDeletes: softDeletes[models.OrderItem]{documents: orderItems, idField: "order_item_id"}}
+}
+
+func (r *orderItemRepository) List(ctx context.Context, query ListQuery) ([]models.OrderItem, int64, error) {
+	return listPage(ctx, r.orderItems, liveOnly(query))
+}
+
+func (r *orderItemRepository) Get(ctx context.Context, orderItemId string) (models.OrderItem, error) {
+	return r.orderItems.FindOne(ctx, live(bson.M{"order_item_id": orderItemId}))
+}
+
+func (r *orderItemRepository) ListByOrder(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	return r.orderItems.Find(ctx, live(bson.M{"order_id": orderId}), findOptions{sort: byCreation})
+}
+
+func (r *orderItemRepository) CountByFood(ctx context.Context, foodId string) (int64, error) {
+	return r.orderItems.Count(ctx, live(bson.M{"food_id": foodId}))
+}
+
+func (r *orderItemRepository) Create(ctx context.Context, orderItems ...models.OrderItem) error {
+	for i := range orderItems {
+		undeleted(&orderItems[i].BaseEntity)
+	}
+	return r.orderItems.Insert(ctx, orderItems...)
+}
+
+func (r *orderItemRepository) Update(ctx context.Context, orderItemId string, set bson.M) (models.OrderItem, error) {
+	return r.orderItems.UpdateOne(ctx, live(bson.M{"order_item_id": orderItemId}), bson.M{"$set": set}, false)
+}
+
+func (r *orderItemRepository) SetStatus(ctx context.Context, orderItemIds []string, status string, at time.Time) error {
+	filter := live(bson.M{"order_item_id": bson.M{"$in": orderItemIds}})
+	_, err := r.orderItems.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"status": status, "updated_at": at}})
+	return err
+}
+
+func (r *orderItemRepository) DiscardByOrder(ctx context.Context, orderId string) error {
+	_, err := r.orderItems.Delete(ctx, bson.M{"order_id": orderId})
+	return err
+}
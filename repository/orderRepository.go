@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type OrderRepository interface {
+	// List returns a page of orders and how many orders match in total.
+	List(ctx context.Context, query ListQuery) ([]models.Order, int64, error)
+	Get(ctx context.Context, orderId string) (models.Order, error)
+	Create(ctx context.Context, order models.Order) error
+	// Update sets the given fields and returns the updated order.
+	Update(ctx context.Context, orderId string, set bson.M) (models.Order, error)
+	// Transition records a status change, but only while the order is still
+	// in the status it was read in ("" for orders from before statuses). It
+	// returns ErrNotFound when the status has moved on meanwhile.
+	Transition(ctx context.Context, orderId string, current string, change models.OrderStatusChange) (models.Order, error)
+	// ClaimForInvoicing marks the order as invoiced. It returns ErrNotFound
+	// when the order was marked already, so only one of the requests invoicing
+	// an order at the same time goes on to price it, and when the order is
+	// missing or finished.
+	ClaimForInvoicing(ctx context.Context, orderId string) (models.Order, error)
+	// ReleaseInvoicing hands back the claim of an invoicing that failed.
+	ReleaseInvoicing(ctx context.Context, orderId string) error
+	// CountOpenByTable counts the orders at the table that are not closed,
+	// cancelled or voided yet.
+	CountOpenByTable(ctx context.Context, tableId string) (int64, error)
+	// CountByTable counts all the orders taken at the table, closed or not.
+	CountByTable(ctx context.Context, tableId string) (int64, error)
+	// Discard removes an order for good, whatever its state. It undoes an
+	// order that failed to be placed and is not a way to delete orders.
+	Discard(ctx context.Context, orderId string) error
+	SoftDeleter[models.Order]
+}
+
+type orderRepository struct {
+	orders collection[models.Order]
+	softDeletes[models.Order]
+}
+
+func newOrderRepository(orders collection[models.Order]) *orderRepository {
+	return &orderRepository{orders: orders, softDeletes: softDeletes[models.Order]{documents: orders, idField: "order_id"}}
+}
+
+// finishedOrderStatuses are the statuses an order ends its life in.
+var finishedOrderStatuses = bson.A{models.OrderStatusClosed, models.OrderStatusCancelled, models.OrderStatusVoided}
+
+func (r *orderRepository) List(ctx context.Context, query ListQuery) ([]models.Order, int64, error) {
+	return listPage(ctx, r.orders, liveOnly(query))
+}
+
+func (r *orderRepository) Get(ctx context.Context, orderId string) (models.Order, error) {
+	return r.orders.FindOne(ctx, live(bson.M{"order_id": orderId}))
+}
+
+func (r *orderRepository) Create(ctx context.Context, order models.Order) error {
+	undeleted(&order.BaseEntity)
+	return r.orders.Insert(ctx, order)
+}
+
+func (r *orderRepository) Update(ctx context.Context, orderId string, set bson.M) (models.Order, error) {
+	return r.orders.UpdateOne(ctx, live(bson.M{"order_id": orderId}), bson.M{"$set": set}, false)
+}
+
+func (r *orderRepository) Transition(ctx context.Context, orderId string, current string, change models.OrderStatusChange) (models.Order, error) {
+	var status interface{} = current
+	if current == "" {
+		status = bson.M{"$in": bson.A{nil, ""}}
+	}
+	update := bson.M{
+		"$set":  bson.M{"status": change.To, "updated_at": change.Changed_at},
+		"$push": bson.M{"status_history": change},
+	}
+	return r.orders.UpdateOne(ctx, live(bson.M{"order_id": orderId, "status": status}), update, false)
+}
+
+func (r *orderRepository) ClaimForInvoicing(ctx context.Context, orderId string) (models.Order, error) {
+	filter := live(bson.M{"order_id": orderId, "invoiced": bson.M{"$ne": true}, "status": bson.M{"$nin": finishedOrderStatuses}})
+	return r.orders.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"invoiced": true}}, false)
+}
+
+func (r *orderRepository) ReleaseInvoicing(ctx context.Context, orderId string) error {
+	_, err := r.orders.UpdateOne(ctx, bson.M{"order_id": orderId}, bson.M{"$set": bson.M{"invoiced": false}}, false)
+	return err
+}
+
+func (r *orderRepository) CountOpenByTable(ctx context.Context, tableId string) (int64, error) {
+	return r.orders.Count(ctx, live(bson.M{"table_id": tableId, "status": bson.M{"$nin": finishedOrderStatuses}}))
+}
+
+func (r *orderRepository) CountByTable(ctx context.Context, tableId string) (int64, error) {
+	return r.orders.Count(ctx, live(bson.M{"table_id": tableId}))
+}
+
+func (r *orderRepository) Discard(ctx context.Context, orderId string) error {
+	_, err := r.orders.Delete(ctx, bson.M{"order_id": orderId})
+	return err
+}
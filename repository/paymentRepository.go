@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type PaymentRepository interface {
+	// ListByInvoice returns the ledger of an invoice, oldest first.
+	ListByInvoice(ctx context.Context, invoiceId string) ([]models.Payment, error)
+	// ListPaidBetween returns the payments and refunds made in [from, to).
+	ListPaidBetween(ctx context.Context, from time.Time, to time.Time) ([]models.Payment, error)
+	Create(ctx context.Context, payments ...models.Payment) error
+	// Update sets the given fields and returns the updated payment.
+	Update(ctx context.Context, paymentId string, set bson.M) (models.Payment, error)
+	// SettlePending sets the given fields on the pending payment with the
+	// provider reference and returns it. It returns ErrNotFound when no such
+	// payment is still pending.
+	SettlePending(ctx context.Context, provider string, reference string, set bson.M) (models.Payment, error)
+}
+
+type paymentRepository struct {
+	payments collection[models.Payment]
+}
+
+var byPaymentTime = bson.D{{Key: "paid_at", Value: 1}, {Key: "_id", Value: 1}}
+
+func (r *paymentRepository) ListByInvoice(ctx context.Context, invoiceId string) ([]models.Payment, error) {
+	return r.payments.Find(ctx, bson.M{"invoice_id": invoiceId}, findOptions{sort: byPaymentTime})
+}
+
+func (r *paymentRepository) ListPaidBetween(ctx context.Context, from time.Time, to time.Time) ([]models.Payment, error) {
+	return r.payments.Find(ctx, bson.M{"paid_at": bson.M{"$gte": from, "$lt": to}}, findOptions{sort: byPaymentTime})
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payments ...models.Payment) error {
+	return r.payments.Insert(ctx, payments...)
+}
+
+func (r *paymentRepository) Update(ctx context.Context, paymentId string, set bson.M) (models.Payment, error) {
+	return r.payments.UpdateOne(ctx, bson.M{"payment_id": paymentId}, bson.M{"$set": set}, false)
+}
+
+func (r *paymentRepository) SettlePending(ctx context.Context, provider string, reference string, set bson.M) (models.Payment, error) {
+	filter := bson.M{
+		"provider":           provider,
+		"provider_reference": reference,
+		"status":             models.PaymentStatusPending,
+	}
+	return r.payments.UpdateOne(ctx, filter, bson.M{"$set": set}, false)
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PricingRepository holds the single pricing rules document.
+type PricingRepository interface {
+	// Get returns ErrNotFound while no rules were saved.
+	Get(ctx context.Context) (models.PricingRules, error)
+	// Save replaces the rules as a whole.
+	Save(ctx context.Context, rules models.PricingRules) error
+}
+
+type pricingRepository struct {
+	rules collection[models.PricingRules]
+}
+
+func (r *pricingRepository) Get(ctx context.Context) (models.PricingRules, error) {
+	return r.rules.FindOne(ctx, bson.M{"pricing_id": models.DefaultPricingId})
+}
+
+func (r *pricingRepository) Save(ctx context.Context, rules models.PricingRules) error {
+	return r.rules.Replace(ctx, bson.M{"pricing_id": models.DefaultPricingId}, rules)
+}
@@ -0,0 +1,95 @@
+// Package repository stores the restaurant's data. Each aggregate has its own
+// repository interface; NewMongo backs them with MongoDB and NewMemory keeps
+// everything in memory for tests.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when the requested document does not exist, or
+// when a conditional update found nothing to update.
+var ErrNotFound = errors.New("document not found")
+
+// ErrDuplicate is returned when a unique index rejects a write, such as a
+// second user signing up with the same email at the same time.
+var ErrDuplicate = errors.New("duplicate document")
+
+// Repositories is the set of repositories the handlers work with.
+type Repositories struct {
+	Foods          FoodRepository
+	Menus          MenuRepository
+	Tables         TableRepository
+	Orders         OrderRepository
+	OrderItems     OrderItemRepository
+	Invoices       InvoiceRepository
+	Users          UserRepository
+	Payments       PaymentRepository
+	CreditNotes    CreditNoteRepository
+	Notes          NoteRepository
+	KitchenTickets KitchenTicketRepository
+	Reservations   ReservationRepository
+	Pricing        PricingRepository
+	Counters       CounterRepository
+
+	ping         func(ctx context.Context) error
+	transactions *mongoTransactions // nil in memory
+}
+
+// Ping checks that the store answers. The in-memory store always does.
+func (r Repositories) Ping(ctx context.Context) error {
+	if r.ping == nil {
+		return nil
+	}
+	return r.ping(ctx)
+}
+
+// NewMongo returns repositories over the collections of the database.
+func NewMongo(db *mongo.Database) Repositories {
+	return Repositories{
+		Foods:          newFoodRepository(newMongoCollection[models.Food](db, "food")),
+		Menus:          newMenuRepository(newMongoCollection[models.Menu](db, "menu")),
+		Tables:         newTableRepository(newMongoCollection[models.Table](db, "table")),
+		Orders:         newOrderRepository(newMongoCollection[models.Order](db, "order")),
+		OrderItems:     newOrderItemRepository(newMongoCollection[models.OrderItem](db, "orderItem")),
+		Invoices:       newInvoiceRepository(newMongoCollection[models.Invoice](db, "invoice")),
+		Users:          newUserRepository(newMongoCollection[models.User](db, "user")),
+		Payments:       &paymentRepository{payments: newMongoCollection[models.Payment](db, "payment")},
+		CreditNotes:    &creditNoteRepository{creditNotes: newMongoCollection[models.CreditNote](db, "creditNote")},
+		Notes:          &noteRepository{notes: newMongoCollection[models.Note](db, "note")},
+		KitchenTickets: &kitchenTicketRepository{tickets: newMongoCollection[models.KitchenTicket](db, "kitchenTicket")},
+		Reservations:   &reservationRepository{reservations: newMongoCollection[models.Reservation](db, "reservation")},
+		Pricing:        &pricingRepository{rules: newMongoCollection[models.PricingRules](db, "pricing")},
+		Counters:       &counterRepository{counters: newMongoCollection[counter](db, "counter")},
+
+		ping: func(ctx context.Context) error {
+			return db.Client().Ping(ctx, nil)
+		},
+		transactions: &mongoTransactions{client: db.Client()},
+	}
+}
+
+// NewMemory returns empty repositories that keep their data in memory.
+func NewMemory() Repositories {
+	return Repositories{
+		Foods:          newFoodRepository(newMemoryCollection[models.Food]()),
+		Menus:          newMenuRepository(newMemoryCollection[models.Menu]()),
+		Tables:         newTableRepository(newMemoryCollection[models.Table]()),
+		Orders:         newOrderRepository(newMemoryCollection[models.Order]()),
+		OrderItems:     newOrderItemRepository(newMemoryCollection[models.OrderItem]()),
+		Invoices:       newInvoiceRepository(newMemoryCollection[models.Invoice]()),
+		Users:          newUserRepository(newMemoryCollection[models.User]()),
+		Payments:       &paymentRepository{payments: newMemoryCollection[models.Payment]()},
+		CreditNotes:    &creditNoteRepository{creditNotes: newMemoryCollection[models.CreditNote]()},
+		Notes:          &noteRepository{notes: newMemoryCollection[models.Note]()},
+		KitchenTickets: &kitchenTicketRepository{tickets: newMemoryCollection[models.KitchenTicket]()},
+		Reservations:   &reservationRepository{reservations: newMemoryCollection[models.Reservation]()},
+		Pricing:        &pricingRepository{rules: newMemoryCollection[models.PricingRules]()},
+		Counters:       &counterRepository{counters: newMemoryCollection[counter]()},
+	}
+}
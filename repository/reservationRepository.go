@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// activeReservationStatuses are the statuses that keep a table occupied.
+var activeReservationStatuses = bson.A{models.ReservationStatusBooked, models.ReservationStatusSeated}
+
+type ReservationRepository interface {
+	// List returns a page of reservations and how many reservations match
+	// in total. Without a sort the earliest reservation comes first.
+	List(ctx context.Context, query ListQuery) ([]models.Reservation, int64, error)
+	// CountBooked counts the reservations still booked at the table that
+	// start at or after from.
+	CountBooked(ctx context.Context, tableId string, from time.Time) (int64, error)
+	Get(ctx context.Context, reservationId string) (models.Reservation, error)
+	Create(ctx context.Context, reservation models.Reservation) error
+	// UpdateBooked sets the given fields while the reservation is still
+	// booked, and returns ErrNotFound when it is not.
+	UpdateBooked(ctx context.Context, reservationId string, set bson.M) (models.Reservation, error)
+	// BusyTableIds returns the tables held by a reservation overlapping [start, end).
+	BusyTableIds(ctx context.Context, start time.Time, end time.Time) ([]string, error)
+	// CountOverlapping counts the reservations holding the table during
+	// [start, end), other than the excluded one.
+	CountOverlapping(ctx context.Context, tableId string, start time.Time, end time.Time, excludeReservationId string) (int64, error)
+}
+
+type reservationRepository struct {
+	reservations collection[models.Reservation]
+}
+
+func (r *reservationRepository) List(ctx context.Context, query ListQuery) ([]models.Reservation, int64, error) {
+	if len(query.Sort) == 0 {
+		query.Sort = bson.D{{Key: "start_time", Value: 1}}
+	}
+	return listPage(ctx, r.reservations, query)
+}
+
+func (r *reservationRepository) CountBooked(ctx context.Context, tableId string, from time.Time) (int64, error) {
+	return r.reservations.Count(ctx, bson.M{
+		"table_id":   tableId,
+		"status":     models.ReservationStatusBooked,
+		"start_time": bson.M{"$gte": from},
+	})
+}
+
+func (r *reservationRepository) Get(ctx context.Context, reservationId string) (models.Reservation, error) {
+	return r.reservations.FindOne(ctx, bson.M{"reservation_id": reservationId})
+}
+
+func (r *reservationRepository) Create(ctx context.Context, reservation models.Reservation) error {
+	return r.reservations.Insert(ctx, reservation)
+}
+
+func (r *reservationRepository) UpdateBooked(ctx context.Context, reservationId string, set bson.M) (models.Reservation, error) {
+	filter := bson.M{"reservation_id": reservationId, "status": models.ReservationStatusBooked}
+	return r.reservations.UpdateOne(ctx, filter, bson.M{"$set": set}, false)
+}
+
+func (r *reservationRepository) BusyTableIds(ctx context.Context, start time.Time, end time.Time) ([]string, error) {
+	overlapping, err := r.reservations.Find(ctx, bson.M{
+		"status":     bson.M{"$in": activeReservationStatuses},
+		"start_time": bson.M{"$lt": end},
+		"end_time":   bson.M{"$gt": start},
+	}, findOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	busy := []string{}
+	for _, reservation := range overlapping {
+		if reservation.Table_id != nil && !seen[*reservation.Table_id] {
+			seen[*reservation.Table_id] = true
+			busy = append(busy, *reservation.Table_id)
+		}
+	}
+	return busy, nil
+}
+
+func (r *reservationRepository) CountOverlapping(ctx context.Context, tableId string, start time.Time, end time.Time, excludeReservationId string) (int64, error) {
+	filter := bson.M{
+		"table_id":   tableId,
+		"status":     bson.M{"$in": activeReservationStatuses},
+		"start_time": bson.M{"$lt": end},
+		"end_time":   bson.M{"$gt": start},
+	}
+	if excludeReservationId != "" {
+		filter["reservation_id"] = bson.M{"$ne": excludeReservationId}
+	}
+	return r.reservations.Count(ctx, filter)
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SoftDeleter is implemented by the repositories whose documents are soft
+// deleted. A deleted document keeps its data but is left out of every other
+// query of the repository until it is restored or purged.
+type SoftDeleter[T any] interface {
+	// Delete marks the document as deleted by the user. It returns
+	// ErrNotFound when there is no such document that is not deleted yet.
+	Delete(ctx context.Context, id string, deletedBy string) error
+	// GetDeleted returns a deleted document, or ErrNotFound.
+	GetDeleted(ctx context.Context, id string) (T, error)
+	// Restore clears the deletion and returns the document, or ErrNotFound
+	// when there is no such deleted document.
+	Restore(ctx context.Context, id string) (T, error)
+	// Purge removes a deleted document for good. It returns ErrNotFound
+	// when there is no such deleted document.
+	Purge(ctx context.Context, id string) error
+}
+
+// softDeletes implements SoftDeleter over a collection whose documents are
+// identified by idField.
+type softDeletes[T any] struct {
+	documents collection[T]
+	idField   string
+}
+
+var (
+	notDeleted = bson.M{"$exists": false}
+	isDeleted  = bson.M{"$exists": true}
+)
+
+// live adds the condition that leaves deleted documents out to the filter.
+func live(filter bson.M) bson.M {
+	filter["deleted_at"] = notDeleted
+	return filter
+}
+
+// undeleted clears deletion marks a request body may have carried into a
+// new document. Documents only ever get them through Delete.
+func undeleted(entity *models.BaseEntity) {
+	entity.Deleted_at = nil
+	entity.Deleted_by = nil
+}
+
+func (s softDeletes[T]) Delete(ctx context.Context, id string, deletedBy string) error {
+	now := time.Now()
+	set := bson.M{"deleted_at": now, "deleted_by": deletedBy, "updated_at": now}
+	_, err := s.documents.UpdateOne(ctx, live(bson.M{s.idField: id}), bson.M{"$set": set}, false)
+	return err
+}
+
+func (s softDeletes[T]) GetDeleted(ctx context.Context, id string) (T, error) {
+	return s.documents.FindOne(ctx, bson.M{s.idField: id, "deleted_at": isDeleted})
+}
+
+func (s softDeletes[T]) Restore(ctx context.Context, id string) (T, error) {
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	}
+	return s.documents.UpdateOne(ctx, bson.M{s.idField: id, "deleted_at": isDeleted}, update, false)
+}
+
+func (s softDeletes[T]) Purge(ctx context.Context, id string) error {
+	deleted, err := s.documents.Delete(ctx, bson.M{s.idField: id, "deleted_at": isDeleted})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
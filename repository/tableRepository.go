@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type TableRepository interface {
+	// List returns a page of tables and how many tables match in total.
+	List(ctx context.Context, query ListQuery) ([]models.Table, int64, error)
+	Get(ctx context.Context, tableId string) (models.Table, error)
+	Create(ctx context.Context, table models.Table) error
+	// Update sets the given fields and returns the updated table.
+	Update(ctx context.Context, tableId string, set bson.M) (models.Table, error)
+	// ListSeating returns the tables that seat the party, except the busy
+	// ones, smallest table first.
+	ListSeating(ctx context.Context, partySize int, busyTableIds []string) ([]models.Table, error)
+	// Hold keeps other requests from booking the table until it is released
+	// or until the given time, after which the hold of a request that never
+	// released it lapses. It returns ErrNotFound while the table is held.
+	Hold(ctx context.Context, tableId string, until time.Time) error
+	// Release ends the hold that lasts until the given time.
+	Release(ctx context.Context, tableId string, until time.Time) error
+	SoftDeleter[models.Table]
+}
+
+type tableRepository struct {
+	tables collection[models.Table]
+	softDeletes[models.Table]
+}
+
+func newTableRepository(tables collection[models.Table]) *tableRepository {
+	return &tableRepository{tables: tables, softDeletes: softDeletes[models.Table]{documents: tables, idField: "table_id"}}
+}
+
+func (r *tableRepository) List(ctx context.Context, query ListQuery) ([]models.Table, int64, error) {
+	return listPage(ctx, r.tables, liveOnly(query))
+}
+
+func (r *tableRepository) Get(ctx context.Context, tableId string) (models.Table, error) {
+	return r.tables.FindOne(ctx, live(bson.M{"table_id": tableId}))
+}
+
+func (r *tableRepository) Create(ctx context.Context, table models.Table) error {
+	undeleted(&table.BaseEntity)
+	return r.tables.Insert(ctx, table)
+}
+
+func (r *tableRepository) Update(ctx context.Context, tableId string, set bson.M) (models.Table, error) {
+	return r.tables.UpdateOne(ctx, live(bson.M{"table_id": tableId}), bson.M{"$set": set}, false)
+}
+
+func (r *tableRepository) ListSeating(ctx context.Context, partySize int, busyTableIds []string) ([]models.Table, error) {
+	if busyTableIds == nil {
+		busyTableIds = []string{}
+	}
+	filter := live(bson.M{
+		"number_of_guests": bson.M{"$gte": partySize},
+		"table_id":         bson.M{"$nin": busyTableIds},
+	})
+	sort := bson.D{{Key: "number_of_guests", Value: 1}, {Key: "table_number", Value: 1}}
+	return r.tables.Find(ctx, filter, findOptions{sort: sort})
+}
+
+func (r *tableRepository) Hold(ctx context.Context, tableId string, until time.Time) error {
+	filter := live(bson.M{
+		"table_id": tableId,
+		"$or": bson.A{
+			bson.M{"held_until": bson.M{"$exists": false}},
+			bson.M{"held_until": bson.M{"$lt": time.Now()}},
+		},
+	})
+	_, err := r.tables.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"held_until": until}}, false)
+	return err
+}
+
+func (r *tableRepository) Release(ctx context.Context, tableId string, until time.Time) error {
+	filter := bson.M{"table_id": tableId, "held_until": until}
+	_, err := r.tables.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"held_until": ""}}, false)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transaction runs fn so that the writes it makes through the repositories,
+// with the context it is given, are kept together or not at all. fn may run
+// more than once when the server asks for a retry, so it should only write.
+//
+// Transactions need a replica set or a sharded cluster. On a standalone
+// server, and in memory, fn runs without one and each write it made is
+// reverted by the step it registered with Undo once fn fails. A Transaction
+// started inside another one joins it.
+func (r Repositories) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inTransaction(ctx) {
+		return fn(ctx)
+	}
+	if r.transactions == nil {
+		return undoable(ctx, fn)
+	}
+	return r.transactions.run(ctx, fn)
+}
+
+type undoKey struct{}
+
+// undoLog collects the steps that revert the writes of a function running
+// without a transaction.
+type undoLog struct {
+	steps []func(ctx context.Context) error
+}
+
+// Undo registers the step that reverts a write fn just made, for Transaction
+// to run when fn fails later on. Inside a real transaction there is nothing
+// to revert and the step is dropped.
+func Undo(ctx context.Context, step func(ctx context.Context) error) {
+	if undo, ok := ctx.Value(undoKey{}).(*undoLog); ok {
+		undo.steps = append(undo.steps, step)
+	}
+}
+
+// inTransaction reports whether ctx belongs to a function Transaction runs.
+func inTransaction(ctx context.Context) bool {
+	if _, ok := ctx.Value(undoKey{}).(*undoLog); ok {
+		return true
+	}
+	return mongo.SessionFromContext(ctx) != nil
+}
+
+// undoable runs fn and, when it fails, the undo steps it registered, last
+// first. They run even when fn failed because its context was cancelled.
+func undoable(ctx context.Context, fn func(ctx context.Context) error) error {
+	undo := &undoLog{}
+	err := fn(context.WithValue(ctx, undoKey{}, undo))
+	if err == nil || len(undo.steps) == 0 {
+		return err
+	}
+
+	undoCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	for i := len(undo.steps) - 1; i >= 0; i-- {
+		if undoErr := undo.steps[i](undoCtx); undoErr != nil {
+			log.Printf("could not undo a write after %v: %v", err, undoErr)
+		}
+	}
+	return err
+}
+
+// mongoTransactions runs transactions on the client, once it knows the
+// server supports them.
+type mongoTransactions struct {
+	client *mongo.Client
+
+	mu        sync.Mutex
+	supported *bool // nil until the server was asked
+}
+
+func (t *mongoTransactions) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	supported, err := t.supportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return undoable(ctx, fn)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("starting a session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+// supportsTransactions asks the server once whether it is part of a replica
+// set or a mongos router, the deployments that run transactions.
+func (t *mongoTransactions) supportsTransactions(ctx context.Context) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.supported != nil {
+		return *t.supported, nil
+	}
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := t.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, fmt.Errorf("asking the server whether it supports transactions: %w", err)
+	}
+	supported := hello.SetName != "" || hello.Msg == "isdbgrid"
+	if !supported {
+		log.Println("MongoDB runs standalone, writes that belong together are made without a transaction")
+	}
+	t.supported = &supported
+	return supported, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type UserRepository interface {
+	// List returns a page of users and how many users match in total.
+	List(ctx context.Context, query ListQuery) ([]models.User, int64, error)
+	Get(ctx context.Context, userId string) (models.User, error)
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// CountByEmail and CountByPhone include deleted users, whose email and
+	// phone number stay taken until they are purged.
+	CountByEmail(ctx context.Context, email string) (int64, error)
+	CountByPhone(ctx context.Context, phone string) (int64, error)
+	Create(ctx context.Context, user models.User) error
+	// SetRole changes the role of a user and returns the updated user.
+	SetRole(ctx context.Context, userId string, role string) (models.User, error)
+
+	// SetTokens stores the token pair a user signed in with.
+	SetTokens(ctx context.Context, userId string, token string, refreshToken string) error
+	// RotateTokens replaces the stored token pair, but only while the
+	// presented refresh token is still the one on record. A false result
+	// means the refresh token was already rotated (or revoked) and is being
+	// reused.
+	RotateTokens(ctx context.Context, userId string, presentedRefreshToken string, token string, refreshToken string) (bool, error)
+	// RevokeTokens clears the stored token pair so neither token can be used again.
+	RevokeTokens(ctx context.Context, userId string) error
+	// FindByToken returns the user only while the access token is the one
+	// stored on it, which stops logged out or rotated tokens from being used.
+	FindByToken(ctx context.Context, userId string, token string) (models.User, error)
+	SoftDeleter[models.User]
+}
+
+type userRepository struct {
+	users collection[models.User]
+	softDeletes[models.User]
+}
+
+func newUserRepository(users collection[models.User]) *userRepository {
+	return &userRepository{users: users, softDeletes: softDeletes[models.User]{documents: users, idField: "user_id"}}
+}
+
+func (r *userRepository) List(ctx context.Context, query ListQuery) ([]models.User, int64, error) {
+	return listPage(ctx, r.users, liveOnly(query))
+}
+
+func (r *userRepository) Get(ctx context.Context, userId string) (models.User, error) {
+	return r.users.FindOne(ctx, live(bson.M{"user_id": userId}))
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.users.FindOne(ctx, live(bson.M{"email": email}))
+}
+
+func (r *userRepository) CountByEmail(ctx context.Context, email string) (int64, error) {
+	return r.users.Count(ctx, bson.M{"email": email})
+}
+
+func (r *userRepository) CountByPhone(ctx context.Context, phone string) (int64, error) {
+	return r.users.Count(ctx, bson.M{"phone": phone})
+}
+
+func (r *userRepository) Create(ctx context.Context, user models.User) error {
+	undeleted(&user.BaseEntity)
+	return r.users.Insert(ctx, user)
+}
+
+func (r *userRepository) SetRole(ctx context.Context, userId string, role string) (models.User, error) {
+	set := bson.M{"role": role, "updated_at": time.Now()}
+	return r.users.UpdateOne(ctx, live(bson.M{"user_id": userId}), bson.M{"$set": set}, false)
+}
+
+func (r *userRepository) SetTokens(ctx context.Context, userId string, token string, refreshToken string) error {
+	set := bson.M{"token": token, "refresh_token": refreshToken, "updated_at": time.Now()}
+	_, err := r.users.UpdateOne(ctx, live(bson.M{"user_id": userId}), bson.M{"$set": set}, false)
+	return err
+}
+
+func (r *userRepository) RotateTokens(ctx context.Context, userId string, presentedRefreshToken string, token string, refreshToken string) (bool, error) {
+	filter := live(bson.M{"user_id": userId, "refresh_token": presentedRefreshToken})
+	set := bson.M{"token": token, "refresh_token": refreshToken, "updated_at": time.Now()}
+	_, err := r.users.UpdateOne(ctx, filter, bson.M{"$set": set}, false)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *userRepository) RevokeTokens(ctx context.Context, userId string) error {
+	set := bson.M{"token": nil, "refresh_token": nil, "updated_at": time.Now()}
+	_, err := r.users.UpdateMany(ctx, bson.M{"user_id": userId}, bson.M{"$set": set})
+	return err
+}
+
+func (r *userRepository) FindByToken(ctx context.Context, userId string, token string) (models.User, error) {
+	return r.users.FindOne(ctx, live(bson.M{"user_id": userId, "token": token}))
+}
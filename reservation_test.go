@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	helper "go-restaurant-management/helpers"
+	"go-restaurant-management/models"
+	"go-restaurant-management/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// racingReservations books the table a second time while the first request
+// is still checking it for overlaps.
+type racingReservations struct {
+	repository.ReservationRepository
+	race func(tableId string)
+}
+
+func (r *racingReservations) CountOverlapping(ctx context.Context, tableId string, start time.Time, end time.Time, excludeReservationId string) (int64, error) {
+	if race := r.race; race != nil {
+		r.race = nil
+		race(tableId)
+	}
+	return r.ReservationRepository.CountOverlapping(ctx, tableId, start, end, excludeReservationId)
+}
+
+func TestTableIsBookedOnceWhenRequestsRace(t *testing.T) {
+	repos := repository.NewMemory()
+	reservations := &racingReservations{ReservationRepository: repos.Reservations}
+	repos.Reservations = reservations
+	s := newTestServerOn(t, testSettings(), repos)
+	admin := s.admin()
+	tableId := s.create(http.MethodPost, "/tables", admin.Token, gin.H{"number_of_guests": 4, "table_number": 7})
+
+	booking := func(guest string) gin.H {
+		return gin.H{
+			"table_id":   tableId,
+			"guest_name": guest,
+			"phone":      "555-0100",
+			"party_size": 2,
+			"start_time": time.Now().Add(24 * time.Hour).Truncate(time.Hour),
+		}
+	}
+
+	var raced apiError
+	reservations.race = func(tableId string) {
+		raced = s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/reservations", admin.Token, booking("Second Guest"))
+	}
+	s.create(http.MethodPost, "/reservations", admin.Token, booking("First Guest"))
+	if raced.Code == "" {
+		t.Fatal("the second booking never ran")
+	}
+
+	var page listPage[models.Reservation]
+	s.expect(http.StatusOK, http.MethodGet, "/reservations?table_id="+tableId, admin.Token, nil, &page)
+	if page.Total != 1 {
+		t.Fatalf("the table was booked %d times for the same time, want once", page.Total)
+	}
+	s.expectError(http.StatusConflict, helper.CodeConflict, http.MethodPost, "/reservations", admin.Token, booking("Third Guest"))
+}
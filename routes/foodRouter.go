@@ -2,13 +2,20 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func FoodRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/foods", controller.GetFoods())
-	incomingRoutes.GET("/foods/:food_id", controller.GetFood())
-	incomingRoutes.POST("/foods", controller.CreateFood())
-	incomingRoutes.PATCH("/foods/:food_id", controller.UpdateFood())
+func FoodRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/foods", h.GetFoods())
+	incomingRoutes.GET("/foods/:food_id", h.GetFood())
+	incomingRoutes.POST("/foods", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.CreateFood())
+	incomingRoutes.PATCH("/foods/:food_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.UpdateFood())
+	incomingRoutes.DELETE("/foods/:food_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.DeleteFood())
+
+	// Soft deleted foods are restored or removed for good by an admin
+	incomingRoutes.POST("/foods/:food_id/restore", middleware.Authorize(models.RoleAdmin), h.RestoreFood())
+	incomingRoutes.DELETE("/foods/:food_id/purge", middleware.Authorize(models.RoleAdmin), h.PurgeFood())
 }
\ No newline at end of file
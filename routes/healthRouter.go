@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthRoutes are polled by the orchestrator, which does not log in.
+func HealthRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/healthz", h.Liveness())
+	incomingRoutes.GET("/readyz", h.Readiness())
+}
@@ -2,13 +2,31 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InvoiceRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/invoices", controller.GetInvoices())
-	incomingRoutes.GET("/invoices/:invoice_id", controller.GetInvoice())
-	incomingRoutes.POST("/invoices", controller.CreateInvoice())
-	incomingRoutes.PATCH("/invoices/:invoice_id", controller.UpdateInvoice())
+func InvoiceRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/invoices", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier), h.GetInvoices())
+	incomingRoutes.GET("/invoices/:invoice_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.GetInvoice())
+	incomingRoutes.GET("/invoices/:invoice_id/pdf", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.GetInvoicePDF())
+	incomingRoutes.POST("/invoices/:invoice_id/print", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.PrintInvoice())
+	incomingRoutes.POST("/invoices", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.CreateInvoice())
+	incomingRoutes.PATCH("/invoices/:invoice_id", middleware.Authorize(models.RoleManager, models.RoleCashier), h.UpdateInvoice())
+	incomingRoutes.DELETE("/invoices/:invoice_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.DeleteInvoice())
+
+	// Soft deleted invoices are restored or removed for good by an admin
+	incomingRoutes.POST("/invoices/:invoice_id/restore", middleware.Authorize(models.RoleAdmin), h.RestoreInvoice())
+	incomingRoutes.DELETE("/invoices/:invoice_id/purge", middleware.Authorize(models.RoleAdmin), h.PurgeInvoice())
+
+	// Payment ledger
+	incomingRoutes.GET("/invoices/:invoice_id/payments", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.GetInvoicePayments())
+	incomingRoutes.POST("/invoices/:invoice_id/payments", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.CreatePayment())
+
+	// Credit notes and refunds, the invoice itself stays as issued
+	incomingRoutes.GET("/invoices/:invoice_id/credit-notes", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier), h.GetInvoiceCreditNotes())
+	incomingRoutes.POST("/invoices/:invoice_id/credit-notes", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.CreateCreditNote())
+	incomingRoutes.GET("/credit-notes/:credit_note_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier), h.GetCreditNote())
 }
\ No newline at end of file
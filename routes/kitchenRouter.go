@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/kitchen/tickets", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen, models.RoleWaiter), h.GetKitchenTickets())
+	incomingRoutes.GET("/kitchen/tickets/:ticket_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen, models.RoleWaiter), h.GetKitchenTicket())
+	incomingRoutes.GET("/kitchen/stream", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen, models.RoleWaiter), h.StreamKitchenTickets())
+	incomingRoutes.PATCH("/kitchen/tickets/:ticket_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen), h.BumpKitchenTicket())
+	incomingRoutes.PATCH("/kitchen/tickets/:ticket_id/items/:order_item_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen), h.BumpKitchenTicketItem())
+	incomingRoutes.POST("/kitchen/tickets/:ticket_id/print", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen, models.RoleWaiter), h.PrintKitchenTicket())
+}
@@ -2,13 +2,20 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func MenuRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/menus", controller.GetMenus())
-	incomingRoutes.GET("/menus/:menu_id", controller.GetMenu())
-	incomingRoutes.POST("/menus", controller.CreateMenu())
-	incomingRoutes.PATCH("/menus/:menu_id", controller.UpdateMenu())
+func MenuRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/menus", h.GetMenus())
+	incomingRoutes.GET("/menus/:menu_id", h.GetMenu())
+	incomingRoutes.POST("/menus", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.CreateMenu())
+	incomingRoutes.PATCH("/menus/:menu_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.UpdateMenu())
+	incomingRoutes.DELETE("/menus/:menu_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.DeleteMenu())
+
+	// Soft deleted menus are restored or removed for good by an admin
+	incomingRoutes.POST("/menus/:menu_id/restore", middleware.Authorize(models.RoleAdmin), h.RestoreMenu())
+	incomingRoutes.DELETE("/menus/:menu_id/purge", middleware.Authorize(models.RoleAdmin), h.PurgeMenu())
 }
\ No newline at end of file
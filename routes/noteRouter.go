@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Every signed-in role can work with notes; editing and deleting is limited
+// to the author, managers and admins inside the controller.
+func NoteRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/notes", h.GetNotes())
+	incomingRoutes.GET("/notes/:note_id", h.GetNote())
+	incomingRoutes.POST("/notes", h.CreateNote())
+	incomingRoutes.PATCH("/notes/:note_id", h.UpdateNote())
+	incomingRoutes.DELETE("/notes/:note_id", h.DeleteNote())
+}
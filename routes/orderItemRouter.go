@@ -2,14 +2,21 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func OrderItemRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orderItems", controller.GetOrderItems())
-	incomingRoutes.GET("/orderItems/:orderItem_id", controller.GetOrderItem())
-	incomingRoutes.GET("/orderItems-order/:order_id", controller.GetOrderItemsByOrder())
-	incomingRoutes.POST("/orderItems", controller.CreateOrderItem())
-	incomingRoutes.PATCH("/orderItems/:orderItem_id", controller.UpdateOrderItem())
+func OrderItemRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/orderItems", h.GetOrderItems())
+	incomingRoutes.GET("/orderItems/:orderItem_id", h.GetOrderItem())
+	incomingRoutes.GET("/orderItems-order/:order_id", h.GetOrderItemsByOrder())
+	incomingRoutes.POST("/orderItems", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.CreateOrderItem())
+	incomingRoutes.PATCH("/orderItems/:orderItem_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.UpdateOrderItem())
+	incomingRoutes.DELETE("/orderItems/:orderItem_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.DeleteOrderItem())
+
+	// Soft deleted order items are restored or removed for good by an admin
+	incomingRoutes.POST("/orderItems/:orderItem_id/restore", middleware.Authorize(models.RoleAdmin), h.RestoreOrderItem())
+	incomingRoutes.DELETE("/orderItems/:orderItem_id/purge", middleware.Authorize(models.RoleAdmin), h.PurgeOrderItem())
 }
\ No newline at end of file
@@ -2,13 +2,31 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func OrderRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orders", controller.GetOrders())
-	incomingRoutes.GET("/orders/:order_id", controller.GetOrder())
-	incomingRoutes.POST("/orders", controller.CreateOrder())
-	incomingRoutes.PATCH("/orders/:order_id", controller.UpdateOrder())
+func OrderRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/orders", h.GetOrders())
+	incomingRoutes.GET("/orders/:order_id", h.GetOrder())
+	incomingRoutes.POST("/orders", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.CreateOrder())
+	incomingRoutes.PATCH("/orders/:order_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.UpdateOrder())
+	incomingRoutes.DELETE("/orders/:order_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.DeleteOrder())
+
+	// Soft deleted orders are restored or removed for good by an admin
+	incomingRoutes.POST("/orders/:order_id/restore", middleware.Authorize(models.RoleAdmin), h.RestoreOrder())
+	incomingRoutes.DELETE("/orders/:order_id/purge", middleware.Authorize(models.RoleAdmin), h.PurgeOrder())
+
+	// Lifecycle transitions
+	incomingRoutes.POST("/orders/:order_id/fire", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.TransitionOrder(models.OrderStatusFired))
+	incomingRoutes.POST("/orders/:order_id/ready", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleKitchen), h.TransitionOrder(models.OrderStatusReady))
+	incomingRoutes.POST("/orders/:order_id/serve", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.TransitionOrder(models.OrderStatusServed))
+	incomingRoutes.POST("/orders/:order_id/close", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier), h.TransitionOrder(models.OrderStatusClosed))
+	incomingRoutes.POST("/orders/:order_id/cancel", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.TransitionOrder(models.OrderStatusCancelled))
+	incomingRoutes.POST("/orders/:order_id/void", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.TransitionOrder(models.OrderStatusVoided))
+
+	// Separate bills for one table
+	incomingRoutes.POST("/orders/:order_id/split", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter), h.SplitOrder())
 }
\ No newline at end of file
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentWebhookRoutes are called by the payment provider, which signs its
+// requests instead of logging in, so they are registered before Authentication.
+func PaymentWebhookRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.POST("/payments/webhook", h.PaymentWebhook())
+}
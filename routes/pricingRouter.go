@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PricingRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/pricing", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier), h.GetPricingRules())
+	incomingRoutes.PUT("/pricing", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.UpdatePricingRules())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PrinterRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/printers", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter, models.RoleKitchen), h.GetPrinters())
+	incomingRoutes.GET("/print-jobs/:job_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleCashier, models.RoleWaiter, models.RoleKitchen), h.GetPrintJob())
+}
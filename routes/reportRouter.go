@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReportRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/reports/sales", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.GetSalesReport())
+}
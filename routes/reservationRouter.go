@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReservationRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/reservations", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.GetReservations())
+	incomingRoutes.GET("/reservations/availability", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.GetAvailableTables())
+	incomingRoutes.GET("/reservations/:reservation_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.GetReservation())
+	incomingRoutes.POST("/reservations", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.CreateReservation())
+	incomingRoutes.PATCH("/reservations/:reservation_id", middleware.Authorize(models.RoleAdmin, models.RoleManager, models.RoleWaiter), h.UpdateReservation())
+}
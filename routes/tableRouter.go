@@ -2,14 +2,21 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 
-func TableRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/tables", controller.GetTables())
-	incomingRoutes.GET("/tables/:table_id", controller.GetTable())
-	incomingRoutes.POST("/tables", controller.CreateTable())
-	incomingRoutes.PATCH("/tables/:table_id", controller.UpdateTable())
+func TableRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/tables", h.GetTables())
+	incomingRoutes.GET("/tables/:table_id", h.GetTable())
+	incomingRoutes.POST("/tables", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.CreateTable())
+	incomingRoutes.PATCH("/tables/:table_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.UpdateTable())
+	incomingRoutes.DELETE("/tables/:table_id", middleware.Authorize(models.RoleAdmin, models.RoleManager), h.DeleteTable())
+
+	// Soft deleted tables are restored or removed for good by an admin
+	incomingRoutes.POST("/tables/:table_id/restore", middleware.Authorize(models.RoleAdmin), h.RestoreTable())
+	incomingRoutes.DELETE("/tables/:table_id/purge", middleware.Authorize(models.RoleAdmin), h.PurgeTable())
 }
\ No newline at end of file
@@ -2,13 +2,21 @@ package routes
 
 import (
 	controller "go-restaurant-management/controllers"
+	middleware "go-restaurant-management/middleware"
+	"go-restaurant-management/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", controller.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controller.GetUser())
-	incomingRoutes.POST("/users/signup", controller.SignUp())
-	incomingRoutes.POST("/users/login", controller.Login())
+func UserRoutes(incomingRoutes *gin.Engine, h *controller.Handler) {
+	incomingRoutes.GET("/users", middleware.Authentication(h.Users), middleware.Authorize(models.RoleAdmin), h.GetUsers())
+	incomingRoutes.GET("/users/:user_id", middleware.Authentication(h.Users), middleware.Authorize(models.RoleAdmin, models.RoleManager), h.GetUser())
+	incomingRoutes.PATCH("/users/:user_id/role", middleware.Authentication(h.Users), middleware.Authorize(models.RoleAdmin), h.UpdateUserRole())
+	incomingRoutes.DELETE("/users/:user_id", middleware.Authentication(h.Users), middleware.Authorize(models.RoleAdmin), h.DeleteUser())
+	incomingRoutes.POST("/users/:user_id/restore", middleware.Authentication(h.Users), middleware.Authorize(models.RoleAdmin), h.RestoreUser())
+	incomingRoutes.DELETE("/users/:user_id/purge", middleware.Authentication(h.Users), middleware.Authorize(models.RoleAdmin), h.PurgeUser())
+	incomingRoutes.POST("/users/signup", h.SignUp())
+	incomingRoutes.POST("/users/login", h.Login())
+	incomingRoutes.POST("/users/refresh", h.RefreshToken())
+	incomingRoutes.POST("/users/logout", middleware.Authentication(h.Users), h.Logout())
 }
\ No newline at end of file